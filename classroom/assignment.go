@@ -0,0 +1,381 @@
+package classroom
+
+import (
+	"context"
+	"errors"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/quiz"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AssignmentQuizType is the quiz type used for students' assignment quizzes
+const AssignmentQuizType = "assignment"
+
+var (
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	ErrAssignmentNotOpen  = errors.New("assignment is not open yet")
+	ErrAttemptLimit       = errors.New("no attempts left for this assignment")
+	ErrNoOpenAttempt      = errors.New("no attempt in progress")
+	ErrAttemptStarting    = errors.New("the attempt is still being started, try again")
+)
+
+// resolveTestQuestions flattens the modules of a practice test into one question set
+func resolveTestQuestions(testName string) ([]primitive.ObjectID, error) {
+	for _, test := range parameterdata.Tests {
+		if test.Name != testName {
+			continue
+		}
+
+		seen := make(map[primitive.ObjectID]bool)
+		var questionIDs []primitive.ObjectID
+		for _, questionList := range test.QuestionLists {
+			for _, id := range questionList {
+				questionID, err := primitive.ObjectIDFromHex(id)
+				if err != nil {
+					return nil, fmt.Errorf("invalid question ID in test %s: %w", testName, err)
+				}
+				if !seen[questionID] {
+					seen[questionID] = true
+					questionIDs = append(questionIDs, questionID)
+				}
+			}
+		}
+		return questionIDs, nil
+	}
+
+	return nil, fmt.Errorf("unknown test %s", testName)
+}
+
+// CreateAssignment validates and stores a new assignment. A TestName is expanded into the questions of that practice test.
+func (s *ClassService) CreateAssignment(ctx context.Context, assignment *Assignment) (*Assignment, error) {
+	if assignment.TestName != "" {
+		questionIDs, err := resolveTestQuestions(assignment.TestName)
+		if err != nil {
+			return nil, err
+		}
+		assignment.QuestionIDs = questionIDs
+	}
+
+	if assignment.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if len(assignment.QuestionIDs) == 0 {
+		return nil, errors.New("assignment must have at least one question")
+	}
+	if assignment.OpenTime.IsZero() {
+		assignment.OpenTime = time.Now()
+	}
+	if assignment.DueTime.IsZero() || !assignment.DueTime.After(assignment.OpenTime) {
+		return nil, errors.New("due date must be after the open date")
+	}
+	if assignment.AttemptLimit < 0 {
+		return nil, errors.New("attempt limit cannot be negative")
+	}
+	if assignment.AttemptLimit == 0 {
+		assignment.AttemptLimit = 1
+	}
+	assignment.CreatedTime = time.Now()
+
+	result, err := s.assignmentCollection.InsertOne(ctx, assignment)
+	if err != nil {
+		return nil, err
+	}
+	assignment.ID = result.InsertedID.(primitive.ObjectID)
+
+	return assignment, nil
+}
+
+func (s *ClassService) GetAssignment(ctx context.Context, id primitive.ObjectID) (*Assignment, error) {
+	var assignment Assignment
+	err := s.assignmentCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&assignment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAssignmentNotFound
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// GetAssignmentsForClass returns a class's assignments, soonest due first
+func (s *ClassService) GetAssignmentsForClass(ctx context.Context, classID primitive.ObjectID) ([]Assignment, error) {
+	return s.findAssignments(ctx, bson.M{"class_id": classID})
+}
+
+// GetAssignmentsForStudent returns the open assignments of every class the student is in
+func (s *ClassService) GetAssignmentsForStudent(ctx context.Context, studentID primitive.ObjectID) ([]Assignment, error) {
+	classes, err := s.GetClassesForStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(classes) == 0 {
+		return []Assignment{}, nil
+	}
+
+	classIDs := make([]primitive.ObjectID, len(classes))
+	for i, class := range classes {
+		classIDs[i] = class.ID
+	}
+
+	return s.findAssignments(ctx, bson.M{"class_id": bson.M{"$in": classIDs}, "open_time": bson.M{"$lte": time.Now()}})
+}
+
+func (s *ClassService) findAssignments(ctx context.Context, filter bson.M) ([]Assignment, error) {
+	cursor, err := s.assignmentCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"due_time": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := []Assignment{}
+	if err = cursor.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// GetAttempts returns a student's attempts at an assignment in order
+func (s *ClassService) GetAttempts(ctx context.Context, assignmentID, studentID primitive.ObjectID) ([]AssignmentAttempt, error) {
+	cursor, err := s.attemptCollection.Find(ctx,
+		bson.M{"assignment_id": assignmentID, "student_id": studentID},
+		options.Find().SetSort(bson.M{"number": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []AssignmentAttempt{}
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// StartAssignment gives the student their own quiz for the assignment.
+// If an attempt is already in progress it is returned instead of starting another.
+func (s *ClassService) StartAssignment(ctx context.Context, assignment *Assignment, studentID primitive.ObjectID, quizService *quiz.QuizService) (*AssignmentAttempt, error) {
+	now := time.Now()
+	if now.Before(assignment.OpenTime) {
+		return nil, ErrAssignmentNotOpen
+	}
+
+	attempts, err := s.GetAttempts(ctx, assignment.ID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attempts {
+		if attempts[i].SubmittedTime == nil {
+			return &attempts[i], nil
+		}
+	}
+	if len(attempts) >= assignment.AttemptLimit {
+		return nil, ErrAttemptLimit
+	}
+
+	// The attempt is stored before its quiz is created. The unique index on its number means that of two
+	// concurrent starts only one creates a quiz; the other returns the same attempt.
+	attempt := AssignmentAttempt{
+		AssignmentID: assignment.ID,
+		StudentID:    studentID,
+		Number:       len(attempts) + 1,
+		StartedTime:  now,
+	}
+
+	result, err := s.attemptCollection.InsertOne(ctx, attempt)
+	if mongo.IsDuplicateKeyError(err) {
+		return s.getStartedAttempt(ctx, assignment.ID, studentID, attempt.Number)
+	} else if err != nil {
+		return nil, err
+	}
+	attempt.ID = result.InsertedID.(primitive.ObjectID)
+
+	quizID, err := createAttemptQuiz(ctx, assignment, &attempt, quizService)
+	if err == nil {
+		attempt.QuizID = quizID
+		_, err = s.attemptCollection.UpdateOne(ctx, bson.M{"_id": attempt.ID}, bson.M{"$set": bson.M{"quiz_id": quizID}})
+	}
+	if err != nil {
+		// Remove the attempt so the student can start again
+		if _, deleteErr := s.attemptCollection.DeleteOne(ctx, bson.M{"_id": attempt.ID}); deleteErr != nil {
+			return nil, deleteErr
+		}
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// createAttemptQuiz creates the student's quiz for an attempt and starts its timer
+func createAttemptQuiz(ctx context.Context, assignment *Assignment, attempt *AssignmentAttempt, quizService *quiz.QuizService) (primitive.ObjectID, error) {
+	quizType := AssignmentQuizType
+	// Quiz names are unique per user, and students can have assignments with the same title from different classes
+	quizName := assignment.Title + " (" + assignment.ID.Hex() + ") - Attempt " + strconv.Itoa(attempt.Number)
+	quizID, err := quizService.InitializeQuiz(ctx, assignment.QuestionIDs, attempt.StudentID, &quizType, &quizName)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if assignment.TimeLimit > 0 {
+		if _, err := quizService.StartQuizTimer(ctx, quizID, assignment.TimeLimit); err != nil {
+			if deleteErr := quizService.DeleteQuiz(ctx, quizID); deleteErr != nil {
+				return primitive.NilObjectID, deleteErr
+			}
+			return primitive.NilObjectID, err
+		}
+	}
+
+	return quizID, nil
+}
+
+// getStartedAttempt returns an attempt started by a concurrent request, once its quiz has been created
+func (s *ClassService) getStartedAttempt(ctx context.Context, assignmentID, studentID primitive.ObjectID, number int) (*AssignmentAttempt, error) {
+	var attempt AssignmentAttempt
+	err := s.attemptCollection.FindOne(ctx, bson.M{"assignment_id": assignmentID, "student_id": studentID, "number": number}).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		// The other request failed and removed its attempt
+		return nil, ErrAttemptStarting
+	} else if err != nil {
+		return nil, err
+	}
+	if attempt.QuizID.IsZero() {
+		return nil, ErrAttemptStarting
+	}
+	return &attempt, nil
+}
+
+// SubmitAssignment submits the student's attempt in progress and scores it. Submissions after the due date are marked late.
+func (s *ClassService) SubmitAssignment(ctx context.Context, assignment *Assignment, studentID primitive.ObjectID, quizService *quiz.QuizService, engagementService *engagement.EngagementService) (*AssignmentAttempt, error) {
+	attempts, err := s.GetAttempts(ctx, assignment.ID, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range attempts {
+		if attempts[i].SubmittedTime != nil {
+			continue
+		}
+
+		// Quizzes already closed by their timer keep the time they were closed
+		if err := quizService.SubmitQuiz(ctx, attempts[i].QuizID, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := s.finishAttempt(ctx, &attempts[i], assignment, quizService, engagementService); err != nil {
+			return nil, err
+		}
+		return &attempts[i], nil
+	}
+
+	return nil, ErrNoOpenAttempt
+}
+
+// finishAttempt scores the attempt once its quiz has been submitted and saves it.
+// Only answers given in the attempt's quiz count, so earlier attempts and practice after the quiz closed
+// do not change the score, however long after closing it is scored.
+func (s *ClassService) finishAttempt(ctx context.Context, attempt *AssignmentAttempt, assignment *Assignment, quizService *quiz.QuizService, engagementService *engagement.EngagementService) error {
+	attemptQuiz, err := quizService.GetQuiz(ctx, attempt.QuizID)
+	if err != nil {
+		return err
+	}
+
+	submittedTime := time.Now()
+	if attemptQuiz.SubmittedTime != nil {
+		submittedTime = *attemptQuiz.SubmittedTime
+	}
+
+	statuses, err := engagementService.GetQuizStatuses(ctx, attempt.StudentID, attempt.QuizID)
+	if err != nil {
+		return err
+	}
+
+	attempt.SubmittedTime = &submittedTime
+	attempt.Late = submittedTime.After(assignment.DueTime)
+	attempt.NumTotal = len(attemptQuiz.QuestionEngagementIDCombos)
+	attempt.NumCorrect = 0
+	for _, combo := range attemptQuiz.QuestionEngagementIDCombos {
+		if combo.QuestionID != nil && statuses[*combo.QuestionID] == engagement.StatusCorrect {
+			attempt.NumCorrect++
+		}
+	}
+	if attempt.NumTotal > 0 {
+		score := float64(attempt.NumCorrect) / float64(attempt.NumTotal) * 100
+		attempt.Score = &score
+	}
+
+	_, err = s.attemptCollection.UpdateOne(ctx, bson.M{"_id": attempt.ID}, bson.M{"$set": bson.M{
+		"submitted_time": attempt.SubmittedTime,
+		"late":           attempt.Late,
+		"num_correct":    attempt.NumCorrect,
+		"num_total":      attempt.NumTotal,
+		"score":          attempt.Score,
+	}})
+	return err
+}
+
+// GetGradebook returns completion and score per student on the class roster.
+// Attempts whose quiz was closed by its timer are scored here, since the student never submitted them.
+func (s *ClassService) GetGradebook(ctx context.Context, class *Class, assignment *Assignment, quizService *quiz.QuizService, engagementService *engagement.EngagementService) (*Gradebook, error) {
+	roster, err := s.GetRoster(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	gradebook := &Gradebook{Assignment: assignment, Students: make([]GradebookEntry, len(roster))}
+	scoreSum := 0.0
+	numScored := 0
+
+	for i, student := range roster {
+		entry := GradebookEntry{Student: student}
+
+		attempts, err := s.GetAttempts(ctx, assignment.ID, student.ID)
+		if err != nil {
+			return nil, err
+		}
+		entry.Attempts = len(attempts)
+
+		for j := range attempts {
+			attempt := &attempts[j]
+			if attempt.SubmittedTime == nil {
+				attemptQuiz, err := quizService.GetQuiz(ctx, attempt.QuizID)
+				if err != nil || !attemptQuiz.Submitted || attemptQuiz.SubmittedTime == nil {
+					continue
+				}
+				if err := s.finishAttempt(ctx, attempt, assignment, quizService, engagementService); err != nil {
+					return nil, err
+				}
+			}
+
+			entry.Completed = true
+			entry.Late = attempt.Late
+			entry.SubmittedTime = attempt.SubmittedTime
+			entry.LatestScore = attempt.Score
+			if attempt.Score != nil && (entry.BestScore == nil || *attempt.Score > *entry.BestScore) {
+				entry.BestScore = attempt.Score
+			}
+		}
+
+		if entry.Completed {
+			gradebook.NumCompleted++
+		}
+		if entry.BestScore != nil {
+			scoreSum += *entry.BestScore
+			numScored++
+		}
+		gradebook.Students[i] = entry
+	}
+
+	if len(roster) > 0 {
+		gradebook.CompletionRate = float64(gradebook.NumCompleted) / float64(len(roster)) * 100
+	}
+	if numScored > 0 {
+		average := scoreSum / float64(numScored)
+		gradebook.AverageScore = &average
+	}
+
+	return gradebook, nil
+}
@@ -0,0 +1,74 @@
+package classroom
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Class is a group of students run by a tutor. Students join with the invite code.
+type Class struct {
+	ID          primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	Name        string               `json:"Name" bson:"name"`
+	TutorID     primitive.ObjectID   `json:"TutorID" bson:"tutor_id"`
+	InviteCode  string               `json:"InviteCode,omitempty" bson:"invite_code"`
+	StudentIDs  []primitive.ObjectID `json:"StudentIDs" bson:"student_ids"`
+	CreatedTime time.Time            `json:"CreatedTime" bson:"created_time"`
+}
+
+// StudentSummary is how a student is listed on a class roster
+type StudentSummary struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Email     string             `json:"Email" bson:"email"`
+	FirstName string             `json:"FirstName" bson:"first_name"`
+	LastName  string             `json:"LastName" bson:"last_name"`
+}
+
+// Assignment is a question set a tutor has given to a class. Each student works on their own quiz.
+type Assignment struct {
+	ID           primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	ClassID      primitive.ObjectID   `json:"ClassID" bson:"class_id"`
+	Title        string               `json:"Title" bson:"title"`
+	QuestionIDs  []primitive.ObjectID `json:"QuestionIDs" bson:"question_ids"`
+	TestName     string               `json:"TestName,omitempty" bson:"test_name,omitempty"`
+	OpenTime     time.Time            `json:"OpenTime" bson:"open_time"`
+	DueTime      time.Time            `json:"DueTime" bson:"due_time"`
+	AttemptLimit int                  `json:"AttemptLimit" bson:"attempt_limit"`
+	TimeLimit    time.Duration        `json:"TimeLimit,omitempty" bson:"time_limit,omitempty"`
+	CreatedTime  time.Time            `json:"CreatedTime" bson:"created_time"`
+}
+
+// AssignmentAttempt is one student's attempt at an assignment
+type AssignmentAttempt struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	AssignmentID  primitive.ObjectID `json:"AssignmentID" bson:"assignment_id"`
+	StudentID     primitive.ObjectID `json:"StudentID" bson:"student_id"`
+	QuizID        primitive.ObjectID `json:"QuizID" bson:"quiz_id"`
+	Number        int                `json:"Number" bson:"number"`
+	StartedTime   time.Time          `json:"StartedTime" bson:"started_time"`
+	SubmittedTime *time.Time         `json:"SubmittedTime,omitempty" bson:"submitted_time,omitempty"`
+	Late          bool               `json:"Late" bson:"late"`
+	NumCorrect    int                `json:"NumCorrect" bson:"num_correct"`
+	NumTotal      int                `json:"NumTotal" bson:"num_total"`
+	Score         *float64           `json:"Score,omitempty" bson:"score,omitempty"`
+}
+
+// GradebookEntry is one student's row in an assignment's grade book
+type GradebookEntry struct {
+	Student       StudentSummary `json:"Student"`
+	Attempts      int            `json:"Attempts"`
+	Completed     bool           `json:"Completed"`
+	Late          bool           `json:"Late"`
+	BestScore     *float64       `json:"BestScore,omitempty"`
+	LatestScore   *float64       `json:"LatestScore,omitempty"`
+	SubmittedTime *time.Time     `json:"SubmittedTime,omitempty"`
+}
+
+// Gradebook shows completion and score per student for an assignment
+type Gradebook struct {
+	Assignment     *Assignment      `json:"Assignment"`
+	Students       []GradebookEntry `json:"Students"`
+	NumCompleted   int              `json:"NumCompleted"`
+	CompletionRate float64          `json:"CompletionRate"`
+	AverageScore   *float64         `json:"AverageScore,omitempty"`
+}
@@ -0,0 +1,540 @@
+package classroom
+
+import (
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/test"
+	"example/goserver/user"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *ClassService, userService *user.UserService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) {
+	requireTutor := user.RequireRole(userService, user.RoleTutor)
+
+	publicRouter.POST("/class", requireTutor, createClass(service))
+	publicRouter.GET("/classes", getClasses(service))
+	publicRouter.POST("/class/join", joinClass(service))
+	publicRouter.GET("/class/:id", requireTutor, getClass(service, userService))
+	publicRouter.POST("/class/:id/invitecode", requireTutor, resetInviteCode(service, userService))
+	publicRouter.DELETE("/class/:id/students/:studentID", requireTutor, removeStudent(service, userService))
+
+	// Read-only views of a student's progress, for the tutor of a class the student is in
+	publicRouter.GET("/class/:id/students/:studentID/datacube", requireTutor, getStudentDataCube(service, userService, dataCubeService))
+	publicRouter.GET("/class/:id/students/:studentID/tests", requireTutor, getStudentTests(service, userService, testService, quizService, questionService, engagementService, scoringService))
+	publicRouter.GET("/class/:id/students/:studentID/quizzes", requireTutor, getStudentQuizzes(service, userService, quizService, questionService, engagementService))
+
+	// Assignments
+	publicRouter.POST("/class/:id/assignments", requireTutor, createAssignment(service, userService))
+	publicRouter.GET("/class/:id/assignments", requireTutor, getClassAssignments(service, userService))
+	publicRouter.GET("/class/:id/assignments/:assignmentID/gradebook", requireTutor, getGradebook(service, userService, quizService, engagementService))
+	publicRouter.GET("/assignments", getStudentAssignments(service))
+	publicRouter.GET("/assignment/:id", getAssignment(service))
+	publicRouter.POST("/assignment/:id/start", startAssignment(service, quizService))
+	publicRouter.POST("/assignment/:id/submit", submitAssignment(service, quizService, engagementService))
+}
+
+// currentUserID returns the logged in user's ID, writing an error response if there is none
+func currentUserID(c *gin.Context) (primitive.ObjectID, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+		return primitive.NilObjectID, false
+	}
+
+	userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return primitive.NilObjectID, false
+	}
+	return userIDObj, true
+}
+
+// tutorClass loads the class in the URL and checks that the logged in user is its tutor. Super admins can see every class.
+func tutorClass(c *gin.Context, service *ClassService, userService *user.UserService) (*Class, bool) {
+	userIDObj, ok := currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	classID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid class ID"})
+		return nil, false
+	}
+
+	class, err := service.GetClass(c, classID)
+	if err != nil {
+		if err == ErrClassNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if class.TutorID != userIDObj {
+		role, err := userService.FetchUserRoleFromDB(c, userIDObj.Hex())
+		if err != nil || role != user.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return nil, false
+		}
+	}
+
+	return class, true
+}
+
+// classStudent checks that the student in the URL is in a class the logged in user tutors
+func classStudent(c *gin.Context, service *ClassService, userService *user.UserService) (primitive.ObjectID, bool) {
+	class, ok := tutorClass(c, service, userService)
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+
+	studentID, err := primitive.ObjectIDFromHex(c.Param("studentID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		return primitive.NilObjectID, false
+	}
+
+	if !class.HasStudent(studentID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Student is not in this class"})
+		return primitive.NilObjectID, false
+	}
+
+	return studentID, true
+}
+
+func createClass(service *ClassService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Name string `json:"Name"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.TrimSpace(request.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+			return
+		}
+
+		class, err := service.CreateClass(c, strings.TrimSpace(request.Name), userIDObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, class)
+	}
+}
+
+// getClasses returns the classes the user tutors and the classes they have joined.
+// Students only see the name of a class, not its roster or invite code.
+func getClasses(service *ClassService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		tutoring, err := service.GetClassesForTutor(c, userIDObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		joined, err := service.GetClassesForStudent(c, userIDObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		type joinedClass struct {
+			ID   primitive.ObjectID `json:"id"`
+			Name string             `json:"Name"`
+		}
+		joinedClasses := make([]joinedClass, len(joined))
+		for i, class := range joined {
+			joinedClasses[i] = joinedClass{ID: class.ID, Name: class.Name}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"Tutoring": tutoring, "Joined": joinedClasses})
+	}
+}
+
+func joinClass(service *ClassService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			InviteCode string `json:"InviteCode"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		class, err := service.JoinClass(c, strings.ToUpper(strings.TrimSpace(request.InviteCode)), userIDObj)
+		if err != nil {
+			if err == ErrClassNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invalid invite code"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"id": class.ID, "Name": class.Name})
+	}
+}
+
+// getClass returns a class with its roster
+func getClass(service *ClassService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, ok := tutorClass(c, service, userService)
+		if !ok {
+			return
+		}
+
+		roster, err := service.GetRoster(c, class)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"Class": class, "Students": roster})
+	}
+}
+
+func resetInviteCode(service *ClassService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, ok := tutorClass(c, service, userService)
+		if !ok {
+			return
+		}
+
+		code, err := service.ResetInviteCode(c, class.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"InviteCode": code})
+	}
+}
+
+func removeStudent(service *ClassService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, ok := classStudent(c, service, userService)
+		if !ok {
+			return
+		}
+
+		classID, _ := primitive.ObjectIDFromHex(c.Param("id"))
+		if err := service.RemoveStudent(c, classID, studentID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Student removed successfully"})
+	}
+}
+
+func getStudentDataCube(service *ClassService, userService *user.UserService, dataCubeService *datacube.DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, ok := classStudent(c, service, userService)
+		if !ok {
+			return
+		}
+
+		dataCube, err := dataCubeService.GetDataCube(&studentID)
+		if err != nil {
+			dataCube, err = dataCubeService.ComputeDataCube(&studentID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, dataCube)
+	}
+}
+
+func getStudentTests(service *ClassService, userService *user.UserService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, ok := classStudent(c, service, userService)
+		if !ok {
+			return
+		}
+
+		tests, err := testService.GetTestsForUser(c, studentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy := question.PolicyFor(c, userService)
+
+		testResults := make([]test.TestResult, len(tests))
+		for i, studentTest := range tests {
+			testResult, err := testService.GetTestUnderlying(c, quizService, questionService, engagementService, scoringService, studentTest)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			testResult.ApplyAccessPolicy(policy)
+			testResults[i] = *testResult
+		}
+
+		c.JSON(http.StatusOK, testResults)
+	}
+}
+
+func getStudentQuizzes(service *ClassService, userService *user.UserService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentID, ok := classStudent(c, service, userService)
+		if !ok {
+			return
+		}
+
+		results, err := quiz.GetQuizzesUnderlyingForUserID(c, quizService, questionService, engagementService, studentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy := question.PolicyFor(c, userService)
+		for _, result := range results {
+			result.ApplyAccessPolicy(policy)
+		}
+
+		c.JSON(http.StatusOK, results)
+	}
+}
+
+type CreateAssignmentRequest struct {
+	Title        string    `json:"Title"`
+	QuestionIDs  []string  `json:"QuestionIDs"`
+	TestName     string    `json:"TestName"`
+	OpenTime     time.Time `json:"OpenTime"`
+	DueTime      time.Time `json:"DueTime"`
+	AttemptLimit int       `json:"AttemptLimit"`
+	// TimeLimitMinutes puts each attempt on a timer when set
+	TimeLimitMinutes int `json:"TimeLimitMinutes"`
+}
+
+func createAssignment(service *ClassService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, ok := tutorClass(c, service, userService)
+		if !ok {
+			return
+		}
+
+		var request CreateAssignmentRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		assignment := Assignment{
+			ClassID:      class.ID,
+			Title:        strings.TrimSpace(request.Title),
+			TestName:     request.TestName,
+			OpenTime:     request.OpenTime,
+			DueTime:      request.DueTime,
+			AttemptLimit: request.AttemptLimit,
+			TimeLimit:    time.Duration(request.TimeLimitMinutes) * time.Minute,
+		}
+		for _, id := range request.QuestionIDs {
+			questionID, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+				return
+			}
+			assignment.QuestionIDs = append(assignment.QuestionIDs, questionID)
+		}
+
+		created, err := service.CreateAssignment(c, &assignment)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+func getClassAssignments(service *ClassService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, ok := tutorClass(c, service, userService)
+		if !ok {
+			return
+		}
+
+		assignments, err := service.GetAssignmentsForClass(c, class.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, assignments)
+	}
+}
+
+func getGradebook(service *ClassService, userService *user.UserService, quizService *quiz.QuizService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		class, ok := tutorClass(c, service, userService)
+		if !ok {
+			return
+		}
+
+		assignmentID, err := primitive.ObjectIDFromHex(c.Param("assignmentID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+			return
+		}
+
+		assignment, err := service.GetAssignment(c, assignmentID)
+		if err != nil || assignment.ClassID != class.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrAssignmentNotFound.Error()})
+			return
+		}
+
+		gradebook, err := service.GetGradebook(c, class, assignment, quizService, engagementService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gradebook)
+	}
+}
+
+// studentAssignment loads the assignment in the URL and checks that the logged in user is in its class
+func studentAssignment(c *gin.Context, service *ClassService) (*Assignment, primitive.ObjectID, bool) {
+	userIDObj, ok := currentUserID(c)
+	if !ok {
+		return nil, primitive.NilObjectID, false
+	}
+
+	assignmentID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return nil, primitive.NilObjectID, false
+	}
+
+	assignment, err := service.GetAssignment(c, assignmentID)
+	if err != nil {
+		if err == ErrAssignmentNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, primitive.NilObjectID, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, primitive.NilObjectID, false
+	}
+
+	class, err := service.GetClass(c, assignment.ClassID)
+	if err != nil || !class.HasStudent(userIDObj) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, primitive.NilObjectID, false
+	}
+
+	return assignment, userIDObj, true
+}
+
+// getStudentAssignments lists the open assignments for every class the user is in
+func getStudentAssignments(service *ClassService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		assignments, err := service.GetAssignmentsForStudent(c, userIDObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, assignments)
+	}
+}
+
+// getAssignment returns an assignment with the user's attempts at it
+func getAssignment(service *ClassService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, userIDObj, ok := studentAssignment(c, service)
+		if !ok {
+			return
+		}
+
+		attempts, err := service.GetAttempts(c, assignment.ID, userIDObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"Assignment": assignment, "Attempts": attempts})
+	}
+}
+
+func startAssignment(service *ClassService, quizService *quiz.QuizService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, userIDObj, ok := studentAssignment(c, service)
+		if !ok {
+			return
+		}
+
+		attempt, err := service.StartAssignment(c, assignment, userIDObj, quizService)
+		if err != nil {
+			switch err {
+			case ErrAssignmentNotOpen, ErrAttemptLimit:
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			case ErrAttemptStarting:
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, attempt)
+	}
+}
+
+func submitAssignment(service *ClassService, quizService *quiz.QuizService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, userIDObj, ok := studentAssignment(c, service)
+		if !ok {
+			return
+		}
+
+		attempt, err := service.SubmitAssignment(c, assignment, userIDObj, quizService, engagementService)
+		if err != nil {
+			if err == ErrNoOpenAttempt {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, attempt)
+	}
+}
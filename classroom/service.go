@@ -0,0 +1,226 @@
+package classroom
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// inviteCodeAlphabet leaves out characters that are easy to confuse, such as 0 and O
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const inviteCodeLength = 6
+
+// ErrClassNotFound is returned for unknown classes and invite codes
+var ErrClassNotFound = errors.New("class not found")
+
+type ClassService struct {
+	collection           *mongo.Collection
+	userCollection       *mongo.Collection
+	assignmentCollection *mongo.Collection
+	attemptCollection    *mongo.Collection
+}
+
+func NewClassService(ctx context.Context, client *mongo.Client) (*ClassService, error) {
+	collection := client.Database("test").Collection("classes")
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "invite_code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "student_ids", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(ctx, indexModels)
+	if err != nil {
+		return nil, fmt.Errorf("could not create index: %w", err)
+	}
+
+	attemptCollection := client.Database("test").Collection("assignment_attempts")
+	_, err = attemptCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "assignment_id", Value: 1}, {Key: "student_id", Value: 1}, {Key: "number", Value: 1}},
+		// Stops two concurrent starts from creating the same attempt twice
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create index: %w", err)
+	}
+
+	return &ClassService{
+		collection:           collection,
+		userCollection:       client.Database("test").Collection("users"),
+		assignmentCollection: client.Database("test").Collection("assignments"),
+		attemptCollection:    attemptCollection,
+	}, nil
+}
+
+func newInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CreateClass creates an empty class for a tutor
+func (s *ClassService) CreateClass(ctx context.Context, name string, tutorID primitive.ObjectID) (*Class, error) {
+	class := Class{
+		Name:        name,
+		TutorID:     tutorID,
+		StudentIDs:  []primitive.ObjectID{},
+		CreatedTime: time.Now(),
+	}
+
+	// Retry in the unlikely case the invite code is already taken
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := newInviteCode()
+		if err != nil {
+			return nil, err
+		}
+		class.InviteCode = code
+
+		result, err := s.collection.InsertOne(ctx, class)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		class.ID = result.InsertedID.(primitive.ObjectID)
+		return &class, nil
+	}
+
+	return nil, errors.New("could not generate a unique invite code")
+}
+
+func (s *ClassService) GetClass(ctx context.Context, id primitive.ObjectID) (*Class, error) {
+	var class Class
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&class)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrClassNotFound
+		}
+		return nil, err
+	}
+	return &class, nil
+}
+
+// GetClassesForTutor returns the classes the tutor runs
+func (s *ClassService) GetClassesForTutor(ctx context.Context, tutorID primitive.ObjectID) ([]Class, error) {
+	return s.findClasses(ctx, bson.M{"tutor_id": tutorID})
+}
+
+// GetClassesForStudent returns the classes the student has joined
+func (s *ClassService) GetClassesForStudent(ctx context.Context, studentID primitive.ObjectID) ([]Class, error) {
+	return s.findClasses(ctx, bson.M{"student_ids": studentID})
+}
+
+func (s *ClassService) findClasses(ctx context.Context, filter bson.M) ([]Class, error) {
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_time": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	classes := []Class{}
+	if err = cursor.All(ctx, &classes); err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// JoinClass adds a student to the class with the invite code
+func (s *ClassService) JoinClass(ctx context.Context, inviteCode string, studentID primitive.ObjectID) (*Class, error) {
+	var class Class
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"invite_code": inviteCode},
+		bson.M{"$addToSet": bson.M{"student_ids": studentID}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&class)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrClassNotFound
+		}
+		return nil, err
+	}
+	return &class, nil
+}
+
+// RemoveStudent takes a student off the roster
+func (s *ClassService) RemoveStudent(ctx context.Context, classID primitive.ObjectID, studentID primitive.ObjectID) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": classID}, bson.M{"$pull": bson.M{"student_ids": studentID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrClassNotFound
+	}
+	return nil
+}
+
+// ResetInviteCode replaces the invite code, so the old one can no longer be used to join
+func (s *ClassService) ResetInviteCode(ctx context.Context, classID primitive.ObjectID) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := newInviteCode()
+		if err != nil {
+			return "", err
+		}
+
+		result, err := s.collection.UpdateOne(ctx, bson.M{"_id": classID}, bson.M{"$set": bson.M{"invite_code": code}})
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if result.MatchedCount == 0 {
+			return "", ErrClassNotFound
+		}
+		return code, nil
+	}
+
+	return "", errors.New("could not generate a unique invite code")
+}
+
+// GetRoster returns the students in a class
+func (s *ClassService) GetRoster(ctx context.Context, class *Class) ([]StudentSummary, error) {
+	roster := []StudentSummary{}
+	if len(class.StudentIDs) == 0 {
+		return roster, nil
+	}
+
+	projection := bson.M{"email": 1, "first_name": 1, "last_name": 1}
+	cursor, err := s.userCollection.Find(ctx, bson.M{"_id": bson.M{"$in": class.StudentIDs}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cursor.All(ctx, &roster); err != nil {
+		return nil, err
+	}
+	return roster, nil
+}
+
+// HasStudent reports whether the student is on the class roster
+func (c *Class) HasStudent(studentID primitive.ObjectID) bool {
+	for _, id := range c.StudentIDs {
+		if id == studentID {
+			return true
+		}
+	}
+	return false
+}
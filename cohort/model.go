@@ -0,0 +1,50 @@
+package cohort
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of cohort statistics
+const (
+	KindDataCube = "datacube" // a cell of the data cube, e.g. accuracy in Algebra on hard questions
+	KindScore    = "score"    // a scaled score of the latest completed test
+)
+
+// CohortStat is the distribution of one measure across every user with a value for it.
+// Values holds the sorted values of all users and is only used to rank a user within the cohort.
+type CohortStat struct {
+	Kind       string    `json:"Kind" bson:"kind"`
+	Row        string    `json:"Row" bson:"row"`
+	Cell       string    `json:"Cell" bson:"cell"`
+	Column     string    `json:"Column" bson:"column"`
+	Count      int       `json:"Count" bson:"count"`
+	Mean       float64   `json:"Mean" bson:"mean"`
+	P10        float64   `json:"P10" bson:"p10"`
+	P25        float64   `json:"P25" bson:"p25"`
+	P50        float64   `json:"P50" bson:"p50"`
+	P75        float64   `json:"P75" bson:"p75"`
+	P90        float64   `json:"P90" bson:"p90"`
+	Values     []float64 `json:"-" bson:"values"`
+	ComputedAt time.Time `json:"ComputedAt" bson:"computed_at"`
+}
+
+// Comparison places one of the user's values within the cohort.
+// Percentile and Cohort are nil when too few users have a value to compare against.
+type Comparison struct {
+	Row        string      `json:"Row"`
+	Cell       string      `json:"Cell"`
+	Column     string      `json:"Column"`
+	Value      float64     `json:"Value"`
+	Percentile *float64    `json:"Percentile"`
+	Cohort     *CohortStat `json:"Cohort"`
+}
+
+// PeerComparison is the user's percentile rank in every data cube cell and test score
+type PeerComparison struct {
+	UserID        primitive.ObjectID `json:"UserID"`
+	MinCohortSize int                `json:"MinCohortSize"`
+	Cells         []Comparison       `json:"Cells"`
+	Scores        []Comparison       `json:"Scores"`
+}
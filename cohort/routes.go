@@ -0,0 +1,56 @@
+package cohort
+
+import (
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/test"
+	"example/goserver/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *CohortService, userService *user.UserService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) {
+	publicRouter.GET("/cohort/percentiles", getPeerComparison(service, dataCubeService, testService, quizService, questionService, engagementService, scoringService))
+	publicRouter.POST("/cohort/compute", user.RequireRole(userService, user.RoleContentAdmin), computeCohortStats(service, dataCubeService, testService, quizService, questionService, engagementService, scoringService))
+}
+
+func getPeerComparison(service *CohortService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		comparison, err := service.GetPeerComparison(c, userIDObj, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, comparison)
+	}
+}
+
+func computeCohortStats(service *CohortService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, err := service.ComputeCohortStats(c, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Cohort stats computed successfully", "numStats": count})
+	}
+}
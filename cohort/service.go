@@ -0,0 +1,287 @@
+package cohort
+
+import (
+	"context"
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/test"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MinCohortSize is the fewest users a distribution is kept for, so no single user's results can be inferred
+const MinCohortSize = 10
+
+// cohortCells are the data cube cells compared across users. Usage counts users who have questions in the cell,
+// accuracy only those who have answered one.
+var cohortCells = []string{"accuracy", "usage"}
+
+// cohortColumns are the difficulty columns of every cell
+var cohortColumns = []string{"easy", "medium", "hard", "extreme", "hardextreme", "total"}
+
+type CohortService struct {
+	collection *mongo.Collection
+}
+
+func NewCohortService(client *mongo.Client) *CohortService {
+	collection := client.Database("test").Collection("cohort_stats")
+	return &CohortService{collection: collection}
+}
+
+type statKey struct {
+	kind, row, cell, column string
+}
+
+// cubeValues returns the values of a data cube that are compared across users
+func cubeValues(dataCube *datacube.DataCube) map[statKey]float64 {
+	values := make(map[statKey]float64)
+	for rowName, row := range dataCube.Rows {
+		for _, column := range cohortColumns {
+			total := datacube.CellValue(row.Cells["total"], column)
+			attempted := datacube.CellValue(row.Cells["attempted"], column)
+			if total > 0 {
+				values[statKey{KindDataCube, rowName, "usage", column}] = datacube.CellValue(row.Cells["usage"], column)
+			}
+			if attempted > 0 {
+				values[statKey{KindDataCube, rowName, "accuracy", column}] = datacube.CellValue(row.Cells["accuracy"], column)
+			}
+		}
+	}
+	return values
+}
+
+// scoreValues returns the scaled scores of a test result that are compared across users
+func scoreValues(result *test.TestResult) map[statKey]float64 {
+	return map[statKey]float64{
+		{KindScore, "Math", "scaled", ""}:    result.MathScaled,
+		{KindScore, "Reading", "scaled", ""}: result.ReadingScaled,
+		{KindScore, "Total", "scaled", ""}:   result.TotalScaled,
+	}
+}
+
+// quantile returns the p quantile of sorted values, interpolating between the closest ranks.
+// An empty cohort has no quantiles and returns 0.
+func quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+// percentileRank returns the percentage of values below value, counting ties as half below.
+// Nobody is ranked against an empty cohort, so it returns 0.
+func percentileRank(sorted []float64, value float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	below := sort.SearchFloat64s(sorted, value)
+	notAbove := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })
+	return (float64(below) + float64(notAbove-below)/2) / float64(len(sorted)) * 100
+}
+
+func newCohortStat(key statKey, values []float64, now time.Time) *CohortStat {
+	sort.Float64s(values)
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+
+	return &CohortStat{
+		Kind:       key.kind,
+		Row:        key.row,
+		Cell:       key.cell,
+		Column:     key.column,
+		Count:      len(values),
+		Mean:       sum / float64(len(values)),
+		P10:        quantile(values, 0.10),
+		P25:        quantile(values, 0.25),
+		P50:        quantile(values, 0.50),
+		P75:        quantile(values, 0.75),
+		P90:        quantile(values, 0.90),
+		Values:     values,
+		ComputedAt: now,
+	}
+}
+
+// ComputeCohortStats recomputes the distribution of every data cube cell across all stored data cubes,
+// and of the scaled scores of each user's latest completed test. Distributions with fewer than
+// MinCohortSize users are not kept. It returns the number of distributions stored.
+func (s *CohortService) ComputeCohortStats(ctx context.Context, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) (int, error) {
+	values := make(map[statKey][]float64)
+
+	err := dataCubeService.ForEachDataCube(ctx, func(dataCube *datacube.DataCube) error {
+		// The shared cube of users who are not logged in is not anyone's results
+		if dataCube.UserID.IsZero() {
+			return nil
+		}
+		for key, value := range cubeValues(dataCube) {
+			values[key] = append(values[key], value)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tests, err := testService.GetLatestCompletedTests(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	for _, completed := range tests {
+		result, err := testService.GetTestUnderlying(ctx, quizService, questionService, engagementService, scoringService, completed)
+		if err != nil {
+			// One broken test should not keep everyone else's scores out of the cohort
+			fmt.Println("Error scoring test for cohort stats:", completed.ID.Hex(), err)
+			continue
+		}
+		for key, value := range scoreValues(result) {
+			values[key] = append(values[key], value)
+		}
+	}
+
+	now := time.Now()
+	stored := 0
+	for key, stat := range cohortStats(values, now) {
+		filter := bson.M{"kind": key.kind, "row": key.row, "cell": key.cell, "column": key.column}
+		_, err := s.collection.ReplaceOne(ctx, filter, stat, options.Replace().SetUpsert(true))
+		if err != nil {
+			return stored, fmt.Errorf("error saving cohort stat: %w", err)
+		}
+		stored++
+	}
+
+	// Distributions that were not recomputed have dropped below the minimum cohort size
+	if _, err := s.collection.DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": now}}); err != nil {
+		return stored, fmt.Errorf("error removing old cohort stats: %w", err)
+	}
+
+	return stored, nil
+}
+
+// cohortStats returns the distribution of each key's values, leaving out those of fewer than MinCohortSize users
+func cohortStats(values map[statKey][]float64, now time.Time) map[statKey]*CohortStat {
+	stats := make(map[statKey]*CohortStat)
+	for key, keyValues := range values {
+		if len(keyValues) < MinCohortSize {
+			continue
+		}
+		stats[key] = newCohortStat(key, keyValues, now)
+	}
+	return stats
+}
+
+// RunCohortStats recomputes the cohort statistics now and then every interval until the context is cancelled
+func (s *CohortService) RunCohortStats(ctx context.Context, interval time.Duration, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ComputeCohortStats(ctx, dataCubeService, testService, quizService, questionService, engagementService, scoringService); err != nil {
+			fmt.Println("Error computing cohort stats:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getCohortStats returns the stored distributions by key
+func (s *CohortService) getCohortStats(ctx context.Context) (map[statKey]*CohortStat, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting cohort stats: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var stats []CohortStat
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	statsByKey := make(map[statKey]*CohortStat, len(stats))
+	for i := range stats {
+		stat := &stats[i]
+		statsByKey[statKey{stat.Kind, stat.Row, stat.Cell, stat.Column}] = stat
+	}
+	return statsByKey, nil
+}
+
+// compare ranks each of the user's values within its stored distribution, sorted by row, cell and column
+func compare(values map[statKey]float64, stats map[statKey]*CohortStat) []Comparison {
+	comparisons := make([]Comparison, 0, len(values))
+	for key, value := range values {
+		comparison := Comparison{Row: key.row, Cell: key.cell, Column: key.column, Value: value}
+		if stat, ok := stats[key]; ok && stat.Count >= MinCohortSize {
+			percentile := percentileRank(stat.Values, value)
+			comparison.Percentile = &percentile
+			comparison.Cohort = stat
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	sort.Slice(comparisons, func(i, j int) bool {
+		a, b := comparisons[i], comparisons[j]
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		if a.Cell != b.Cell {
+			return a.Cell < b.Cell
+		}
+		return a.Column < b.Column
+	})
+	return comparisons
+}
+
+// GetPeerComparison returns the user's percentile rank in each data cube cell and in the scaled scores
+// of their latest completed test, using the distributions from the last time the cohort stats were computed
+func (s *CohortService) GetPeerComparison(ctx context.Context, userID primitive.ObjectID, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) (*PeerComparison, error) {
+	stats, err := s.getCohortStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dataCube, _ := dataCubeService.GetDataCube(&userID)
+	if dataCube == nil {
+		dataCube, err = dataCubeService.ComputeDataCube(&userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scores := make(map[statKey]float64)
+	tests, err := testService.GetLatestCompletedTests(ctx, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tests) > 0 {
+		result, err := testService.GetTestUnderlying(ctx, quizService, questionService, engagementService, scoringService, tests[0])
+		if err != nil {
+			return nil, err
+		}
+		scores = scoreValues(result)
+	}
+
+	return &PeerComparison{
+		UserID:        userID,
+		MinCohortSize: MinCohortSize,
+		Cells:         compare(cubeValues(dataCube), stats),
+		Scores:        compare(scores, stats),
+	}, nil
+}
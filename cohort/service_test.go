@@ -0,0 +1,142 @@
+package cohort
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty cohort", nil, 0.5, 0},
+		{"single user median", []float64{5}, 0.5, 5},
+		{"single user p90", []float64{5}, 0.9, 5},
+		{"minimum", []float64{1, 2, 3, 4}, 0, 1},
+		{"maximum", []float64{1, 2, 3, 4}, 1, 4},
+		{"median between ranks", []float64{1, 2, 3, 4}, 0.5, 2.5},
+		{"quartile between ranks", []float64{1, 2, 3, 4}, 0.25, 1.75},
+		{"median within ties", []float64{2, 2, 2, 8}, 0.5, 2},
+		{"past the ties", []float64{2, 2, 2, 8}, 0.9, 6.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quantile(tt.sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("quantile(%v, %g) = %g, want %g", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentileRank(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		value  float64
+		want   float64
+	}{
+		{"empty cohort", nil, 3, 0},
+		{"single user tied", []float64{5}, 5, 50},
+		{"single user below", []float64{5}, 4, 0},
+		{"single user above", []float64{5}, 6, 100},
+		{"ties count half", []float64{1, 2, 2, 2, 3}, 2, 50},
+		{"lowest value", []float64{1, 2, 2, 2, 3}, 1, 10},
+		{"between values", []float64{1, 2, 2, 2, 3}, 2.5, 80},
+		{"everyone tied", []float64{4, 4, 4}, 4, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentileRank(tt.sorted, tt.value); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("percentileRank(%v, %g) = %g, want %g", tt.sorted, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// cohortOf returns n values counting down from n, so they are not sorted
+func cohortOf(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = float64(n - i)
+	}
+	return values
+}
+
+func TestCohortStats(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		size int
+		kept bool
+	}{
+		{"empty", 0, false},
+		{"single user", 1, false},
+		{"just below the minimum", MinCohortSize - 1, false},
+		{"at the minimum", MinCohortSize, true},
+		{"above the minimum", MinCohortSize + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := statKey{KindDataCube, "Algebra", "accuracy", "total"}
+			stats := cohortStats(map[statKey][]float64{key: cohortOf(tt.size)}, now)
+
+			stat, ok := stats[key]
+			if ok != tt.kept {
+				t.Fatalf("kept = %v, want %v", ok, tt.kept)
+			}
+			if !ok {
+				return
+			}
+			if stat.Count != tt.size || stat.Values[0] != 1 || stat.Values[tt.size-1] != float64(tt.size) {
+				t.Errorf("stat = %+v, want %d sorted values", stat, tt.size)
+			}
+			if want := float64(tt.size+1) / 2; stat.Mean != want || stat.P50 != want {
+				t.Errorf("mean %g and median %g, want %g", stat.Mean, stat.P50, want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	small := statKey{KindDataCube, "Circles", "accuracy", "total"}
+	large := statKey{KindDataCube, "Algebra", "accuracy", "total"}
+	missing := statKey{KindDataCube, "Geometry", "accuracy", "total"}
+
+	stats := map[statKey]*CohortStat{
+		small: {Count: MinCohortSize - 1, Values: cohortOf(MinCohortSize - 1)},
+		large: {Count: MinCohortSize, Values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}
+	comparisons := compare(map[statKey]float64{small: 5, large: 3, missing: 1}, stats)
+
+	want := []struct {
+		row        string
+		percentile *float64
+	}{
+		{"Algebra", func() *float64 { p := 25.0; return &p }()},
+		{"Circles", nil},
+		{"Geometry", nil},
+	}
+	if len(comparisons) != len(want) {
+		t.Fatalf("got %d comparisons, want %d", len(comparisons), len(want))
+	}
+	for i, w := range want {
+		got := comparisons[i]
+		if got.Row != w.row {
+			t.Errorf("comparison %d row = %q, want %q", i, got.Row, w.row)
+		}
+		if (got.Percentile == nil) != (w.percentile == nil) || (w.percentile != nil && *got.Percentile != *w.percentile) {
+			t.Errorf("%s percentile = %v, want %v", got.Row, got.Percentile, w.percentile)
+		}
+		if (got.Cohort == nil) != (w.percentile == nil) {
+			t.Errorf("%s cohort = %v, want it only with a percentile", got.Row, got.Cohort)
+		}
+	}
+}
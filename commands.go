@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/question"
+	"example/goserver/user"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// runCommand runs a command-line tool instead of the server
+func runCommand(ctx context.Context, client *mongo.Client, args []string) error {
+	questionService, err := question.NewQuestionService(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	// Imports keep the stored data cubes up to date like the server does
+	engagementService, err := engagement.NewEngagementService(ctx, client)
+	if err != nil {
+		return err
+	}
+	dataCubeService := datacube.NewDataCubeService(client, questionService)
+	engagementService.OnStatusChange(dataCubeService.ApplyStatusChange)
+	questionService.OnQuestionChange(engagementService.ApplyQuestionChange)
+	questionService.OnQuestionChange(dataCubeService.ApplyQuestionChange)
+
+	switch args[0] {
+	case "import-questions":
+		flags := flag.NewFlagSet("import-questions", flag.ExitOnError)
+		format := flags.String("format", "", "jsonl, csv or qti (default: from the file name)")
+		dryRun := flags.Bool("dry-run", false, "validate without saving")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: import-questions [-format jsonl|csv|qti] [-dry-run] FILE")
+		}
+
+		data, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *format == "" {
+			*format = question.DetectFormat(flags.Arg(0))
+		}
+
+		rows, err := question.ParseImport(*format, data)
+		if err != nil {
+			return err
+		}
+		report, err := questionService.ImportQuestions(ctx, rows, *dryRun, nil)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+		if report.Failed > 0 {
+			return fmt.Errorf("%d of %d questions failed validation", report.Failed, report.Total)
+		}
+		return nil
+
+	case "export-questions":
+		flags := flag.NewFlagSet("export-questions", flag.ExitOnError)
+		format := flags.String("format", question.FormatJSONLines, "jsonl, csv or qti")
+		subject := flags.String("subject", "", "only export this subject")
+		topic := flags.String("topic", "", "only export this topic")
+		output := flags.String("o", "", "output file (default: standard output)")
+		flags.Parse(args[1:])
+
+		questions, err := questionService.FindQuestions(ctx, *subject, *topic)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		return question.ExportQuestions(w, *format, questions)
+
+	case "verify-datacubes":
+		flags := flag.NewFlagSet("verify-datacubes", flag.ExitOnError)
+		repair := flags.Bool("repair", false, "recompute the data cubes that differ")
+		flags.Parse(args[1:])
+
+		userIDs, err := dataCubeService.GetDataCubeUserIDs(ctx)
+		if err != nil {
+			return err
+		}
+
+		mismatched := 0
+		for _, userID := range userIDs {
+			differences, err := dataCubeService.VerifyDataCube(ctx, userID)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", userID.Hex(), err)
+			}
+			if len(differences) == 0 {
+				continue
+			}
+
+			mismatched++
+			for _, difference := range differences {
+				fmt.Printf("%s\t%s\t%s\t%s\tstored %g\tcomputed %g\n", userID.Hex(), difference.Row, difference.Cell, difference.Column, difference.Stored, difference.Computed)
+			}
+			if *repair {
+				if _, err := dataCubeService.ComputeDataCube(&userID); err != nil {
+					return fmt.Errorf("user %s: %w", userID.Hex(), err)
+				}
+			}
+		}
+
+		fmt.Printf("%d of %d data cubes differ from a full recompute\n", mismatched, len(userIDs))
+		if mismatched > 0 && !*repair {
+			return fmt.Errorf("run with -repair to recompute them")
+		}
+		return nil
+
+	case "make-super-admin":
+		// The role route needs a super admin, so the first one is made from the command line.
+		// The user registers as usual first.
+		if len(args) != 2 {
+			return fmt.Errorf("usage: make-super-admin EMAIL")
+		}
+
+		userService := user.NewUserService(client)
+		account, err := userService.GetUserByEmail(ctx, args[1])
+		if err == user.ErrUserNotFound {
+			return fmt.Errorf("no user is registered with the email %s", args[1])
+		} else if err != nil {
+			return err
+		}
+		if err := userService.SetUserRole(ctx, account.ID.Hex(), user.RoleSuperAdmin); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s is now a super admin\n", account.Email)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q (available: import-questions, export-questions, verify-datacubes, make-super-admin)", args[0])
+	}
+}
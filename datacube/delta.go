@@ -0,0 +1,237 @@
+package datacube
+
+import (
+	"context"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countCells are the cells that hold question counts. The other cells are ratios derived from them.
+var countCells = []string{"unattempted", "correct", "incorrect", "omitted", "total", "attempted"}
+
+// CellDifference is a count that differs between the stored data cube and a full recompute
+type CellDifference struct {
+	Row      string  `json:"Row"`
+	Cell     string  `json:"Cell"`
+	Column   string  `json:"Column"`
+	Stored   float64 `json:"Stored"`
+	Computed float64 `json:"Computed"`
+}
+
+// cubeRowsForTopic returns the rows a question in the topic is counted in: the subtopic, its parent topic,
+// the subject and the total. Only subtopics are counted, matching how the rows are summed in buildDataCube.
+func cubeRowsForTopic(topic string) []string {
+	path, ok := parameterdata.FindTopic(topic)
+	if !ok || !path.Subtopic {
+		return nil
+	}
+	return []string{topic, path.ParentTopic, path.Subject, "Total"}
+}
+
+// statusDelta returns the increments to the count cells for a question moving from one status to another
+func statusDelta(rows []string, oldStatus, newStatus, difficulty string) bson.M {
+	columns := []string{difficulty, "total"}
+	if difficulty == "hard" || difficulty == "extreme" {
+		columns = append(columns, "hardextreme")
+	}
+
+	changes := map[string]int{oldStatus: -1, newStatus: 1}
+	// attempted is the sum of correct, incorrect and omitted, so it only changes when a question is first answered
+	if oldStatus == engagement.StatusUnattempted {
+		changes["attempted"] = 1
+	} else if newStatus == engagement.StatusUnattempted {
+		changes["attempted"] = -1
+	}
+
+	inc := bson.M{}
+	for _, row := range rows {
+		for cell, change := range changes {
+			for _, column := range columns {
+				inc[fmt.Sprintf("rows.%s.cells.%s.values.%s", row, cell, column)] = change
+			}
+		}
+	}
+	return inc
+}
+
+// questionDelta returns the increments to the count cells for adding (change 1) or removing (change -1)
+// an unattempted question. The users' statuses on it are moved separately, as status changes.
+func questionDelta(rows []string, difficulty string, change int) bson.M {
+	columns := []string{difficulty, "total"}
+	if difficulty == "hard" || difficulty == "extreme" {
+		columns = append(columns, "hardextreme")
+	}
+
+	inc := bson.M{}
+	for _, row := range rows {
+		for _, cell := range []string{engagement.StatusUnattempted, "total"} {
+			for _, column := range columns {
+				inc[fmt.Sprintf("rows.%s.cells.%s.values.%s", row, cell, column)] = change
+			}
+		}
+	}
+	return inc
+}
+
+// countedRows returns the rows a question with the key is counted in, or nil if it is not counted
+func countedRows(key *engagement.QuestionKey) []string {
+	if key == nil || !key.Published || !contains(question.ValidDifficulties, key.Difficulty) {
+		return nil
+	}
+	return cubeRowsForTopic(key.Topic)
+}
+
+// ApplyQuestionChange updates every stored data cube for a question that was added, removed or moved
+// to another topic or difficulty. It is registered as a question change listener.
+// Question counts are not kept in the history, so trends are reconstructed against the current questions.
+func (s *DataCubeService) ApplyQuestionChange(ctx context.Context, change engagement.QuestionChange) error {
+	sides := []struct {
+		key    *engagement.QuestionKey
+		change int
+	}{{change.Old, -1}, {change.New, 1}}
+
+	// Rows shared by the old and new key, such as the total, cancel out
+	inc := bson.M{}
+	for _, side := range sides {
+		rows := countedRows(side.key)
+		if rows == nil {
+			continue
+		}
+		for field, value := range questionDelta(rows, side.key.Difficulty, side.change) {
+			total, _ := inc[field].(int)
+			inc[field] = total + value.(int)
+		}
+	}
+	if len(inc) == 0 {
+		return nil
+	}
+
+	_, err := s.collection.UpdateMany(ctx, bson.M{}, bson.M{"$inc": inc})
+	if err != nil {
+		return fmt.Errorf("error applying question change to data cubes: %w", err)
+	}
+	return nil
+}
+
+// ApplyStatusChange updates the stored data cube of the user for one engagement status change.
+// The counts are changed with a single atomic $inc, so concurrent answers cannot overwrite each other.
+// Users without a stored cube are skipped; theirs is computed in full the first time it is read.
+// The change is also recorded in the user's daily history.
+func (s *DataCubeService) ApplyStatusChange(ctx context.Context, change engagement.StatusChange) error {
+	if !change.Published || !contains(question.ValidDifficulties, change.Difficulty) {
+		return nil
+	}
+
+	rows := cubeRowsForTopic(change.Topic)
+	if rows == nil {
+		return nil
+	}
+
+	inc := statusDelta(rows, change.OldStatus, change.NewStatus, change.Difficulty)
+	_, err := s.collection.UpdateOne(ctx, bson.M{"user_id": change.UserID}, bson.M{"$inc": inc})
+	if err != nil {
+		return fmt.Errorf("error applying status change to data cube: %w", err)
+	}
+
+	// The same change is kept in the day's history so earlier versions of the cube can be reconstructed
+	return s.recordHistory(ctx, change, inc)
+}
+
+// VerifyDataCube compares the stored data cube of a user with a full recompute and returns the counts that differ
+func (s *DataCubeService) VerifyDataCube(ctx context.Context, userID primitive.ObjectID) ([]CellDifference, error) {
+	stored, err := s.GetDataCube(&userID)
+	if err != nil {
+		return nil, err
+	}
+	computed, err := s.buildDataCube(ctx, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	differences := []CellDifference{}
+	rowNames := map[string]bool{}
+	for name := range stored.Rows {
+		rowNames[name] = true
+	}
+	for name := range computed.Rows {
+		rowNames[name] = true
+	}
+
+	for rowName := range rowNames {
+		for _, cellName := range countCells {
+			storedCell := stored.Rows[rowName].Cells[cellName]
+			computedCell := computed.Rows[rowName].Cells[cellName]
+			for _, column := range trendColumns {
+				storedValue := CellValue(storedCell, column)
+				computedValue := CellValue(computedCell, column)
+				if math.Abs(storedValue-computedValue) > 1e-9 {
+					differences = append(differences, CellDifference{
+						Row: rowName, Cell: cellName, Column: column, Stored: storedValue, Computed: computedValue,
+					})
+				}
+			}
+		}
+	}
+
+	return differences, nil
+}
+
+// GetDataCubeUserIDs returns the users that have a stored data cube
+func (s *DataCubeService) GetDataCubeUserIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	values, err := s.collection.Distinct(ctx, "user_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if userID, ok := value.(primitive.ObjectID); ok {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+// ForEachDataCube calls fn with every stored data cube, with the ratios derived, until fn returns an error
+func (s *DataCubeService) ForEachDataCube(ctx context.Context, fn func(*DataCube) error) error {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("error getting data cubes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var dataCube DataCube
+		if err := cursor.Decode(&dataCube); err != nil {
+			return err
+		}
+		deriveCells(&dataCube)
+		if err := fn(&dataCube); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// CellValue returns the value of a cell in the given difficulty column, or 0 if it is not set
+func CellValue(cell Cell, column string) float64 {
+	if cell.Values == nil || cell.Values[column] == nil {
+		return 0
+	}
+	return *cell.Values[column]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package datacube
+
+import (
+	"example/goserver/engagement"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCubeRowsForTopic(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  []string
+	}{
+		{"Linear functions", []string{"Linear functions", "Algebra", "Math", "Total"}},
+		{"Nonlinear functions", []string{"Nonlinear functions", "Advanced math", "Math", "Total"}},
+		{"Algebra", nil},
+		{"Unknown", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := cubeRowsForTopic(tt.topic); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cubeRowsForTopic(%q) = %v, want %v", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusDelta(t *testing.T) {
+	rows := []string{"Linear functions", "Total"}
+
+	tests := []struct {
+		name       string
+		oldStatus  string
+		newStatus  string
+		difficulty string
+		want       bson.M
+	}{
+		{
+			name:      "first answer",
+			oldStatus: engagement.StatusUnattempted, newStatus: engagement.StatusCorrect, difficulty: "easy",
+			want: bson.M{
+				"rows.Linear functions.cells.unattempted.values.easy":  -1,
+				"rows.Linear functions.cells.unattempted.values.total": -1,
+				"rows.Linear functions.cells.correct.values.easy":      1,
+				"rows.Linear functions.cells.correct.values.total":     1,
+				"rows.Linear functions.cells.attempted.values.easy":    1,
+				"rows.Linear functions.cells.attempted.values.total":   1,
+				"rows.Total.cells.unattempted.values.easy":             -1,
+				"rows.Total.cells.unattempted.values.total":            -1,
+				"rows.Total.cells.correct.values.easy":                 1,
+				"rows.Total.cells.correct.values.total":                1,
+				"rows.Total.cells.attempted.values.easy":               1,
+				"rows.Total.cells.attempted.values.total":              1,
+			},
+		},
+		{
+			name:      "answer changed",
+			oldStatus: engagement.StatusCorrect, newStatus: engagement.StatusIncorrect, difficulty: "medium",
+			want: bson.M{
+				"rows.Linear functions.cells.correct.values.medium":   -1,
+				"rows.Linear functions.cells.correct.values.total":    -1,
+				"rows.Linear functions.cells.incorrect.values.medium": 1,
+				"rows.Linear functions.cells.incorrect.values.total":  1,
+				"rows.Total.cells.correct.values.medium":              -1,
+				"rows.Total.cells.correct.values.total":               -1,
+				"rows.Total.cells.incorrect.values.medium":            1,
+				"rows.Total.cells.incorrect.values.total":             1,
+			},
+		},
+		{
+			name:      "hard questions count in hardextreme",
+			oldStatus: engagement.StatusOmitted, newStatus: engagement.StatusUnattempted, difficulty: "hard",
+			want: bson.M{
+				"rows.Linear functions.cells.omitted.values.hard":            -1,
+				"rows.Linear functions.cells.omitted.values.total":           -1,
+				"rows.Linear functions.cells.omitted.values.hardextreme":     -1,
+				"rows.Linear functions.cells.unattempted.values.hard":        1,
+				"rows.Linear functions.cells.unattempted.values.total":       1,
+				"rows.Linear functions.cells.unattempted.values.hardextreme": 1,
+				"rows.Linear functions.cells.attempted.values.hard":          -1,
+				"rows.Linear functions.cells.attempted.values.total":         -1,
+				"rows.Linear functions.cells.attempted.values.hardextreme":   -1,
+				"rows.Total.cells.omitted.values.hard":                       -1,
+				"rows.Total.cells.omitted.values.total":                      -1,
+				"rows.Total.cells.omitted.values.hardextreme":                -1,
+				"rows.Total.cells.unattempted.values.hard":                   1,
+				"rows.Total.cells.unattempted.values.total":                  1,
+				"rows.Total.cells.unattempted.values.hardextreme":            1,
+				"rows.Total.cells.attempted.values.hard":                     -1,
+				"rows.Total.cells.attempted.values.total":                    -1,
+				"rows.Total.cells.attempted.values.hardextreme":              -1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statusDelta(rows, tt.oldStatus, tt.newStatus, tt.difficulty)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statusDelta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuestionDelta(t *testing.T) {
+	got := questionDelta([]string{"Total"}, "extreme", -1)
+	want := bson.M{
+		"rows.Total.cells.unattempted.values.extreme":     -1,
+		"rows.Total.cells.unattempted.values.total":       -1,
+		"rows.Total.cells.unattempted.values.hardextreme": -1,
+		"rows.Total.cells.total.values.extreme":           -1,
+		"rows.Total.cells.total.values.total":             -1,
+		"rows.Total.cells.total.values.hardextreme":       -1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("questionDelta() = %v, want %v", got, want)
+	}
+}
+
+func TestCountedRows(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *engagement.QuestionKey
+		want bool
+	}{
+		{"published", &engagement.QuestionKey{Topic: "Linear functions", Difficulty: "easy", Published: true}, true},
+		{"draft", &engagement.QuestionKey{Topic: "Linear functions", Difficulty: "easy"}, false},
+		{"unknown difficulty", &engagement.QuestionKey{Topic: "Linear functions", Difficulty: "trivial", Published: true}, false},
+		{"parent topic", &engagement.QuestionKey{Topic: "Algebra", Difficulty: "easy", Published: true}, false},
+		{"no question", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countedRows(tt.key) != nil; got != tt.want {
+				t.Errorf("countedRows() counted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
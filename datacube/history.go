@@ -0,0 +1,224 @@
+package datacube
+
+import (
+	"context"
+	"errors"
+	"example/goserver/engagement"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Trend intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// MaxTrendPeriods limits how far back a trend can go
+const MaxTrendPeriods = 120
+
+// trendColumns are the difficulty columns of every cell
+var trendColumns = []string{"easy", "medium", "hard", "extreme", "hardextreme", "total"}
+
+// trendCells are the cells a trend can be requested for
+var trendCells = []string{"unattempted", "correct", "incorrect", "omitted", "total", "attempted", "usage", "accuracy"}
+
+var ErrInvalidTrend = errors.New("invalid trend request")
+
+// recordHistory adds a status change to the user's history bucket for the day the answer was given
+func (s *DataCubeService) recordHistory(ctx context.Context, change engagement.StatusChange, inc bson.M) error {
+	filter := bson.M{"user_id": change.UserID, "day": startOfDay(change.Time)}
+	_, err := s.historyCollection.UpdateOne(ctx, filter, bson.M{"$inc": inc}, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error recording data cube history: %w", err)
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// periodStart returns the start of the day, week (starting on Monday) or month containing t
+func periodStart(t time.Time, interval string) time.Time {
+	day := startOfDay(t)
+	switch interval {
+	case IntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case IntervalMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextPeriod returns the start of the period after the one starting at start
+func nextPeriod(start time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// GetTrend returns the value of the row's cells at the end of each of the last periods, oldest first.
+// The current period ends now. Cell and column are optional and limit the series returned.
+// Earlier values are reconstructed by taking the recorded daily changes back off the current cube.
+func (s *DataCubeService) GetTrend(ctx context.Context, userID primitive.ObjectID, row, cell, column, interval string, periods int) (*Trend, error) {
+	if interval != IntervalDay && interval != IntervalWeek && interval != IntervalMonth {
+		return nil, fmt.Errorf("%w: interval must be day, week or month", ErrInvalidTrend)
+	}
+	if periods < 1 || periods > MaxTrendPeriods {
+		return nil, fmt.Errorf("%w: periods must be between 1 and %d", ErrInvalidTrend, MaxTrendPeriods)
+	}
+	cells := trendCells
+	if cell != "" {
+		if !contains(trendCells, cell) {
+			return nil, fmt.Errorf("%w: unknown cell %s", ErrInvalidTrend, cell)
+		}
+		cells = []string{cell}
+	}
+	columns := trendColumns
+	if column != "" {
+		if !contains(trendColumns, column) {
+			return nil, fmt.Errorf("%w: unknown column %s", ErrInvalidTrend, column)
+		}
+		columns = []string{column}
+	}
+
+	current, _ := s.GetDataCube(&userID)
+	if current == nil {
+		var err error
+		current, err = s.ComputeDataCube(&userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	starts := periodStarts(time.Now(), interval, periods)
+
+	cursor, err := s.historyCollection.Find(ctx, bson.M{"user_id": userID, "day": bson.M{"$gte": starts[0]}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []HistoryBucket
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+
+	snapshots := trendSnapshots(current, buckets, starts)
+
+	trend := &Trend{Row: row, Interval: interval, Series: []TrendSeries{}}
+	for _, cellName := range cells {
+		for _, columnName := range columns {
+			series := TrendSeries{Cell: cellName, Column: columnName, Points: make([]TrendPoint, periods)}
+			for i, snapshot := range snapshots {
+				series.Points[i] = TrendPoint{
+					Start: starts[i],
+					End:   starts[i+1],
+					Value: CellValue(snapshot.Rows[row].Cells[cellName], columnName),
+				}
+			}
+			trend.Series = append(trend.Series, series)
+		}
+	}
+
+	return trend, nil
+}
+
+// periodStarts returns the boundaries of the last periods ending at now, oldest first.
+// Period i runs from starts[i] to starts[i+1], and the last one ends at now.
+func periodStarts(now time.Time, interval string, periods int) []time.Time {
+	now = now.UTC()
+	starts := make([]time.Time, periods+1)
+	starts[periods-1] = periodStart(now, interval)
+	starts[periods] = now
+	for i := periods - 2; i >= 0; i-- {
+		previous := starts[i+1]
+		starts[i] = periodStart(previous.AddDate(0, 0, -1), interval)
+	}
+	return starts
+}
+
+// trendSnapshots returns the data cube as it stood at the end of each period.
+// It walks back from the newest period, taking each period's changes off the running counts.
+func trendSnapshots(current *DataCube, buckets []HistoryBucket, starts []time.Time) []*DataCube {
+	periods := len(starts) - 1
+	running := cloneCounts(current)
+	snapshots := make([]*DataCube, periods)
+	for i := periods - 1; i >= 0; i-- {
+		snapshot := cloneCounts(running)
+		deriveCells(snapshot)
+		snapshots[i] = snapshot
+
+		for _, bucket := range buckets {
+			if !bucket.Day.Before(starts[i]) && bucket.Day.Before(starts[i+1]) {
+				subtractRows(running, bucket.Rows)
+			}
+		}
+	}
+	return snapshots
+}
+
+// cloneCounts copies the count cells of a data cube, leaving out the derived ratios
+func cloneCounts(dataCube *DataCube) *DataCube {
+	clone := &DataCube{UserID: dataCube.UserID, Rows: make(map[string]Row)}
+	for rowName, row := range dataCube.Rows {
+		clonedRow := Row{Cells: make(map[string]Cell)}
+		for _, cellName := range countCells {
+			cell, ok := row.Cells[cellName]
+			if !ok {
+				continue
+			}
+			clonedCell := Cell{Values: make(map[string]*float64)}
+			for column, value := range cell.Values {
+				if value != nil {
+					v := *value
+					clonedCell.Values[column] = &v
+				}
+			}
+			clonedRow.Cells[cellName] = clonedCell
+		}
+		clone.Rows[rowName] = clonedRow
+	}
+	return clone
+}
+
+// subtractRows takes the changes in a history bucket off the counts of a data cube
+func subtractRows(dataCube *DataCube, changes map[string]Row) {
+	for rowName, changeRow := range changes {
+		row, ok := dataCube.Rows[rowName]
+		if !ok || row.Cells == nil {
+			row = Row{Cells: make(map[string]Cell)}
+			dataCube.Rows[rowName] = row
+		}
+		for cellName, changeCell := range changeRow.Cells {
+			cell, ok := row.Cells[cellName]
+			if !ok || cell.Values == nil {
+				cell = Cell{Values: make(map[string]*float64)}
+				row.Cells[cellName] = cell
+			}
+			for column, change := range changeCell.Values {
+				if change == nil {
+					continue
+				}
+				if cell.Values[column] == nil {
+					cell.Values[column] = new(float64)
+				}
+				*cell.Values[column] -= *change
+			}
+		}
+	}
+}
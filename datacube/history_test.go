@@ -0,0 +1,172 @@
+package datacube
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriodStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        time.Time
+		interval string
+		want     time.Time
+	}{
+		{"day", time.Date(2024, 3, 6, 15, 30, 0, 0, time.UTC), IntervalDay, day(2024, 3, 6)},
+		{"day in UTC", time.Date(2024, 3, 6, 1, 0, 0, 0, time.FixedZone("UTC+5", 5*3600)), IntervalDay, day(2024, 3, 5)},
+		{"week from Wednesday", time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC), IntervalWeek, day(2024, 3, 4)},
+		{"week from Monday", day(2024, 3, 4), IntervalWeek, day(2024, 3, 4)},
+		{"week from Sunday", time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC), IntervalWeek, day(2024, 3, 4)},
+		{"week across months", day(2024, 3, 2), IntervalWeek, day(2024, 2, 26)},
+		{"month", time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC), IntervalMonth, day(2024, 3, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodStart(tt.t, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("periodStart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPeriod(t *testing.T) {
+	tests := []struct {
+		start    time.Time
+		interval string
+		want     time.Time
+	}{
+		{day(2024, 2, 28), IntervalDay, day(2024, 2, 29)},
+		{day(2024, 2, 26), IntervalWeek, day(2024, 3, 4)},
+		{day(2023, 12, 1), IntervalMonth, day(2024, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		if got := nextPeriod(tt.start, tt.interval); !got.Equal(tt.want) {
+			t.Errorf("nextPeriod(%v, %s) = %v, want %v", tt.start, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodStarts(t *testing.T) {
+	now := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval string
+		periods  int
+		want     []time.Time
+	}{
+		{"days", now, IntervalDay, 2, []time.Time{day(2024, 3, 5), day(2024, 3, 6), now}},
+		{"weeks", now, IntervalWeek, 3, []time.Time{day(2024, 2, 19), day(2024, 2, 26), day(2024, 3, 4), now}},
+		{"months across a year", time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), IntervalMonth, 3, []time.Time{day(2023, 11, 1), day(2023, 12, 1), day(2024, 1, 1), time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)}},
+		{"one period", now, IntervalMonth, 1, []time.Time{day(2024, 3, 1), now}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodStarts(tt.now, tt.interval, tt.periods); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("periodStarts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// countRow builds a row with the total column of the given cells set
+func countRow(totals map[string]float64) Row {
+	row := Row{Cells: make(map[string]Cell)}
+	for cellName, value := range totals {
+		value := value
+		row.Cells[cellName] = Cell{Values: map[string]*float64{"total": &value}}
+	}
+	return row
+}
+
+func TestCloneCounts(t *testing.T) {
+	original := &DataCube{Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 3, "accuracy": 0.5})}}
+	original.Rows["Total"].Cells["attempted"] = Cell{Values: map[string]*float64{"total": nil}}
+
+	clone := cloneCounts(original)
+	if _, ok := clone.Rows["Total"].Cells["accuracy"]; ok {
+		t.Error("clone kept the derived accuracy cell")
+	}
+	if _, ok := clone.Rows["Total"].Cells["attempted"].Values["total"]; ok {
+		t.Error("clone kept a nil value")
+	}
+
+	*clone.Rows["Total"].Cells["correct"].Values["total"] = 10
+	if got := CellValue(original.Rows["Total"].Cells["correct"], "total"); got != 3 {
+		t.Errorf("changing the clone changed the original to %g", got)
+	}
+}
+
+func TestSubtractRows(t *testing.T) {
+	dataCube := &DataCube{Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 5, "attempted": 6})}}
+
+	subtractRows(dataCube, map[string]Row{
+		"Total":     countRow(map[string]float64{"correct": 2, "incorrect": 1}),
+		"Circles":   countRow(map[string]float64{"correct": 1}),
+		"Skipped":   {},
+		"Untouched": {Cells: map[string]Cell{"correct": {Values: map[string]*float64{"total": nil}}}},
+	})
+
+	tests := []struct {
+		row, cell string
+		want      float64
+	}{
+		{"Total", "correct", 3},
+		{"Total", "attempted", 6},
+		{"Total", "incorrect", -1},
+		{"Circles", "correct", -1},
+		{"Untouched", "correct", 0},
+	}
+	for _, tt := range tests {
+		if got := CellValue(dataCube.Rows[tt.row].Cells[tt.cell], "total"); got != tt.want {
+			t.Errorf("%s %s = %g, want %g", tt.row, tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestTrendSnapshots(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	starts := []time.Time{day(2024, 3, 4), day(2024, 3, 5), day(2024, 3, 6), now}
+
+	current := &DataCube{Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 5, "attempted": 6})}}
+	buckets := []HistoryBucket{
+		{Day: day(2024, 3, 6), Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 2, "attempted": 2})}},
+		{Day: day(2024, 3, 4), Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 1, "attempted": 3})}},
+		{Day: day(2024, 3, 1), Rows: map[string]Row{"Total": countRow(map[string]float64{"correct": 1, "attempted": 1})}},
+	}
+
+	snapshots := trendSnapshots(current, buckets, starts)
+	if len(snapshots) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snapshots))
+	}
+
+	// Each snapshot is the cube at the end of its period
+	want := []struct{ correct, attempted, accuracy float64 }{
+		{3, 4, 0.75},
+		{3, 4, 0.75},
+		{5, 6, 5.0 / 6},
+	}
+	for i, w := range want {
+		cells := snapshots[i].Rows["Total"].Cells
+		correct := CellValue(cells["correct"], "total")
+		attempted := CellValue(cells["attempted"], "total")
+		accuracy := CellValue(cells["accuracy"], "total")
+		if correct != w.correct || attempted != w.attempted || math.Abs(accuracy-w.accuracy) > 1e-9 {
+			t.Errorf("snapshot %d = correct %g attempted %g accuracy %g, want %+v", i, correct, attempted, accuracy, w)
+		}
+	}
+
+	if got := CellValue(current.Rows["Total"].Cells["correct"], "total"); got != 5 {
+		t.Errorf("current cube changed to %g correct", got)
+	}
+}
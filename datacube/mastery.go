@@ -0,0 +1,307 @@
+package datacube
+
+import (
+	"context"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// priorDifficulty places the authored difficulty labels on the Rasch logit scale.
+// They are used for questions that few users have answered yet. The labels are one logit apart, so a user
+// who answers half of the medium questions correctly is expected to answer 73% of easy and 27% of hard ones.
+var priorDifficulty = map[string]float64{
+	"easy":    -1,
+	"medium":  0,
+	"hard":    1,
+	"extreme": 2,
+}
+
+// priorWeight is how many responses the authored difficulty is worth when calibrating a question.
+// After ten answers the observed difficulty and the label count equally.
+const priorWeight = 10.0
+
+// smoothingCount is added to both the correct and the incorrect answers of a question when it is calibrated,
+// so a question that everyone answers the same way still gets a finite difficulty
+const smoothingCount = 0.5
+
+const (
+	// maxAbilityIterations bounds the Newton steps of an ability estimate; it usually converges in under ten
+	maxAbilityIterations = 50
+	// abilityTolerance is the step size, in logits, at which an ability estimate has converged
+	abilityTolerance = 1e-6
+)
+
+// QuestionCalibration is the Rasch difficulty of a question estimated from every user's answers
+type QuestionCalibration struct {
+	QuestionID   primitive.ObjectID `json:"QuestionID" bson:"question_id"`
+	Difficulty   float64            `json:"Difficulty" bson:"difficulty"`
+	Responses    int                `json:"Responses" bson:"responses"`
+	Correct      int                `json:"Correct" bson:"correct"`
+	CalibratedAt time.Time          `json:"CalibratedAt" bson:"calibrated_at"`
+}
+
+// TopicMastery is a user's estimated ability in one topic
+type TopicMastery struct {
+	Ability       float64 `json:"Ability" bson:"ability"`              // logit scale
+	StandardError float64 `json:"StandardError" bson:"standard_error"` // of the ability
+	Mastery       float64 `json:"Mastery" bson:"mastery"`              // chance of answering a medium question correctly, 0-100
+	Responses     int     `json:"Responses" bson:"responses"`
+}
+
+// Mastery holds a user's ability estimates for every topic, stored next to their data cube
+type Mastery struct {
+	UserID     primitive.ObjectID      `json:"UserID" bson:"user_id"`
+	Topics     map[string]TopicMastery `json:"Topics" bson:"topics"`
+	ComputedAt time.Time               `json:"ComputedAt" bson:"computed_at"`
+}
+
+// response is one graded answer used for estimation
+type response struct {
+	QuestionID primitive.ObjectID `bson:"question_id"`
+	Status     string             `bson:"status"`
+	Topic      string             `bson:"topic"`
+	Difficulty string             `bson:"difficulty"`
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// getResponses returns graded answers joined with the topic and difficulty of their question.
+// A nil userID returns the answers of every user.
+func (s *DataCubeService) getResponses(ctx context.Context, userID *primitive.ObjectID) ([]response, error) {
+	match := bson.M{"status": bson.M{"$in": []string{"correct", "incorrect", "omitted"}}}
+	if userID != nil {
+		match["user_id"] = userID
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$lookup": bson.M{
+			"from":         "questions",
+			"localField":   "question_id",
+			"foreignField": "_id",
+			"as":           "question",
+		}},
+		{"$unwind": "$question"},
+		{"$match": bson.M{"question.state": question.PublishedFilter()}},
+		{"$project": bson.M{
+			"question_id": 1,
+			"status":      1,
+			"topic":       "$question.topic",
+			"difficulty":  "$question.difficulty",
+		}},
+	}
+
+	cursor, err := s.engagementCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error getting responses: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var responses []response
+	if err = cursor.All(ctx, &responses); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// CalibrateQuestions re-estimates the difficulty of every answered question from all users' engagements.
+// Each difficulty is the negative logit of the smoothed proportion correct, shrunk towards the authored label.
+func (s *DataCubeService) CalibrateQuestions(ctx context.Context) (int, error) {
+	responses, err := s.getResponses(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	calibrations := make(map[primitive.ObjectID]*QuestionCalibration)
+	labels := make(map[primitive.ObjectID]string)
+	for _, r := range responses {
+		calibration, ok := calibrations[r.QuestionID]
+		if !ok {
+			calibration = &QuestionCalibration{QuestionID: r.QuestionID}
+			calibrations[r.QuestionID] = calibration
+			labels[r.QuestionID] = r.Difficulty
+		}
+		calibration.Responses++
+		if r.Status == "correct" {
+			calibration.Correct++
+		}
+	}
+
+	now := time.Now()
+	for questionID, calibration := range calibrations {
+		calibration.Difficulty = calibratedDifficulty(calibration.Correct, calibration.Responses, labels[questionID])
+		calibration.CalibratedAt = now
+
+		_, err := s.calibrationCollection.ReplaceOne(ctx, bson.M{"question_id": questionID}, calibration, options.Replace().SetUpsert(true))
+		if err != nil {
+			return 0, fmt.Errorf("error saving calibration: %w", err)
+		}
+	}
+
+	return len(calibrations), nil
+}
+
+// calibratedDifficulty is the negative logit of a question's smoothed proportion correct,
+// averaged with its authored difficulty as if that were priorWeight more responses
+func calibratedDifficulty(correct, responses int, label string) float64 {
+	proportion := (float64(correct) + smoothingCount) / (float64(responses) + 2*smoothingCount)
+	observed := -math.Log(proportion / (1 - proportion))
+
+	n := float64(responses)
+	return (n*observed + priorWeight*priorDifficulty[label]) / (n + priorWeight)
+}
+
+// getCalibrations returns the calibrated difficulty of the given questions
+func (s *DataCubeService) getCalibrations(ctx context.Context, questionIDs []primitive.ObjectID) (map[primitive.ObjectID]float64, error) {
+	cursor, err := s.calibrationCollection.Find(ctx, bson.M{"question_id": bson.M{"$in": questionIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var calibrations []QuestionCalibration
+	if err = cursor.All(ctx, &calibrations); err != nil {
+		return nil, err
+	}
+
+	difficulties := make(map[primitive.ObjectID]float64)
+	for _, calibration := range calibrations {
+		difficulties[calibration.QuestionID] = calibration.Difficulty
+	}
+
+	return difficulties, nil
+}
+
+// estimateAbility finds the maximum a posteriori Rasch ability for a set of answers,
+// with a standard normal prior so a handful of answers cannot give an infinite estimate.
+// It returns the ability and its standard error; with no answers that is the prior, 0 and 1.
+func estimateAbility(correct []bool, difficulties []float64) (float64, float64) {
+	theta := 0.0
+	information := 1.0
+
+	for iteration := 0; iteration < maxAbilityIterations; iteration++ {
+		// The prior contributes -theta to the gradient and 1 to the information
+		gradient := -theta
+		information = 1.0
+		for i, difficulty := range difficulties {
+			p := logistic(theta - difficulty)
+			if correct[i] {
+				gradient += 1 - p
+			} else {
+				gradient -= p
+			}
+			information += p * (1 - p)
+		}
+
+		step := gradient / information
+		theta += step
+		if math.Abs(step) < abilityTolerance {
+			break
+		}
+	}
+
+	return theta, 1 / math.Sqrt(information)
+}
+
+func newTopicMastery(correct []bool, difficulties []float64) TopicMastery {
+	ability, standardError := estimateAbility(correct, difficulties)
+	return TopicMastery{
+		Ability:       ability,
+		StandardError: standardError,
+		Mastery:       logistic(ability-priorDifficulty["medium"]) * 100,
+		Responses:     len(correct),
+	}
+}
+
+// ComputeMastery estimates the user's ability in every topic and parent topic and stores it
+func (s *DataCubeService) ComputeMastery(ctx context.Context, userIDObj *primitive.ObjectID) (*Mastery, error) {
+	responses, err := s.getResponses(ctx, userIDObj)
+	if err != nil {
+		return nil, err
+	}
+
+	questionIDs := make([]primitive.ObjectID, 0, len(responses))
+	for _, r := range responses {
+		questionIDs = append(questionIDs, r.QuestionID)
+	}
+
+	calibrated, err := s.getCalibrations(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	correctByTopic := make(map[string][]bool)
+	difficultiesByTopic := make(map[string][]float64)
+	for _, r := range responses {
+		difficulty, ok := calibrated[r.QuestionID]
+		if !ok {
+			difficulty = priorDifficulty[r.Difficulty]
+		}
+		correctByTopic[r.Topic] = append(correctByTopic[r.Topic], r.Status == "correct")
+		difficultiesByTopic[r.Topic] = append(difficultiesByTopic[r.Topic], difficulty)
+	}
+
+	mastery := &Mastery{
+		UserID:     *userIDObj,
+		Topics:     make(map[string]TopicMastery),
+		ComputedAt: time.Now(),
+	}
+
+	// Parent topics and subjects are estimated from the answers of all their subtopics
+	for subject, topics := range map[string][]*parameterdata.Topic{"Math": parameterdata.MathTopicsList, "Reading": parameterdata.ReadingTopicsList} {
+		var subjectCorrect []bool
+		var subjectDifficulties []float64
+
+		for _, topic := range topics {
+			var topicCorrect []bool
+			var topicDifficulties []float64
+
+			for _, subtopic := range topic.Children {
+				correct := correctByTopic[subtopic.Name]
+				difficulties := difficultiesByTopic[subtopic.Name]
+				mastery.Topics[subtopic.Name] = newTopicMastery(correct, difficulties)
+
+				topicCorrect = append(topicCorrect, correct...)
+				topicDifficulties = append(topicDifficulties, difficulties...)
+			}
+
+			mastery.Topics[topic.Name] = newTopicMastery(topicCorrect, topicDifficulties)
+			subjectCorrect = append(subjectCorrect, topicCorrect...)
+			subjectDifficulties = append(subjectDifficulties, topicDifficulties...)
+		}
+
+		mastery.Topics[subject] = newTopicMastery(subjectCorrect, subjectDifficulties)
+	}
+
+	_, err = s.masteryCollection.ReplaceOne(ctx, bson.M{"user_id": *userIDObj}, mastery, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, fmt.Errorf("error updating mastery: %w", err)
+	}
+
+	return mastery, nil
+}
+
+// GetMastery returns the stored mastery for a user, or nil if it has not been computed yet
+func (s *DataCubeService) GetMastery(ctx context.Context, userIDObj *primitive.ObjectID) (*Mastery, error) {
+	var mastery Mastery
+	err := s.masteryCollection.FindOne(ctx, bson.M{"user_id": userIDObj}).Decode(&mastery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting mastery for user: %w", err)
+	}
+
+	return &mastery, nil
+}
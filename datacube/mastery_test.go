@@ -0,0 +1,120 @@
+package datacube
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibratedDifficulty(t *testing.T) {
+	// -log(21) is the logit of answering 10.5 of 11 smoothed responses incorrectly
+	allCorrect := -math.Log(21) * 10 / 20
+
+	tests := []struct {
+		name               string
+		correct, responses int
+		label              string
+		want               float64
+	}{
+		{"no responses is the label", 0, 0, "hard", 1},
+		{"unknown label starts at medium", 0, 0, "", 0},
+		{"all correct", 10, 10, "medium", allCorrect},
+		{"all wrong", 0, 10, "medium", -allCorrect},
+		{"half correct moves halfway to zero", 5, 10, "easy", -0.5},
+		{"many responses outweigh the label", 500, 1000, "extreme", 2 * priorWeight / (1000 + priorWeight)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calibratedDifficulty(tt.correct, tt.responses, tt.label); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("calibratedDifficulty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func repeat(answer bool, difficulty float64, n int) ([]bool, []float64) {
+	correct := make([]bool, n)
+	difficulties := make([]float64, n)
+	for i := range correct {
+		correct[i] = answer
+		difficulties[i] = difficulty
+	}
+	return correct, difficulties
+}
+
+func TestEstimateAbility(t *testing.T) {
+	allRight, medium := repeat(true, 0, 5)
+	allWrong, _ := repeat(false, 0, 5)
+	fewRight, _ := repeat(true, 0, 2)
+	hardRight, hard := repeat(true, 1, 5)
+
+	tests := []struct {
+		name         string
+		correct      []bool
+		difficulties []float64
+		wantAbility  func(float64) bool
+		wantSE       func(float64) bool
+	}{
+		{"no attempts is the prior", nil, nil,
+			func(a float64) bool { return a == 0 },
+			func(se float64) bool { return se == 1 }},
+		{"all correct is finite", allRight, medium,
+			func(a float64) bool { return a > 0 && a < 3 },
+			func(se float64) bool { return se < 1 }},
+		{"all wrong mirrors all correct", allWrong, medium,
+			func(a float64) bool { return a < 0 && a > -3 },
+			func(se float64) bool { return se < 1 }},
+		{"half correct", []bool{true, false, true, false}, []float64{0, 0, 0, 0},
+			func(a float64) bool { return math.Abs(a) < 1e-6 },
+			func(se float64) bool { return se < 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ability, se := estimateAbility(tt.correct, tt.difficulties)
+			if !tt.wantAbility(ability) || !tt.wantSE(se) {
+				t.Errorf("estimateAbility() = %v, %v", ability, se)
+			}
+		})
+	}
+
+	t.Run("steps", func(t *testing.T) {
+		right, _ := estimateAbility(allRight, medium)
+		wrong, _ := estimateAbility(allWrong, medium)
+		if math.Abs(right+wrong) > 1e-6 {
+			t.Errorf("all correct %v and all wrong %v are not symmetric", right, wrong)
+		}
+		if few, _ := estimateAbility(fewRight, medium[:2]); few >= right {
+			t.Errorf("2 correct answers gave %v, want less than 5 gave, %v", few, right)
+		}
+		if harder, _ := estimateAbility(hardRight, hard); harder <= right {
+			t.Errorf("correct hard answers gave %v, want more than medium ones gave, %v", harder, right)
+		}
+	})
+}
+
+func TestNewTopicMastery(t *testing.T) {
+	tests := []struct {
+		name        string
+		correct     []bool
+		difficulty  float64
+		wantMastery func(float64) bool
+	}{
+		{"no attempts", nil, 0, func(m float64) bool { return m == 50 }},
+		{"all correct", []bool{true, true, true}, 0, func(m float64) bool { return m > 50 && m < 100 }},
+		{"all wrong", []bool{false, false, false}, 0, func(m float64) bool { return m > 0 && m < 50 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			difficulties := make([]float64, len(tt.correct))
+			for i := range difficulties {
+				difficulties[i] = tt.difficulty
+			}
+			mastery := newTopicMastery(tt.correct, difficulties)
+			if !tt.wantMastery(mastery.Mastery) || mastery.Responses != len(tt.correct) {
+				t.Errorf("newTopicMastery() = %+v", mastery)
+			}
+		})
+	}
+}
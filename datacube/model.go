@@ -1,6 +1,10 @@
 package datacube
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Cell struct {
 	Values map[string]*float64
@@ -15,3 +19,32 @@ type DataCube struct {
 type Row struct {
 	Cells map[string]Cell
 }
+
+// HistoryBucket holds the changes to a user's data cube counts during one day.
+// Rows has the same layout as DataCube.Rows, with each value being the net change.
+type HistoryBucket struct {
+	UserID primitive.ObjectID `json:"UserID" bson:"user_id"`
+	Day    time.Time          `json:"Day" bson:"day"`
+	Rows   map[string]Row
+}
+
+// TrendPoint is the value of a cell at the end of one period
+type TrendPoint struct {
+	Start time.Time `json:"Start"`
+	End   time.Time `json:"End"`
+	Value float64   `json:"Value"`
+}
+
+// TrendSeries is the history of one cell and difficulty column of a data cube row
+type TrendSeries struct {
+	Cell   string       `json:"Cell"`
+	Column string       `json:"Column"`
+	Points []TrendPoint `json:"Points"`
+}
+
+// Trend is the history of a data cube row over a number of days, weeks or months
+type Trend struct {
+	Row      string        `json:"Row"`
+	Interval string        `json:"Interval"`
+	Series   []TrendSeries `json:"Series"`
+}
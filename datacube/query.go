@@ -0,0 +1,413 @@
+package datacube
+
+import (
+	"context"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Query dimensions
+const (
+	DimensionSubject     = "subject"
+	DimensionParentTopic = "parentTopic"
+	DimensionTopic       = "topic"
+	DimensionDifficulty  = "difficulty"
+	DimensionStatus      = "status"
+	DimensionMode        = "mode"
+)
+
+// Dimensions are the dimensions a query can group by and filter on
+var Dimensions = []string{DimensionSubject, DimensionParentTopic, DimensionTopic, DimensionDifficulty, DimensionStatus, DimensionMode}
+
+// Measures are the values calculated for each group. Completion is the share of questions attempted.
+var Measures = []string{"total", "unattempted", "attempted", "correct", "incorrect", "omitted", "accuracy", "completion"}
+
+// topicHierarchy lists the dimensions of the topic hierarchy from the finest to the coarsest
+var topicHierarchy = []string{DimensionTopic, DimensionParentTopic, DimensionSubject}
+
+// statuses are the statuses a question can have for a user
+var statuses = []string{engagement.StatusUnattempted, engagement.StatusCorrect, engagement.StatusIncorrect, engagement.StatusOmitted}
+
+// Query selects and aggregates a user's questions along the data cube dimensions
+type Query struct {
+	// GroupBy lists the dimensions to break the results down by
+	GroupBy []string
+	// Filters limits each dimension to the given values
+	Filters map[string][]string
+	// From and To limit the answers counted to those given in the date range. Each question counts with
+	// the last answer given in the range, and questions with no answer in the range count as unattempted.
+	From *time.Time
+	To   *time.Time
+	// Rollup adds subtotal rows along the topic hierarchy: parent topic, subject and a grand total
+	Rollup bool
+	// Measures limits the measures returned. All measures are returned if it is empty.
+	Measures []string
+}
+
+// QueryRow is one group in the result of a query
+type QueryRow struct {
+	Dimensions map[string]string  `json:"Dimensions"`
+	Rollup     bool               `json:"Rollup,omitempty"`
+	Measures   map[string]float64 `json:"Measures"`
+}
+
+// fact is one question joined with the user's engagement with it
+type fact struct {
+	Topic      string          `bson:"topic"`
+	Difficulty string          `bson:"difficulty"`
+	Engagement *factEngagement `bson:"engagement"`
+}
+
+// factEngagement is the part of an engagement a query needs
+type factEngagement struct {
+	Status      *string              `bson:"status"`
+	Mode        *string              `bson:"mode"`
+	AttemptTime time.Time            `bson:"attempt_time"`
+	Attempts    []engagement.Attempt `bson:"attempts"`
+}
+
+// lastAttemptIn returns the status and mode of the last answer given in the date range of a query
+func (e *factEngagement) lastAttemptIn(query Query) (string, string, bool) {
+	attempts := e.Attempts
+	// Engagements logged before attempts were recorded only have their latest answer
+	if len(attempts) == 0 {
+		attempts = []engagement.Attempt{{Status: e.Status, Mode: e.Mode, AttemptTime: e.AttemptTime}}
+	}
+
+	for i := len(attempts) - 1; i >= 0; i-- {
+		attempt := attempts[i]
+		if attempt.Status == nil ||
+			(query.From != nil && attempt.AttemptTime.Before(*query.From)) ||
+			(query.To != nil && !attempt.AttemptTime.Before(*query.To)) {
+			continue
+		}
+		mode := ""
+		if attempt.Mode != nil {
+			mode = *attempt.Mode
+		}
+		return *attempt.Status, mode, true
+	}
+	return "", "", false
+}
+
+// queryGroup holds the counts of one group, laid out like a data cube row:
+// a cell per status with a value per difficulty
+type queryGroup struct {
+	dimensions map[string]string
+	row        Row
+	rollup     bool
+}
+
+// Validate checks the dimensions and measures of a query
+func (q *Query) Validate() error {
+	for _, dimension := range q.GroupBy {
+		if !contains(Dimensions, dimension) {
+			return fmt.Errorf("unknown dimension %s", dimension)
+		}
+	}
+	for dimension := range q.Filters {
+		if !contains(Dimensions, dimension) {
+			return fmt.Errorf("unknown filter %s", dimension)
+		}
+	}
+	for _, measure := range q.Measures {
+		if !contains(Measures, measure) {
+			return fmt.Errorf("unknown measure %s", measure)
+		}
+	}
+	if q.From != nil && q.To != nil && q.To.Before(*q.From) {
+		return fmt.Errorf("the date range ends before it starts")
+	}
+	return nil
+}
+
+// matches reports whether a value passes the filter on a dimension
+func (q *Query) matches(dimension, value string) bool {
+	allowed, ok := q.Filters[dimension]
+	return !ok || len(allowed) == 0 || contains(allowed, value)
+}
+
+// getFacts returns the user's published questions with their engagement
+func (s *DataCubeService) getFacts(ctx context.Context, userID primitive.ObjectID, difficulties []string) ([]fact, error) {
+	match := bson.M{"state": question.PublishedFilter()}
+	if len(difficulties) > 0 {
+		match["difficulty"] = bson.M{"$in": difficulties}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$lookup": bson.M{
+			"from": "engagements",
+			"let":  bson.M{"questionID": "$_id"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$and": []bson.M{
+					{"$eq": []interface{}{"$question_id", "$$questionID"}},
+					{"$eq": []interface{}{"$user_id", userID}},
+				}}}},
+				{"$project": bson.M{"status": 1, "mode": 1, "attempt_time": 1, "attempts.status": 1, "attempts.mode": 1, "attempts.attempt_time": 1}},
+			},
+			"as": "engagement",
+		}},
+		{"$project": bson.M{
+			"topic":      1,
+			"difficulty": 1,
+			"engagement": bson.M{"$arrayElemAt": []interface{}{"$engagement", 0}},
+		}},
+	}
+
+	cursor, err := s.questionCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error getting questions for query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var facts []fact
+	if err := cursor.All(ctx, &facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// RunQuery slices the user's questions along the requested dimensions
+func (s *DataCubeService) RunQuery(ctx context.Context, userID primitive.ObjectID, query Query) ([]QueryRow, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	facts, err := s.getFacts(ctx, userID, query.Filters[DimensionDifficulty])
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceFacts(facts, query), nil
+}
+
+// sliceFacts groups and measures the facts for a validated query
+func sliceFacts(facts []fact, query Query) []QueryRow {
+	results := groupFacts(facts, query)
+	if query.Rollup {
+		results = append(results, rollupGroups(results, query.GroupBy)...)
+	}
+
+	return expandGroups(results, query)
+}
+
+// groupFacts counts the facts that pass the query's filters into groups by the topic levels and mode grouped by.
+// Status and difficulty are split out later from the cells of each group.
+func groupFacts(facts []fact, query Query) []*queryGroup {
+	// Grouping by a topic level also records the coarser levels, so subtotals can be rolled up
+	rowDimensions := []string{}
+	for i, level := range topicHierarchy {
+		if contains(query.GroupBy, level) {
+			rowDimensions = append(rowDimensions, topicHierarchy[i:]...)
+			break
+		}
+	}
+	if contains(query.GroupBy, DimensionMode) {
+		rowDimensions = append(rowDimensions, DimensionMode)
+	}
+
+	groups := map[string]*queryGroup{}
+	for _, f := range facts {
+		if !contains(question.ValidDifficulties, f.Difficulty) {
+			continue
+		}
+		path, ok := parameterdata.FindTopic(f.Topic)
+		if !ok {
+			continue
+		}
+
+		status, mode := engagement.StatusUnattempted, ""
+		if f.Engagement != nil {
+			if attemptStatus, attemptMode, ok := f.Engagement.lastAttemptIn(query); ok {
+				status, mode = attemptStatus, attemptMode
+			}
+		}
+
+		values := map[string]string{
+			DimensionSubject:     path.Subject,
+			DimensionParentTopic: path.ParentTopic,
+			DimensionTopic:       f.Topic,
+			DimensionDifficulty:  f.Difficulty,
+			DimensionStatus:      status,
+			DimensionMode:        mode,
+		}
+		passes := true
+		for _, dimension := range Dimensions {
+			passes = passes && query.matches(dimension, values[dimension])
+		}
+		if !passes {
+			continue
+		}
+
+		dimensions := map[string]string{}
+		for _, dimension := range rowDimensions {
+			dimensions[dimension] = values[dimension]
+		}
+		group := groupFor(groups, dimensions)
+		addCount(group.row, status, f.Difficulty)
+	}
+
+	results := make([]*queryGroup, 0, len(groups))
+	for _, group := range groups {
+		results = append(results, group)
+	}
+	return results
+}
+
+// groupKey identifies a group by its dimension values
+func groupKey(dimensions map[string]string) string {
+	parts := make([]string, 0, len(dimensions))
+	for _, dimension := range Dimensions {
+		if value, ok := dimensions[dimension]; ok {
+			parts = append(parts, dimension+"="+value)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+func groupFor(groups map[string]*queryGroup, dimensions map[string]string) *queryGroup {
+	key := groupKey(dimensions)
+	group, ok := groups[key]
+	if !ok {
+		group = &queryGroup{dimensions: dimensions, row: Row{Cells: make(map[string]Cell)}}
+		groups[key] = group
+	}
+	return group
+}
+
+// addCount counts one question in the status cell of a row
+func addCount(row Row, status, difficulty string) {
+	cell, ok := row.Cells[status]
+	if !ok {
+		cell = Cell{Values: make(map[string]*float64)}
+		row.Cells[status] = cell
+	}
+	for _, column := range []string{difficulty, "total"} {
+		if cell.Values[column] == nil {
+			cell.Values[column] = new(float64)
+		}
+		*cell.Values[column]++
+	}
+}
+
+// rollupGroups sums the groups up each level of the topic hierarchy, starting from the finest level grouped by
+func rollupGroups(groups []*queryGroup, groupBy []string) []*queryGroup {
+	rollups := []*queryGroup{}
+	removed := []string{}
+	started := false
+	for _, level := range topicHierarchy {
+		removed = append(removed, level)
+		started = started || contains(groupBy, level)
+		if !started {
+			continue
+		}
+
+		levelGroups := map[string]*queryGroup{}
+		levelRows := map[string][]Row{}
+		for _, group := range groups {
+			dimensions := map[string]string{}
+			for dimension, value := range group.dimensions {
+				if !contains(removed, dimension) {
+					dimensions[dimension] = value
+				}
+			}
+			key := groupKey(dimensions)
+			if _, ok := levelGroups[key]; !ok {
+				levelGroups[key] = &queryGroup{dimensions: dimensions, rollup: true}
+			}
+			levelRows[key] = append(levelRows[key], group.row)
+		}
+
+		for key, group := range levelGroups {
+			group.row = sumRows(levelRows[key])
+			rollups = append(rollups, group)
+		}
+	}
+	return rollups
+}
+
+// expandGroups calculates the measures of each group, splitting groups by status and difficulty if requested
+func expandGroups(groups []*queryGroup, query Query) []QueryRow {
+	byStatus := contains(query.GroupBy, DimensionStatus)
+	byDifficulty := contains(query.GroupBy, DimensionDifficulty)
+
+	columns := []string{"total"}
+	if byDifficulty {
+		columns = []string{}
+		for _, difficulty := range question.ValidDifficulties {
+			if query.matches(DimensionDifficulty, difficulty) {
+				columns = append(columns, difficulty)
+			}
+		}
+	}
+
+	measures := query.Measures
+	if len(measures) == 0 {
+		measures = Measures
+	}
+
+	rows := []QueryRow{}
+	for _, group := range groups {
+		statusRows := map[string]Row{"": group.row}
+		if byStatus {
+			statusRows = map[string]Row{}
+			for _, status := range statuses {
+				if cell, ok := group.row.Cells[status]; ok {
+					statusRows[status] = Row{Cells: map[string]Cell{status: cell}}
+				}
+			}
+		}
+
+		for status, row := range statusRows {
+			cells := measureCells(row)
+			for _, column := range columns {
+				dimensions := map[string]string{}
+				for dimension, value := range group.dimensions {
+					dimensions[dimension] = value
+				}
+				if byStatus {
+					dimensions[DimensionStatus] = status
+				}
+				if byDifficulty {
+					dimensions[DimensionDifficulty] = column
+				}
+
+				values := map[string]float64{}
+				for _, measure := range measures {
+					values[measure] = CellValue(cells[measure], column)
+				}
+				rows = append(rows, QueryRow{Dimensions: dimensions, Rollup: group.rollup, Measures: values})
+			}
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Rollup != rows[j].Rollup {
+			return !rows[i].Rollup
+		}
+		return groupKey(rows[i].Dimensions) < groupKey(rows[j].Dimensions)
+	})
+	return rows
+}
+
+// measureCells derives the measure cells of a row from its status cells, the same way the data cube does
+func measureCells(row Row) map[string]Cell {
+	cells := map[string]Cell{}
+	for _, status := range statuses {
+		cells[status] = row.Cells[status]
+	}
+	cells["total"] = sumCells([]Cell{cells["unattempted"], cells["correct"], cells["incorrect"], cells["omitted"]})
+	cells["attempted"] = sumCells([]Cell{cells["correct"], cells["incorrect"], cells["omitted"]})
+	cells["accuracy"] = divideCells(cells["correct"], cells["attempted"])
+	cells["completion"] = divideCells(cells["attempted"], cells["total"])
+	return cells
+}
@@ -0,0 +1,231 @@
+package datacube
+
+import (
+	"example/goserver/engagement"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryValidate(t *testing.T) {
+	from := day(2024, 3, 5)
+	to := day(2024, 3, 1)
+
+	tests := []struct {
+		name    string
+		query   Query
+		wantErr bool
+	}{
+		{"empty", Query{}, false},
+		{"known names", Query{GroupBy: []string{DimensionTopic, DimensionStatus}, Filters: map[string][]string{DimensionMode: {"practice"}}, Measures: []string{"accuracy"}}, false},
+		{"unknown dimension", Query{GroupBy: []string{"week"}}, true},
+		{"unknown filter", Query{Filters: map[string][]string{"week": {"1"}}}, true},
+		{"unknown measure", Query{Measures: []string{"speed"}}, true},
+		{"range ends before it starts", Query{From: &from, To: &to}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func answered(topic, difficulty, status, mode string, attemptTime time.Time) fact {
+	return fact{Topic: topic, Difficulty: difficulty, Engagement: &factEngagement{Status: &status, Mode: &mode, AttemptTime: attemptTime}}
+}
+
+var queryFacts = []fact{
+	answered("Linear functions", "easy", engagement.StatusCorrect, "practice", day(2024, 3, 5)),
+	answered("Linear functions", "hard", engagement.StatusIncorrect, "test", day(2024, 3, 10)),
+	{Topic: "Nonlinear functions", Difficulty: "medium"},
+	answered("Circles", "easy", engagement.StatusOmitted, "practice", day(2024, 3, 5)),
+	answered("Craft and structure", "easy", engagement.StatusCorrect, "practice", day(2024, 3, 5)),
+	// Questions with an unknown topic or difficulty are left out
+	answered("Calculus", "easy", engagement.StatusCorrect, "practice", day(2024, 3, 5)),
+	answered("Linear functions", "trivial", engagement.StatusCorrect, "practice", day(2024, 3, 5)),
+}
+
+// findRow returns the row with exactly the given dimensions
+func findRow(t *testing.T, rows []QueryRow, dimensions map[string]string) QueryRow {
+	t.Helper()
+	for _, row := range rows {
+		if reflect.DeepEqual(row.Dimensions, dimensions) {
+			return row
+		}
+	}
+	t.Fatalf("no row with dimensions %v in %+v", dimensions, rows)
+	return QueryRow{}
+}
+
+func TestSliceFacts(t *testing.T) {
+	t.Run("everything in one row", func(t *testing.T) {
+		rows := sliceFacts(queryFacts, Query{})
+		if len(rows) != 1 {
+			t.Fatalf("got %d rows, want 1", len(rows))
+		}
+		want := map[string]float64{"total": 5, "unattempted": 1, "attempted": 4, "correct": 2, "incorrect": 1, "omitted": 1, "accuracy": 0.5, "completion": 0.8}
+		if !reflect.DeepEqual(rows[0].Measures, want) {
+			t.Errorf("measures = %v, want %v", rows[0].Measures, want)
+		}
+	})
+
+	t.Run("by subject", func(t *testing.T) {
+		rows := sliceFacts(queryFacts, Query{GroupBy: []string{DimensionSubject}, Measures: []string{"total", "correct"}})
+		want := []QueryRow{
+			{Dimensions: map[string]string{DimensionSubject: "Math"}, Measures: map[string]float64{"total": 4, "correct": 1}},
+			{Dimensions: map[string]string{DimensionSubject: "Reading"}, Measures: map[string]float64{"total": 1, "correct": 1}},
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("rows = %+v, want %+v", rows, want)
+		}
+	})
+
+	t.Run("by topic with rollup", func(t *testing.T) {
+		query := Query{
+			GroupBy:  []string{DimensionTopic},
+			Filters:  map[string][]string{DimensionSubject: {"Math"}},
+			Rollup:   true,
+			Measures: []string{"total"},
+		}
+		rows := sliceFacts(queryFacts, query)
+
+		// Three topics, three parent topics, the subject and the grand total
+		if len(rows) != 8 {
+			t.Fatalf("got %d rows, want 8: %+v", len(rows), rows)
+		}
+		for i, row := range rows {
+			if row.Rollup != (i >= 3) {
+				t.Errorf("row %d rollup = %v, want topic rows before subtotals", i, row.Rollup)
+			}
+		}
+
+		linear := findRow(t, rows, map[string]string{DimensionTopic: "Linear functions", DimensionParentTopic: "Algebra", DimensionSubject: "Math"})
+		if linear.Measures["total"] != 2 {
+			t.Errorf("Linear functions total = %g, want 2", linear.Measures["total"])
+		}
+		algebra := findRow(t, rows, map[string]string{DimensionParentTopic: "Algebra", DimensionSubject: "Math"})
+		if !algebra.Rollup || algebra.Measures["total"] != 2 {
+			t.Errorf("Algebra subtotal = %+v, want a rollup of 2", algebra)
+		}
+		if mathRow := findRow(t, rows, map[string]string{DimensionSubject: "Math"}); mathRow.Measures["total"] != 4 {
+			t.Errorf("Math subtotal = %g, want 4", mathRow.Measures["total"])
+		}
+		if total := findRow(t, rows, map[string]string{}); total.Measures["total"] != 4 {
+			t.Errorf("grand total = %g, want 4", total.Measures["total"])
+		}
+	})
+
+	t.Run("by status and difficulty", func(t *testing.T) {
+		query := Query{
+			GroupBy:  []string{DimensionStatus, DimensionDifficulty},
+			Filters:  map[string][]string{DimensionDifficulty: {"easy", "hard"}},
+			Measures: []string{"total", "attempted"},
+		}
+		rows := sliceFacts(queryFacts, query)
+
+		// The medium question is filtered out, so there is no unattempted status
+		if len(rows) != 6 {
+			t.Fatalf("got %d rows, want 3 statuses in 2 difficulties: %+v", len(rows), rows)
+		}
+
+		tests := []struct {
+			status, difficulty string
+			total              float64
+		}{
+			{engagement.StatusCorrect, "easy", 2},
+			{engagement.StatusCorrect, "hard", 0},
+			{engagement.StatusIncorrect, "hard", 1},
+			{engagement.StatusOmitted, "easy", 1},
+		}
+		for _, tt := range tests {
+			row := findRow(t, rows, map[string]string{DimensionStatus: tt.status, DimensionDifficulty: tt.difficulty})
+			if row.Measures["total"] != tt.total || row.Measures["attempted"] != tt.total {
+				t.Errorf("%s %s = %v, want total and attempted %g", tt.status, tt.difficulty, row.Measures, tt.total)
+			}
+		}
+	})
+
+	t.Run("answers outside the date range count as unattempted", func(t *testing.T) {
+		from, to := day(2024, 3, 1), day(2024, 3, 8)
+		query := Query{Filters: map[string][]string{DimensionStatus: {engagement.StatusUnattempted}}, From: &from, To: &to, Measures: []string{"total"}}
+
+		rows := sliceFacts(queryFacts, query)
+		if len(rows) != 1 || rows[0].Measures["total"] != 2 {
+			t.Errorf("rows = %+v, want 2 unattempted questions", rows)
+		}
+	})
+
+	t.Run("the last answer in the date range counts", func(t *testing.T) {
+		correct, incorrect, practice, test := engagement.StatusCorrect, engagement.StatusIncorrect, "practice", "test"
+		facts := []fact{{Topic: "Circles", Difficulty: "easy", Engagement: &factEngagement{
+			Status: &incorrect, Mode: &test, AttemptTime: day(2024, 3, 10),
+			Attempts: []engagement.Attempt{
+				{Status: &incorrect, Mode: &practice, AttemptTime: day(2024, 2, 20)},
+				{Status: &correct, Mode: &practice, AttemptTime: day(2024, 3, 2)},
+				{Status: &incorrect, Mode: &test, AttemptTime: day(2024, 3, 10)},
+			},
+		}}}
+
+		tests := []struct {
+			name         string
+			from, to     time.Time
+			status, mode string
+		}{
+			{"an earlier answer", day(2024, 3, 1), day(2024, 3, 8), correct, practice},
+			{"the latest answer", day(2024, 3, 1), day(2024, 3, 11), incorrect, test},
+			{"no answer", day(2024, 3, 3), day(2024, 3, 8), engagement.StatusUnattempted, ""},
+		}
+		for _, tt := range tests {
+			from, to := tt.from, tt.to
+			rows := sliceFacts(facts, Query{GroupBy: []string{DimensionStatus, DimensionMode}, From: &from, To: &to, Measures: []string{"total"}})
+			if row := findRow(t, rows, map[string]string{DimensionStatus: tt.status, DimensionMode: tt.mode}); row.Measures["total"] != 1 {
+				t.Errorf("%s: %s %s total = %g, want 1", tt.name, tt.status, tt.mode, row.Measures["total"])
+			}
+		}
+	})
+
+	t.Run("by mode", func(t *testing.T) {
+		rows := sliceFacts(queryFacts, Query{GroupBy: []string{DimensionMode}, Measures: []string{"attempted"}})
+
+		want := map[string]float64{"": 0, "practice": 3, "test": 1}
+		if len(rows) != len(want) {
+			t.Fatalf("got %d rows, want %d", len(rows), len(want))
+		}
+		for mode, attempted := range want {
+			if row := findRow(t, rows, map[string]string{DimensionMode: mode}); row.Measures["attempted"] != attempted {
+				t.Errorf("mode %q attempted = %g, want %g", mode, row.Measures["attempted"], attempted)
+			}
+		}
+	})
+}
+
+func TestMeasureCells(t *testing.T) {
+	row := Row{Cells: make(map[string]Cell)}
+	addCount(row, engagement.StatusCorrect, "easy")
+	addCount(row, engagement.StatusCorrect, "hard")
+	addCount(row, engagement.StatusIncorrect, "hard")
+	addCount(row, engagement.StatusUnattempted, "easy")
+
+	cells := measureCells(row)
+
+	tests := []struct {
+		measure, column string
+		want            float64
+	}{
+		{"total", "total", 4},
+		{"attempted", "total", 3},
+		{"accuracy", "total", 2.0 / 3},
+		{"completion", "total", 0.75},
+		{"accuracy", "hard", 0.5},
+		{"completion", "easy", 0.5},
+		{"omitted", "total", 0},
+	}
+	for _, tt := range tests {
+		if got := CellValue(cells[tt.measure], tt.column); got != tt.want {
+			t.Errorf("%s %s = %g, want %g", tt.measure, tt.column, got, tt.want)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package datacube
 
 import (
+	"errors"
+	"example/goserver/user"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup, dataCubeService *DataCubeService) {
+func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup, dataCubeService *DataCubeService, userService *user.UserService) {
 	// Existing code...
 
 	// Add this line to create a new route for getDatacube
 	publicRouter.GET("/datacube", getDatacube(dataCubeService))
+	publicRouter.GET("/datacube/trend", getTrend(dataCubeService))
+	publicRouter.GET("/datacube/query", queryDatacube(dataCubeService))
+	publicRouter.GET("/mastery", getMastery(dataCubeService))
+	publicRouter.POST("/mastery/calibrate", user.RequireRole(userService, user.RoleContentAdmin), calibrateQuestions(dataCubeService))
 
 	// Existing code...
 }
@@ -40,34 +49,173 @@ func getDatacube(service *DataCubeService) gin.HandlerFunc {
 			userIDObj = &userIDObjTemp
 		}
 
-		compute := c.DefaultQuery("compute", "false")
+		// The stored cube is kept up to date as engagements are logged, so it is only computed in full the first time
+		dataCube, err := service.GetDataCube(userIDObj)
+		if dataCube == nil {
+			dataCube, err = service.ComputeDataCube(userIDObj)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, dataCube)
+	}
+}
 
-		var dataCube *DataCube
-		var err error
+// getTrend returns the history of a data cube row, e.g. accuracy in Algebra on hard questions over the last 8 weeks:
+// /datacube/trend?row=Algebra&cell=accuracy&column=hard&interval=week&periods=8
+func getTrend(service *DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
 
-		if compute == "true" {
-			dataCube, err = service.ComputeDataCube(userIDObj)
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		row := c.DefaultQuery("row", "Total")
+		periods, err := strconv.Atoi(c.DefaultQuery("periods", "8"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid periods"})
+			return
+		}
+
+		trend, err := service.GetTrend(c, userIDObj, row, c.Query("cell"), c.Query("column"), c.DefaultQuery("interval", IntervalWeek), periods)
+		if errors.Is(err, ErrInvalidTrend) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, trend)
+	}
+}
+
+// parseQueryDate accepts a date or an RFC 3339 time. A date used as the end of a range includes the whole day.
+func parseQueryDate(value string, endOfRange bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// queryDatacube slices the user's questions by the requested dimensions, e.g.
+// /datacube/query?groupBy=topic,difficulty&subject=Math&mode=practice&from=2024-01-01&rollup=true&measures=accuracy,completion
+// Filters can be repeated to allow several values, since topic names contain commas.
+func queryDatacube(service *DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		query := Query{
+			Filters: map[string][]string{},
+			Rollup:  c.Query("rollup") == "true",
+		}
+		if groupBy := c.Query("groupBy"); groupBy != "" {
+			query.GroupBy = strings.Split(groupBy, ",")
+		}
+		if measures := c.Query("measures"); measures != "" {
+			query.Measures = strings.Split(measures, ",")
+		}
+		for _, dimension := range Dimensions {
+			if values := c.QueryArray(dimension); len(values) > 0 {
+				query.Filters[dimension] = values
+			}
+		}
+
+		if query.From, err = parseQueryDate(c.Query("from"), false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+		if query.To, err = parseQueryDate(c.Query("to"), true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+		if err := query.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := service.RunQuery(c, userIDObj, query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rows": rows})
+	}
+}
+
+func getMastery(service *DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var mastery *Mastery
+		if c.DefaultQuery("compute", "false") != "true" {
+			mastery, err = service.GetMastery(c, &userIDObj)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-		} else {
-			dataCube, err = service.GetDataCube(userIDObj)
-
-			// if err != nil {
-			// 	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			// 	return
-			// }
-
-			if dataCube == nil {
-				dataCube, err = service.ComputeDataCube(userIDObj)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-					return
-				}
+		}
+
+		if mastery == nil {
+			mastery, err = service.ComputeMastery(c, &userIDObj)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
 			}
 		}
 
-		c.JSON(http.StatusOK, dataCube)
+		c.JSON(http.StatusOK, mastery)
+	}
+}
+
+func calibrateQuestions(service *DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, err := service.CalibrateQuestions(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Questions calibrated successfully", "numQuestions": count})
 	}
 }
@@ -13,15 +13,25 @@ import (
 )
 
 type DataCubeService struct {
-	collection      *mongo.Collection
-	questionService *question.QuestionService
+	collection            *mongo.Collection
+	masteryCollection     *mongo.Collection
+	calibrationCollection *mongo.Collection
+	engagementCollection  *mongo.Collection
+	historyCollection     *mongo.Collection
+	questionCollection    *mongo.Collection
+	questionService       *question.QuestionService
 }
 
 func NewDataCubeService(client *mongo.Client, questionService *question.QuestionService) *DataCubeService {
 	collection := client.Database("test").Collection("datacubes")
 	return &DataCubeService{
-		collection:      collection,
-		questionService: questionService,
+		collection:            collection,
+		masteryCollection:     client.Database("test").Collection("masteries"),
+		calibrationCollection: client.Database("test").Collection("question_calibrations"),
+		engagementCollection:  client.Database("test").Collection("engagements"),
+		historyCollection:     client.Database("test").Collection("datacube_history"),
+		questionCollection:    client.Database("test").Collection("questions"),
+		questionService:       questionService,
 	}
 }
 func (s *DataCubeService) GetDataCubeCollection() *mongo.Collection {
@@ -48,14 +58,35 @@ func (s *DataCubeService) GetDataCube(userIDObj *primitive.ObjectID) (*DataCube,
 		return nil, fmt.Errorf("error getting data cube for user: %w", err)
 	}
 
+	// The counts are updated incrementally, so the ratios are calculated when the cube is read
+	deriveCells(&dataCube)
+
 	return &dataCube, nil
 }
 
+// ComputeDataCube rebuilds the user's data cube from scratch and saves it.
+// Cubes are kept up to date by ApplyStatusChange, so this is only needed for new users and repairs.
 func (s *DataCubeService) ComputeDataCube(userIDObj *primitive.ObjectID) (*DataCube, error) {
 	fmt.Println("Computing data cube for user", userIDObj)
 	// Create a context
 	ctx := context.TODO()
 
+	dataCube, err := s.buildDataCube(ctx, userIDObj)
+	if err != nil {
+		return nil, err
+	}
+
+	// update the datacube in the database if it already exists, if not create a new one...
+	_, err = s.collection.ReplaceOne(ctx, bson.M{"user_id": *userIDObj}, dataCube, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, fmt.Errorf("error updating data cube: %w", err)
+	}
+
+	return dataCube, nil
+}
+
+// buildDataCube computes the user's data cube from the question statistics without saving it
+func (s *DataCubeService) buildDataCube(ctx context.Context, userIDObj *primitive.ObjectID) (*DataCube, error) {
 	// Get the combined statistics
 	combinedStats, err := s.questionService.GetCombinedCubeStatistics(ctx, userIDObj)
 	if err != nil {
@@ -203,8 +234,18 @@ func (s *DataCubeService) ComputeDataCube(userIDObj *primitive.ObjectID) (*DataC
 	totalRow := sumRows([]Row{summedMathRow, summedReadingRow})
 	dataCube.Rows["Total"] = totalRow
 
-	// calculate usage and accuracy for all rows, add the cells...
+	deriveCells(dataCube)
+
+	return dataCube, nil
+}
+
+// deriveCells calculates the usage and accuracy cells of every row from the counts
+func deriveCells(dataCube *DataCube) {
 	for _, row := range dataCube.Rows {
+		if row.Cells == nil {
+			continue
+		}
+
 		// calculate usage
 		usage := divideCells(row.Cells["attempted"], row.Cells["total"])
 		row.Cells["usage"] = usage
@@ -213,14 +254,6 @@ func (s *DataCubeService) ComputeDataCube(userIDObj *primitive.ObjectID) (*DataC
 		accuracy := divideCells(row.Cells["correct"], row.Cells["attempted"])
 		row.Cells["accuracy"] = accuracy
 	}
-
-	// update the datacube in the database if it already exists, if not create a new one...
-	_, err = s.collection.ReplaceOne(ctx, bson.M{"user_id": *userIDObj}, dataCube, options.Replace().SetUpsert(true))
-	if err != nil {
-		return nil, fmt.Errorf("error updating data cube: %w", err)
-	}
-
-	return dataCube, nil
 }
 
 func sumRows(rows []Row) Row {
@@ -0,0 +1,58 @@
+package engagement
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrQuizClosed is returned when an engagement is logged for a quiz that is submitted, paused or past its deadline
+var ErrQuizClosed = errors.New("quiz is closed for answers")
+
+// ErrQuizNotFound is returned when an engagement is logged for a quiz that does not exist or belongs to another user
+var ErrQuizNotFound = errors.New("quiz not found")
+
+// quizWindow holds the timer fields of a quiz that decide whether it still accepts answers.
+// Like answerKey it is read straight from the quizzes collection to avoid an import cycle.
+type quizWindow struct {
+	TimeLimit   time.Duration `bson:"time_limit,omitempty"`
+	StartedTime *time.Time    `bson:"started_time,omitempty"`
+	Deadline    *time.Time    `bson:"deadline,omitempty"`
+	Submitted   bool          `bson:"submitted,omitempty"`
+}
+
+// finished reports whether the quiz has been submitted or run out of time, so its result is final
+func (window *quizWindow) finished(now time.Time) bool {
+	return window.Submitted || (window.Deadline != nil && now.After(*window.Deadline))
+}
+
+// checkQuizOpen returns ErrQuizClosed if the quiz the engagement is sent for no longer accepts answers.
+// Only that quiz is checked: each quiz keeps its own answers, so a closed quiz does not stop the user from
+// answering its questions again in practice or in another quiz.
+func (es *EngagementService) checkQuizOpen(ctx context.Context, engagement *Engagement) error {
+	if engagement.QuizID == nil {
+		return nil
+	}
+
+	var window quizWindow
+	err := es.quizCollection.FindOne(ctx, bson.M{"_id": engagement.QuizID, "user_id": engagement.UserID}).Decode(&window)
+	if err == mongo.ErrNoDocuments {
+		return ErrQuizNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if window.finished(time.Now()) {
+		return ErrQuizClosed
+	}
+	// A quiz with a time limit but no running clock is paused or has not been started
+	if window.TimeLimit > 0 && window.StartedTime == nil {
+		return ErrQuizClosed
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package engagement
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StatusUnattempted is the status of a question the user has not answered yet
+const StatusUnattempted = "unattempted"
+
+// StatusChange is published whenever the graded status of a user's engagement with a question changes.
+// It carries the question's topic and difficulty so listeners do not have to look the question up.
+type StatusChange struct {
+	UserID     primitive.ObjectID
+	QuestionID primitive.ObjectID
+	OldStatus  string
+	NewStatus  string
+	Subject    string
+	Topic      string
+	Difficulty string
+	// Published is false for questions that are still in the review workflow
+	Published bool
+	// Time is when the answer that caused the change was given
+	Time time.Time
+}
+
+// QuestionKey is where a question's engagements are counted: its subject, topic and difficulty,
+// and whether it is published
+type QuestionKey struct {
+	Subject    string
+	Topic      string
+	Difficulty string
+	Published  bool
+}
+
+// questionKey returns the key the answer key's question is counted under
+func (key *answerKey) questionKey() QuestionKey {
+	return QuestionKey{
+		Subject:    key.Subject,
+		Topic:      key.Topic,
+		Difficulty: key.Difficulty,
+		Published:  key.State == "" || key.State == "published",
+	}
+}
+
+// QuestionChange is published when a question is created, edited, moved through the review workflow or deleted.
+// Old is nil for a new question and New is nil for a deleted one.
+type QuestionChange struct {
+	QuestionID primitive.ObjectID
+	Old        *QuestionKey
+	New        *QuestionKey
+}
+
+// StatusListener is called after an engagement's status has changed
+type StatusListener func(ctx context.Context, change StatusChange) error
+
+// OnStatusChange registers a listener for status changes. Listeners must be registered before the server starts.
+func (es *EngagementService) OnStatusChange(listener StatusListener) {
+	es.statusListeners = append(es.statusListeners, listener)
+}
+
+// publishStatusChange notifies the listeners if the status actually changed.
+// A listener failing does not undo the engagement, so errors are only logged.
+func (es *EngagementService) publishStatusChange(ctx context.Context, engagement *Engagement, key *answerKey, oldStatus *string, newStatus string) {
+	if key == nil {
+		return
+	}
+	es.publishKeyedChange(ctx, engagement, key.questionKey(), oldStatus, newStatus)
+}
+
+// publishKeyedChange notifies the listeners of a status change counted under the given key
+func (es *EngagementService) publishKeyedChange(ctx context.Context, engagement *Engagement, key QuestionKey, oldStatus *string, newStatus string) {
+	if engagement.UserID == nil || engagement.QuestionID == nil {
+		return
+	}
+
+	previous := StatusUnattempted
+	if oldStatus != nil && *oldStatus != "" {
+		previous = *oldStatus
+	}
+	if previous == newStatus {
+		return
+	}
+
+	change := StatusChange{
+		UserID:     *engagement.UserID,
+		QuestionID: *engagement.QuestionID,
+		OldStatus:  previous,
+		NewStatus:  newStatus,
+		Subject:    key.Subject,
+		Topic:      key.Topic,
+		Difficulty: key.Difficulty,
+		Published:  key.Published,
+		Time:       engagement.AttemptTime,
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+
+	for _, listener := range es.statusListeners {
+		if err := listener(ctx, change); err != nil {
+			log.Printf("Error handling status change for user %s, question %s: %v", change.UserID.Hex(), change.QuestionID.Hex(), err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package engagement
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StatusCorrect   = "correct"
+	StatusIncorrect = "incorrect"
+	StatusOmitted   = "omitted"
+)
+
+// AnswerSeparator separates the accepted alternatives in a free response answer key, e.g. "1/2|0.5".
+// Commas are not used because they appear inside answers such as "1,000".
+const AnswerSeparator = "|"
+
+// ErrClientStatus is returned when a client tries to set the status of an engagement itself.
+var ErrClientStatus = errors.New("status is assigned by the server and cannot be set by the client")
+
+// answerKey holds the fields of a question that are needed to grade an answer.
+// It is read straight from the questions collection so this package does not depend on the question package.
+type answerKey struct {
+	AnswerType            *string             `bson:"answer_type,omitempty"`
+	AnswerChoices         *[]string           `bson:"answer_choices,omitempty"`
+	CorrectAnswerMultiple *string             `bson:"correct_answer_multiple,omitempty"`
+	CorrectAnswerFree     *string             `bson:"correct_answer_free,omitempty"`
+	RevisionID            *primitive.ObjectID `bson:"revision_id,omitempty"`
+	Subject               string              `bson:"subject,omitempty"`
+	Topic                 string              `bson:"topic,omitempty"`
+	Difficulty            string              `bson:"difficulty,omitempty"`
+	State                 string              `bson:"state,omitempty"`
+}
+
+// getAnswerKey fetches the answer key for a question
+func (es *EngagementService) getAnswerKey(ctx context.Context, questionID *primitive.ObjectID) (*answerKey, error) {
+	if questionID == nil {
+		return nil, errors.New("question ID is required")
+	}
+
+	var key answerKey
+	projection := bson.M{"answer_type": 1, "answer_choices": 1, "correct_answer_multiple": 1, "correct_answer_free": 1, "revision_id": 1,
+		"subject": 1, "topic": 1, "difficulty": 1, "state": 1}
+	err := es.questionCollection.FindOne(ctx, bson.M{"_id": questionID}, options.FindOne().SetProjection(projection)).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("question not found")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GradeEngagement looks up the question for an engagement and sets its status from the user's answer,
+// along with the question revision it was graded against
+func (es *EngagementService) GradeEngagement(ctx context.Context, engagement *Engagement) error {
+	_, err := es.gradeEngagement(ctx, engagement)
+	return err
+}
+
+// gradeEngagement grades an engagement and returns the answer key it was graded with
+func (es *EngagementService) gradeEngagement(ctx context.Context, engagement *Engagement) (*answerKey, error) {
+	key, err := es.getAnswerKey(ctx, engagement.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := gradeAnswer(key, engagement.UserAnswer)
+	engagement.Status = &status
+	engagement.RevisionID = key.RevisionID
+	return key, nil
+}
+
+// ApplyQuestionChange moves the users' statuses on a question from the key they were counted under to its new key,
+// by publishing each status as taken off the old key and added under the new one. The question's own count
+// in every cube is changed by the data cube listener. It is registered as a question change listener.
+func (es *EngagementService) ApplyQuestionChange(ctx context.Context, change QuestionChange) error {
+	if change.Old == nil {
+		return nil
+	}
+
+	cursor, err := es.collection.Find(ctx, bson.M{"question_id": change.QuestionID, "status": bson.M{"$nin": bson.A{nil, ""}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	unattempted := StatusUnattempted
+	for cursor.Next(ctx) {
+		var engagement Engagement
+		if err := cursor.Decode(&engagement); err != nil {
+			return err
+		}
+
+		es.publishKeyedChange(ctx, &engagement, *change.Old, engagement.Status, StatusUnattempted)
+		if change.New != nil {
+			es.publishKeyedChange(ctx, &engagement, *change.New, &unattempted, *engagement.Status)
+		}
+	}
+	return cursor.Err()
+}
+
+// RegradeResult summarizes a regrade of the engagements for a question
+type RegradeResult struct {
+	Engagements int64 `json:"Engagements"`
+	Changed     int64 `json:"Changed"`
+}
+
+// RegradeQuestion grades every engagement with a question again against its current answer key.
+// The latest answer and each attempt in the history are regraded and marked with the current revision.
+// The statuses are already counted under the question's current topic and difficulty, as ApplyQuestionChange
+// moves them when the question is edited.
+func (es *EngagementService) RegradeQuestion(ctx context.Context, questionID primitive.ObjectID) (*RegradeResult, error) {
+	key, err := es.getAnswerKey(ctx, &questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := es.collection.Find(ctx, bson.M{"question_id": questionID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &RegradeResult{}
+	for cursor.Next(ctx) {
+		var engagement Engagement
+		if err := cursor.Decode(&engagement); err != nil {
+			return nil, err
+		}
+		result.Engagements++
+
+		changed := false
+		set := bson.M{"revision_id": key.RevisionID}
+		if engagement.Status != nil {
+			status := gradeAnswer(key, engagement.UserAnswer)
+			changed = status != *engagement.Status
+			set["status"] = status
+		}
+		if len(engagement.Attempts) > 0 {
+			for i := range engagement.Attempts {
+				status := gradeAnswer(key, engagement.Attempts[i].UserAnswer)
+				engagement.Attempts[i].Status = &status
+				engagement.Attempts[i].RevisionID = key.RevisionID
+			}
+			set["attempts"] = engagement.Attempts
+		}
+
+		if _, err := es.collection.UpdateOne(ctx, bson.M{"_id": engagement.ID}, bson.M{"$set": set}); err != nil {
+			return nil, err
+		}
+		if changed {
+			result.Changed++
+			es.publishStatusChange(ctx, &engagement, key, engagement.Status, set["status"].(string))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// gradeAnswer compares a user's answer to the answer key and returns "correct", "incorrect" or "omitted".
+func gradeAnswer(key *answerKey, userAnswer *string) string {
+	if userAnswer == nil || strings.TrimSpace(*userAnswer) == "" {
+		return StatusOmitted
+	}
+	answer := strings.TrimSpace(*userAnswer)
+
+	if isFreeResponse(key) {
+		if key.CorrectAnswerFree != nil && freeResponseMatches(answer, *key.CorrectAnswerFree) {
+			return StatusCorrect
+		}
+		return StatusIncorrect
+	}
+
+	if key.CorrectAnswerMultiple != nil && multipleChoiceMatches(answer, *key.CorrectAnswerMultiple, key.AnswerChoices) {
+		return StatusCorrect
+	}
+	return StatusIncorrect
+}
+
+// isFreeResponse decides which correct answer field applies to the question
+func isFreeResponse(key *answerKey) bool {
+	if key.AnswerType != nil && *key.AnswerType != "" {
+		return strings.Contains(strings.ToLower(*key.AnswerType), "free")
+	}
+	// Older questions have no answer type, so fall back to whichever answer is filled in
+	return key.CorrectAnswerMultiple == nil && key.CorrectAnswerFree != nil
+}
+
+// multipleChoiceMatches accepts either the choice letter or the choice text
+func multipleChoiceMatches(answer, correct string, choices *[]string) bool {
+	if strings.EqualFold(answer, strings.TrimSpace(correct)) {
+		return true
+	}
+	if choices == nil {
+		return false
+	}
+
+	answerIndex := choiceIndex(answer, *choices)
+	correctIndex := choiceIndex(strings.TrimSpace(correct), *choices)
+	return answerIndex >= 0 && answerIndex == correctIndex
+}
+
+// choiceIndex resolves a letter ("A", "b") or the full text of a choice to its position
+func choiceIndex(value string, choices []string) int {
+	if len(value) == 1 {
+		letter := strings.ToUpper(value)[0]
+		if letter >= 'A' && int(letter-'A') < len(choices) {
+			return int(letter - 'A')
+		}
+	}
+	for i, choice := range choices {
+		if strings.EqualFold(strings.TrimSpace(choice), value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// freeResponseMatches checks the answer against each accepted answer, separated by AnswerSeparator in the key.
+// Numeric answers are compared by value so "0.5", ".5" and "1/2" are all equivalent.
+func freeResponseMatches(answer, correct string) bool {
+	accepted := strings.Split(correct, AnswerSeparator)
+
+	answerValue, answerIsNumber := parseNumber(answer)
+
+	for _, candidate := range accepted {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if strings.EqualFold(normalizeFreeResponse(answer), normalizeFreeResponse(candidate)) {
+			return true
+		}
+		if answerIsNumber {
+			if candidateValue, ok := parseNumber(candidate); ok && math.Abs(answerValue-candidateValue) < 1e-4 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func normalizeFreeResponse(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
+
+// parseNumber parses decimals and fractions such as "-3/4"
+func parseNumber(value string) (float64, bool) {
+	value = normalizeFreeResponse(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if parts := strings.Split(value, "/"); len(parts) == 2 {
+		numerator, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return 0, false
+		}
+		denominator, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || denominator == 0 {
+			return 0, false
+		}
+		return numerator / denominator, true
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, false
+	}
+	return number, true
+}
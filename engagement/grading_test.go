@@ -0,0 +1,97 @@
+package engagement
+
+import "testing"
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestGradeAnswerFreeResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		correct string
+		answer  *string
+		want    string
+	}{
+		{"exact", "12", stringPtr("12"), StatusCorrect},
+		{"decimal without leading zero", "0.5", stringPtr(".5"), StatusCorrect},
+		{"fraction equals decimal", "0.5", stringPtr("1/2"), StatusCorrect},
+		{"decimal equals fraction", "3/4", stringPtr("0.75"), StatusCorrect},
+		{"negative fraction", "-3/4", stringPtr("-0.75"), StatusCorrect},
+		{"trailing zeros", "2", stringPtr("2.000"), StatusCorrect},
+		{"close but wrong", "0.5", stringPtr("0.51"), StatusIncorrect},
+		{"zero denominator", "0", stringPtr("1/0"), StatusIncorrect},
+		{"surrounding whitespace", "7", stringPtr("  7 "), StatusCorrect},
+		{"inner whitespace", "1/2", stringPtr("1 / 2"), StatusCorrect},
+		{"text ignores case", "Pi", stringPtr("pI"), StatusCorrect},
+		{"alternatives", "1/3|.3333", stringPtr(".3333"), StatusCorrect},
+		{"second alternative by value", "1/3|.3333", stringPtr("0.3333"), StatusCorrect},
+		{"alternative not matched", "1/3|.3333", stringPtr(".33"), StatusIncorrect},
+		{"comma is part of the answer", "1,000", stringPtr("1,000"), StatusCorrect},
+		{"comma does not split the key", "1,000", stringPtr("1"), StatusIncorrect},
+		{"comma does not split the key after it", "1,000", stringPtr("000"), StatusIncorrect},
+		{"blank is omitted", "4", stringPtr("   "), StatusOmitted},
+		{"missing is omitted", "4", nil, StatusOmitted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &answerKey{AnswerType: stringPtr("free response"), CorrectAnswerFree: stringPtr(tt.correct)}
+			if got := gradeAnswer(key, tt.answer); got != tt.want {
+				t.Errorf("gradeAnswer(%q, %v) = %s, want %s", tt.correct, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeAnswerMultipleChoice(t *testing.T) {
+	choices := []string{"4", "8", "Sixteen", "32"}
+
+	tests := []struct {
+		name    string
+		correct string
+		choices *[]string
+		answer  string
+		want    string
+	}{
+		{"letter", "B", &choices, "B", StatusCorrect},
+		{"lowercase letter", "B", &choices, "b", StatusCorrect},
+		{"choice text for letter key", "B", &choices, "8", StatusCorrect},
+		{"letter for text key", "Sixteen", &choices, "C", StatusCorrect},
+		{"text ignores case and space", "Sixteen", &choices, " sixteen ", StatusCorrect},
+		{"wrong letter", "B", &choices, "A", StatusIncorrect},
+		{"letter past the choices", "B", &choices, "E", StatusIncorrect},
+		{"no choices needs exact key", "B", nil, "b", StatusCorrect},
+		{"no choices and other answer", "B", nil, "8", StatusIncorrect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &answerKey{AnswerType: stringPtr("multiple choice"), AnswerChoices: tt.choices, CorrectAnswerMultiple: stringPtr(tt.correct)}
+			if got := gradeAnswer(key, stringPtr(tt.answer)); got != tt.want {
+				t.Errorf("gradeAnswer(%q, %q) = %s, want %s", tt.correct, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFreeResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		key  answerKey
+		want bool
+	}{
+		{"free response type", answerKey{AnswerType: stringPtr("Free Response")}, true},
+		{"multiple choice type", answerKey{AnswerType: stringPtr("multiple choice"), CorrectAnswerFree: stringPtr("1")}, false},
+		{"untyped with free answer only", answerKey{CorrectAnswerFree: stringPtr("1")}, true},
+		{"untyped with multiple choice answer", answerKey{CorrectAnswerMultiple: stringPtr("A"), CorrectAnswerFree: stringPtr("1")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFreeResponse(&tt.key); got != tt.want {
+				t.Errorf("isFreeResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,15 +6,18 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// type Attempt struct {
-// 	UserAnswer  *string       `bson:"user_answer,omitempty" json:"UserAnswer,omitempty"`
-// 	Omitted     *bool         `bson:"omitted,omitempty" json:"Omitted,omitempty"`
-// 	IsCorrect   *bool         `bson:"is_correct,omitempty" json:"IsCorrect,omitempty"`
-// 	IsIncorrect *bool         `bson:"is_incorrect,omitempty" json:"IsIncorrect,omitempty"`
-// 	AttemptTime time.Time     `bson:"attempt_time,omitempty" json:"AttemptTime,omitempty"`
-// 	Duration    time.Duration `bson:"duration,omitempty" json:"Duration,omitempty"`
-// 	Mode        *string       `bson:"mode,omitempty" json:"Mode,omitempty"`
-// }
+// Attempt is one answer to a question. Attempts are only ever appended to an engagement,
+// while the top-level engagement fields summarize the latest attempt.
+type Attempt struct {
+	UserAnswer  *string             `bson:"user_answer,omitempty" json:"UserAnswer,omitempty"`
+	Status      *string             `bson:"status,omitempty" json:"Status,omitempty"`
+	AttemptTime time.Time           `bson:"attempt_time,omitempty" json:"AttemptTime,omitempty"`
+	Duration    time.Duration       `bson:"duration,omitempty" json:"Duration,omitempty"`
+	Mode        *string             `bson:"mode,omitempty" json:"Mode,omitempty"`
+	QuizID      *primitive.ObjectID `bson:"quiz_id,omitempty" json:"QuizID,omitempty"`
+	// RevisionID is the question revision the answer was graded against
+	RevisionID *primitive.ObjectID `bson:"revision_id,omitempty" json:"RevisionID,omitempty"`
+}
 
 type Engagement struct {
 	ID          *primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
@@ -28,4 +31,26 @@ type Engagement struct {
 	Mode        *string             `bson:"mode,omitempty" json:"Mode,omitempty"`
 	Starred     *bool               `bson:"starred,omitempty" json:"Starred,omitempty"`
 	Reviewed    *bool               `bson:"reviewed,omitempty" json:"Reviewed,omitempty"`
+	QuizID      *primitive.ObjectID `bson:"quiz_id,omitempty" json:"QuizID,omitempty"`
+	Attempts    []Attempt           `bson:"attempts,omitempty" json:"Attempts,omitempty"`
+	Review      *ReviewSchedule     `bson:"review,omitempty" json:"Review,omitempty"`
+	RevisionID  *primitive.ObjectID `bson:"revision_id,omitempty" json:"RevisionID,omitempty"`
+}
+
+// newAttempt records the answer currently held in the engagement as an attempt
+func newAttempt(engagement *Engagement) Attempt {
+	attemptTime := engagement.AttemptTime
+	if attemptTime.IsZero() {
+		attemptTime = time.Now()
+	}
+
+	return Attempt{
+		UserAnswer:  engagement.UserAnswer,
+		Status:      engagement.Status,
+		AttemptTime: attemptTime,
+		Duration:    engagement.Duration,
+		Mode:        engagement.Mode,
+		QuizID:      engagement.QuizID,
+		RevisionID:  engagement.RevisionID,
+	}
 }
@@ -0,0 +1,94 @@
+package engagement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QuizAnswer is the user's latest answer to a question within one quiz.
+// The engagement is shared by every quiz and practice session with the question, so quiz results are read from here;
+// once the quiz closes no more answers are recorded for it and its result stays as it was.
+type QuizAnswer struct {
+	ID          *primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID  `bson:"user_id" json:"UserID"`
+	QuizID      primitive.ObjectID  `bson:"quiz_id" json:"QuizID"`
+	QuestionID  primitive.ObjectID  `bson:"question_id" json:"QuestionID"`
+	UserAnswer  *string             `bson:"user_answer,omitempty" json:"UserAnswer,omitempty"`
+	Status      string              `bson:"status" json:"Status"`
+	AttemptTime time.Time           `bson:"attempt_time" json:"AttemptTime"`
+	Duration    time.Duration       `bson:"duration,omitempty" json:"Duration,omitempty"`
+	RevisionID  *primitive.ObjectID `bson:"revision_id,omitempty" json:"RevisionID,omitempty"`
+}
+
+// recordQuizAnswer stores a graded engagement as the answer to its question in its quiz
+func (es *EngagementService) recordQuizAnswer(ctx context.Context, engagement *Engagement) error {
+	if engagement.QuizID == nil || engagement.UserID == nil || engagement.QuestionID == nil || engagement.Status == nil {
+		return nil
+	}
+
+	filter := bson.M{"user_id": *engagement.UserID, "quiz_id": *engagement.QuizID, "question_id": *engagement.QuestionID}
+	update := bson.M{"$set": bson.M{
+		"user_answer":  engagement.UserAnswer,
+		"status":       *engagement.Status,
+		"attempt_time": engagement.AttemptTime,
+		"duration":     engagement.Duration,
+		"revision_id":  engagement.RevisionID,
+	}}
+	_, err := es.quizAnswerCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error recording quiz answer: %w", err)
+	}
+	return nil
+}
+
+// GetQuizAnswers returns the user's answers in a quiz by question ID
+func (es *EngagementService) GetQuizAnswers(ctx context.Context, userID, quizID primitive.ObjectID) (map[primitive.ObjectID]QuizAnswer, error) {
+	cursor, err := es.quizAnswerCollection.Find(ctx, bson.M{"user_id": userID, "quiz_id": quizID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var answers []QuizAnswer
+	if err = cursor.All(ctx, &answers); err != nil {
+		return nil, err
+	}
+
+	byQuestion := make(map[primitive.ObjectID]QuizAnswer, len(answers))
+	for _, answer := range answers {
+		byQuestion[answer.QuestionID] = answer
+	}
+	return byQuestion, nil
+}
+
+// GetQuizStatuses returns the user's status on each question as last answered in the quiz.
+// Answers to the same questions given outside the quiz, before or after it, do not change the result.
+func (es *EngagementService) GetQuizStatuses(ctx context.Context, userID, quizID primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	answers, err := es.GetQuizAnswers(ctx, userID, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[primitive.ObjectID]string, len(answers))
+	for questionID, answer := range answers {
+		statuses[questionID] = answer.Status
+	}
+	return statuses, nil
+}
+
+// ApplyQuizAnswer replaces the latest answer summarized in the engagement with the answer given in a quiz
+func (engagement *Engagement) ApplyQuizAnswer(answer QuizAnswer) {
+	status := answer.Status
+	quizID := answer.QuizID
+	engagement.UserAnswer = answer.UserAnswer
+	engagement.Status = &status
+	engagement.AttemptTime = answer.AttemptTime
+	engagement.Duration = answer.Duration
+	engagement.QuizID = &quizID
+	engagement.RevisionID = answer.RevisionID
+}
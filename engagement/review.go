@@ -0,0 +1,133 @@
+package engagement
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	initialEaseFactor = 2.5
+	minimumEaseFactor = 1.3
+
+	// targetDuration is how long a question should take; answers well over it count as hesitant
+	targetDuration = 90 * time.Second
+)
+
+// ReviewSchedule is the SM-2 spaced repetition state of an engagement
+type ReviewSchedule struct {
+	EaseFactor  float64   `bson:"ease_factor" json:"EaseFactor"`
+	Interval    int       `bson:"interval" json:"Interval"` // days
+	Repetitions int       `bson:"repetitions" json:"Repetitions"`
+	NextDue     time.Time `bson:"next_due" json:"NextDue"`
+}
+
+// reviewQuality grades an attempt on the SM-2 scale of 0 to 5 from its outcome and duration
+func reviewQuality(status string, duration time.Duration) int {
+	switch status {
+	case StatusCorrect:
+		if duration > 0 && duration <= targetDuration/2 {
+			return 5
+		}
+		if duration > 2*targetDuration {
+			return 3
+		}
+		return 4
+	case StatusIncorrect:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// nextReview applies an attempt to the previous schedule and returns the new one
+func nextReview(previous *ReviewSchedule, status string, duration time.Duration, attemptTime time.Time) *ReviewSchedule {
+	schedule := ReviewSchedule{EaseFactor: initialEaseFactor}
+	if previous != nil {
+		schedule = *previous
+	}
+
+	quality := reviewQuality(status, duration)
+
+	if quality < 3 {
+		// A miss starts the question over
+		schedule.Repetitions = 0
+		schedule.Interval = 1
+	} else {
+		switch schedule.Repetitions {
+		case 0:
+			schedule.Interval = 1
+		case 1:
+			schedule.Interval = 6
+		default:
+			schedule.Interval = int(math.Round(float64(schedule.Interval) * schedule.EaseFactor))
+		}
+		schedule.Repetitions++
+	}
+
+	miss := float64(5 - quality)
+	schedule.EaseFactor += 0.1 - miss*(0.08+miss*0.02)
+	if schedule.EaseFactor < minimumEaseFactor {
+		schedule.EaseFactor = minimumEaseFactor
+	}
+
+	if attemptTime.IsZero() {
+		attemptTime = time.Now()
+	}
+	schedule.NextDue = attemptTime.AddDate(0, 0, schedule.Interval)
+
+	return &schedule
+}
+
+// scheduleReview updates the review schedule of an engagement after it has been graded
+func (es *EngagementService) scheduleReview(ctx context.Context, engagement *Engagement) error {
+	var previous *ReviewSchedule
+
+	var existing Engagement
+	err := es.collection.FindOne(ctx, bson.M{"user_id": engagement.UserID, "question_id": engagement.QuestionID}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err == nil {
+		previous = existing.Review
+	}
+
+	status := ""
+	if engagement.Status != nil {
+		status = *engagement.Status
+	}
+
+	engagement.Review = nextReview(previous, status, engagement.Duration, engagement.AttemptTime)
+	return nil
+}
+
+// GetDueEngagements returns the user's engagements that are due for review by the given time, most overdue first
+func (es *EngagementService) GetDueEngagements(ctx context.Context, userID primitive.ObjectID, dueBy time.Time, limit int64) ([]*Engagement, error) {
+	filter := bson.M{
+		"user_id":         userID,
+		"review.next_due": bson.M{"$lte": dueBy},
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "review.next_due", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := es.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting due engagements: %w", err)
+	}
+
+	var engagements []*Engagement
+	if err = cursor.All(ctx, &engagements); err != nil {
+		return nil, err
+	}
+
+	return engagements, nil
+}
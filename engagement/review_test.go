@@ -0,0 +1,72 @@
+package engagement
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReviewQuality(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		duration time.Duration
+		want     int
+	}{
+		{"fast correct", StatusCorrect, 30 * time.Second, 5},
+		{"correct on target", StatusCorrect, 90 * time.Second, 4},
+		{"correct without a duration", StatusCorrect, 0, 4},
+		{"slow correct", StatusCorrect, 200 * time.Second, 3},
+		{"incorrect", StatusIncorrect, 30 * time.Second, 1},
+		{"omitted", StatusOmitted, 30 * time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reviewQuality(tt.status, tt.duration); got != tt.want {
+				t.Errorf("reviewQuality(%q, %v) = %d, want %d", tt.status, tt.duration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextReview(t *testing.T) {
+	attemptTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		previous        *ReviewSchedule
+		status          string
+		duration        time.Duration
+		wantInterval    int
+		wantRepetitions int
+		wantEaseFactor  float64
+	}{
+		{"first fast correct", nil, StatusCorrect, 30 * time.Second, 1, 1, 2.6},
+		{"second correct", &ReviewSchedule{EaseFactor: 2.5, Interval: 1, Repetitions: 1}, StatusCorrect, 60 * time.Second, 6, 2, 2.5},
+		{"third correct grows by ease", &ReviewSchedule{EaseFactor: 2.5, Interval: 6, Repetitions: 2}, StatusCorrect, 60 * time.Second, 15, 3, 2.5},
+		{"slow correct lowers ease", &ReviewSchedule{EaseFactor: 2.5, Interval: 6, Repetitions: 2}, StatusCorrect, 200 * time.Second, 15, 3, 2.36},
+		{"miss starts over", &ReviewSchedule{EaseFactor: 2.5, Interval: 15, Repetitions: 3}, StatusIncorrect, 60 * time.Second, 1, 0, 1.96},
+		{"ease has a floor", &ReviewSchedule{EaseFactor: 1.4, Interval: 6, Repetitions: 2}, StatusOmitted, 0, 1, 0, minimumEaseFactor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextReview(tt.previous, tt.status, tt.duration, attemptTime)
+			if got.Interval != tt.wantInterval || got.Repetitions != tt.wantRepetitions || math.Abs(got.EaseFactor-tt.wantEaseFactor) > 1e-9 {
+				t.Errorf("nextReview() = %+v, want interval %d, repetitions %d, ease %g", got, tt.wantInterval, tt.wantRepetitions, tt.wantEaseFactor)
+			}
+			if want := attemptTime.AddDate(0, 0, tt.wantInterval); !got.NextDue.Equal(want) {
+				t.Errorf("NextDue = %v, want %v", got.NextDue, want)
+			}
+		})
+	}
+
+	t.Run("previous schedule is not modified", func(t *testing.T) {
+		previous := &ReviewSchedule{EaseFactor: 2.5, Interval: 6, Repetitions: 2}
+		nextReview(previous, StatusIncorrect, 0, attemptTime)
+		if previous.Interval != 6 || previous.Repetitions != 2 {
+			t.Errorf("previous schedule changed to %+v", previous)
+		}
+	})
+}
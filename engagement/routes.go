@@ -6,18 +6,21 @@ import (
 	"reflect"
 	"strings"
 
+	"example/goserver/user"
+
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func RegisterRoutes(r *gin.Engine, engagementService *EngagementService) {
+func RegisterRoutes(r *gin.Engine, engagementService *EngagementService, userService *user.UserService) {
 	r.POST("/engagement", LogEngagementHandler(engagementService))
 	r.POST("/engagements", LogEngagementsHandler(engagementService)) // New route for logging multiple engagements
 
 	r.GET("/engagement/:id", GetEngagementByIDHandler(engagementService))
 	r.GET("/engagement", GetEngagementHandler(engagementService)) // New route for getting engagement by ID
 	r.GET("/engagements", GetEngagementsByIDHandler(engagementService))
+	r.GET("/engagements/:id/attempts", GetAttemptsHandler(engagementService, userService))
 	r.PATCH("/engagement/:id", UpdateEngagementHandler(engagementService))
 
 }
@@ -44,6 +47,11 @@ func GetEngagementsByIDHandler(service *EngagementService) gin.HandlerFunc {
 			return
 		}
 
+		// The attempt history is only returned by GetAttemptsHandler, which checks who is asking
+		for _, engagement := range engagements {
+			engagement.Attempts = nil
+		}
+
 		c.JSON(http.StatusOK, engagements)
 	}
 }
@@ -93,6 +101,11 @@ func LogEngagementHandler(service *EngagementService) gin.HandlerFunc {
 			return
 		}
 
+		if engagement.Status != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrClientStatus.Error()})
+			return
+		}
+
 		// Attempt to get user ID from context
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -111,6 +124,14 @@ func LogEngagementHandler(service *EngagementService) gin.HandlerFunc {
 		engagement.UserID = &userIDObjID
 
 		id, err := service.LogEngagement(c, &engagement)
+		if err == ErrQuizClosed {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err == ErrQuizNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -130,6 +151,13 @@ func LogEngagementsHandler(service *EngagementService) gin.HandlerFunc {
 			return
 		}
 
+		for _, engagement := range engagements {
+			if engagement.Status != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": ErrClientStatus.Error()})
+				return
+			}
+		}
+
 		// Get the UserID from the context
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -151,6 +179,14 @@ func LogEngagementsHandler(service *EngagementService) gin.HandlerFunc {
 		for i := range engagements {
 			engagements[i].UserID = &userIDObjID
 			id, err := service.LogEngagement(c, &engagements[i])
+			if err == ErrQuizClosed {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			if err == ErrQuizNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -187,10 +223,68 @@ func GetEngagementByIDHandler(service *EngagementService) gin.HandlerFunc {
 			return
 		}
 
+		// The attempt history is only returned by GetAttemptsHandler, which checks who is asking
+		engagement.Attempts = nil
+
 		c.JSON(http.StatusOK, engagement)
 	}
 }
 
+// GetAttemptsHandler returns the attempt history of an engagement.
+// Students can see their own attempts, tutors can see the attempts of students in their classes,
+// and super admins can see anyone's.
+func GetAttemptsHandler(service *EngagementService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idObj, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		engagement, err := service.GetEngagementByID(c, idObj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if engagement.UserID == nil || engagement.UserID.Hex() != userID.(string) {
+			role, err := userService.FetchUserRoleFromDB(c, userID.(string))
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+
+			allowed := role == user.RoleSuperAdmin
+			if !allowed && role == user.RoleTutor && engagement.UserID != nil {
+				tutorID, err := primitive.ObjectIDFromHex(userID.(string))
+				if err == nil {
+					allowed, err = service.IsTutorOf(c, tutorID, *engagement.UserID)
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+						return
+					}
+				}
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"EngagementID": engagement.ID,
+			"QuestionID":   engagement.QuestionID,
+			"Attempts":     engagement.AttemptHistory(),
+		})
+	}
+}
+
 // ConvertJSONToBSONFields converts JSON field names to BSON field names based on struct tags.
 func ConvertJSONToBSONFields(jsonFields map[string]interface{}, model interface{}) (bson.M, error) {
 	t := reflect.TypeOf(model)
@@ -218,23 +312,53 @@ func ConvertJSONToBSONFields(jsonFields map[string]interface{}, model interface{
 	return update, nil
 }
 
+// UpdateEngagementHandler updates one of the logged in user's engagements
 func UpdateEngagementHandler(service *EngagementService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		var jsonFields map[string]interface{}
 
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
 		if err := c.ShouldBindJSON(&jsonFields); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		if _, ok := jsonFields["Status"]; ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrClientStatus.Error()})
+			return
+		}
+
 		update, err := ConvertJSONToBSONFields(jsonFields, Engagement{})
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		result, err := service.UpdateEngagement(c, id, update)
+		result, err := service.UpdateEngagement(c, id, userIDObj, update)
+		if err == ErrQuizClosed || err == ErrNotEngagementOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err == ErrEngagementIdentity || err == ErrAnswerContext {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == ErrEngagementNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -2,6 +2,9 @@ package engagement
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,15 +13,52 @@ import (
 )
 
 type EngagementService struct {
-	collection *mongo.Collection
+	collection           *mongo.Collection
+	questionCollection   *mongo.Collection
+	quizCollection       *mongo.Collection
+	classCollection      *mongo.Collection
+	quizAnswerCollection *mongo.Collection
+	statusListeners      []StatusListener
 }
 
 // NewEngagementService creates a new engagement service
-func NewEngagementService(client *mongo.Client) *EngagementService {
+func NewEngagementService(ctx context.Context, client *mongo.Client) (*EngagementService, error) {
 	collection := client.Database("test").Collection("engagements")
+	questionCollection := client.Database("test").Collection("questions")
+	quizCollection := client.Database("test").Collection("quizzes")
+	classCollection := client.Database("test").Collection("classes")
+	quizAnswerCollection := client.Database("test").Collection("quiz_answers")
+
+	// A user has one answer per question in each quiz
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "quiz_id", Value: 1},
+			{Key: "question_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := quizAnswerCollection.Indexes().CreateOne(ctx, indexModel); err != nil {
+		return nil, fmt.Errorf("could not create index: %w", err)
+	}
+
 	return &EngagementService{
-		collection: collection,
+		collection:           collection,
+		questionCollection:   questionCollection,
+		quizCollection:       quizCollection,
+		classCollection:      classCollection,
+		quizAnswerCollection: quizAnswerCollection,
+	}, nil
+}
+
+// IsTutorOf reports whether the tutor runs a class the student is in.
+// The classes collection is read directly so this package does not depend on the classroom package.
+func (s *EngagementService) IsTutorOf(ctx context.Context, tutorID, studentID primitive.ObjectID) (bool, error) {
+	count, err := s.classCollection.CountDocuments(ctx, bson.M{"tutor_id": tutorID, "student_ids": studentID})
+	if err != nil {
+		return false, err
 	}
+	return count > 0, nil
 }
 
 func (s *EngagementService) GetEngagementCollection() *mongo.Collection {
@@ -41,22 +81,62 @@ func (es *EngagementService) LogEngagement(ctx context.Context, engagement *Enga
 	// If found, update the existing engagement with the new attempt.
 	// If not found, insert a new engagement.
 
+	// Answers for a quiz are only accepted while it is open, and a timed quiz only while its clock is running
+	if err := es.checkQuizOpen(ctx, engagement); err != nil {
+		return "", err
+	}
+
+	// The status is always graded on the server from the user's answer
+	key, err := es.gradeEngagement(ctx, engagement)
+	if err != nil {
+		return "", err
+	}
+
 	filter := bson.M{"user_id": engagement.UserID, "question_id": engagement.QuestionID}
 
-	// Define update operation
-	update := bson.M{"$set": engagement}
+	// The attempt history can only be appended to, never replaced by the client
+	engagement.Attempts = nil
+
+	// The answer is timed on the server, since trends, history and review schedules are bucketed by it
+	engagement.AttemptTime = time.Now()
+
+	if err := es.scheduleReview(ctx, engagement); err != nil {
+		return "", err
+	}
+
+	// Define update operation: the latest attempt becomes the summary and is added to the history
+	update := bson.M{
+		"$set":  engagement,
+		"$push": bson.M{"attempts": newAttempt(engagement)},
+	}
 
-	// Options for the update operation
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	// Options for the update operation. The engagement from before the update is returned so the status change can be published.
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
 
 	// Find and update existing engagement
-	var updatedEngagement Engagement
-	err := es.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedEngagement)
-	if err != nil {
+	var previousEngagement Engagement
+	err = es.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previousEngagement)
+	if err == mongo.ErrNoDocuments {
+		// This is the user's first answer to the question, so the engagement was just inserted
+		es.publishStatusChange(ctx, engagement, key, nil, *engagement.Status)
+		if err := es.recordQuizAnswer(ctx, engagement); err != nil {
+			return "", err
+		}
+
+		inserted, err := es.GetEngagementByUserAndQuestionID(ctx, engagement.UserID, engagement.QuestionID)
+		if err != nil {
+			return "", err
+		}
+		return inserted.ID.Hex(), nil
+	} else if err != nil {
 		return "", err
 	}
 
-	return updatedEngagement.ID.Hex(), nil
+	es.publishStatusChange(ctx, engagement, key, previousEngagement.Status, *engagement.Status)
+	if err := es.recordQuizAnswer(ctx, engagement); err != nil {
+		return "", err
+	}
+	return previousEngagement.ID.Hex(), nil
 }
 
 // GetEngagementByID retrieves an engagement from the database by ID
@@ -85,21 +165,151 @@ func (es *EngagementService) GetEngagementsByID(ctx context.Context, engagementI
 	return engagements, nil
 }
 
-// UpdateEngagement updates an engagement in the database
-func (es *EngagementService) UpdateEngagement(ctx context.Context, id string, update bson.M) (*mongo.UpdateResult, error) {
+var (
+	ErrEngagementNotFound = errors.New("engagement not found")
+	ErrNotEngagementOwner = errors.New("only the user who answered can update an engagement")
+	ErrEngagementIdentity = errors.New("the question and user of an engagement cannot be changed")
+	ErrAnswerContext      = errors.New("the quiz and mode of an answer cannot be changed")
+)
+
+// UpdateEngagement updates one of the user's engagements in the database
+func (es *EngagementService) UpdateEngagement(ctx context.Context, id string, userID primitive.ObjectID, update bson.M) (*mongo.UpdateResult, error) {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := es.collection.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": update}, options.Update().SetUpsert(true))
+	// Moving an engagement to another question or user would leave the status counted under the old one
+	for _, field := range []string{"_id", "question_id", "user_id"} {
+		if _, ok := update[field]; ok {
+			return nil, ErrEngagementIdentity
+		}
+	}
+	// Moving an answer into a quiz would skip the checks made when answers are logged for it
+	for _, field := range []string{"quiz_id", "mode"} {
+		if _, ok := update[field]; ok {
+			return nil, ErrAnswerContext
+		}
+	}
+	if _, ok := update["status"]; ok {
+		return nil, ErrClientStatus
+	}
+	if _, ok := update["attempts"]; ok {
+		return nil, errors.New("attempts cannot be updated")
+	}
+	if _, ok := update["review"]; ok {
+		return nil, errors.New("review schedule cannot be updated")
+	}
+	if _, ok := update["revision_id"]; ok {
+		return nil, errors.New("revision cannot be updated")
+	}
+	if _, ok := update["attempt_time"]; ok {
+		return nil, errors.New("attempt time is set by the server")
+	}
+
+	existing, err := es.GetEngagementByID(ctx, oid)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrEngagementNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	if existing.UserID == nil || *existing.UserID != userID {
+		return nil, ErrNotEngagementOwner
+	}
+
+	operation := bson.M{"$set": update}
+
+	// Set when the answer is regraded, so the status change can be published after the update
+	var regraded *Engagement
+	var key *answerKey
+	var previousStatus *string
+
+	// If the answer changes, regrade it against the question
+	if userAnswer, ok := update["user_answer"]; ok {
+		// Once the engagement's quiz has closed its result is final, so the new answer is given outside the quiz
+		err := es.checkQuizOpen(ctx, existing)
+		if err == ErrQuizClosed || err == ErrQuizNotFound {
+			existing.QuizID = nil
+			operation["$unset"] = bson.M{"quiz_id": ""}
+		} else if err != nil {
+			return nil, err
+		}
+
+		answer, _ := userAnswer.(string)
+		existing.UserAnswer = &answer
+		previousStatus = existing.Status
+		key, err = es.gradeEngagement(ctx, existing)
+		if err != nil {
+			return nil, err
+		}
+		update["status"] = *existing.Status
+		update["revision_id"] = existing.RevisionID
+
+		// A changed answer is a new attempt
+		existing.AttemptTime = time.Now()
+		update["attempt_time"] = existing.AttemptTime
+		// The previous attempt's duration does not apply to the new answer, so it is cleared if none is given
+		existing.Duration = 0
+		if duration, ok := update["duration"].(float64); ok {
+			existing.Duration = time.Duration(duration)
+		}
+		update["duration"] = existing.Duration
+		operation["$push"] = bson.M{"attempts": newAttempt(existing)}
+
+		if err := es.scheduleReview(ctx, existing); err != nil {
+			return nil, err
+		}
+		update["review"] = existing.Review
+		regraded = existing
+	}
+
+	result, err := es.collection.UpdateOne(ctx, bson.M{"_id": oid, "user_id": userID}, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	if regraded != nil {
+		es.publishStatusChange(ctx, regraded, key, previousStatus, *regraded.Status)
+		if err := es.recordQuizAnswer(ctx, regraded); err != nil {
+			return nil, err
+		}
+	}
 
 	return result, nil
 }
 
+// AttemptHistory returns the attempt history of an engagement, oldest first.
+// Engagements logged before attempts were recorded have their summary returned as a single attempt.
+func (engagement *Engagement) AttemptHistory() []Attempt {
+	if len(engagement.Attempts) == 0 && engagement.Status != nil {
+		return []Attempt{newAttempt(engagement)}
+	}
+	return engagement.Attempts
+}
+
+// GetCorrectQuestionIDs returns the IDs of the questions the user last answered correctly
+func (es *EngagementService) GetCorrectQuestionIDs(ctx context.Context, userID *primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := es.collection.Find(ctx, bson.M{"user_id": userID, "status": StatusCorrect}, options.Find().SetProjection(bson.M{"question_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var engagements []*Engagement
+	if err = cursor.All(ctx, &engagements); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(engagements))
+	for _, engagement := range engagements {
+		if engagement.QuestionID != nil {
+			ids = append(ids, *engagement.QuestionID)
+		}
+	}
+	return ids, nil
+}
+
 func (s *EngagementService) GetAttemptedQuestionIDs(ctx context.Context, userID *primitive.ObjectID) ([]*primitive.ObjectID, error) {
 	cursor, err := s.GetEngagementCollection().Find(ctx, bson.M{"user_id": userID})
 	if err != nil {
@@ -1,16 +1,18 @@
 package lessons
 
 import (
+	"example/goserver/user"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(publicRouter *gin.RouterGroup, lessonService *LessonService, courseService *CourseService) {
-	publicRouter.POST("/lesson", AddLessonHandler(lessonService))
+func RegisterRoutes(publicRouter *gin.RouterGroup, lessonService *LessonService, courseService *CourseService, userService *user.UserService) {
+	requireContentAdmin := user.RequireRole(userService, user.RoleContentAdmin)
+	publicRouter.POST("/lesson", requireContentAdmin, AddLessonHandler(lessonService))
 	publicRouter.GET("/lesson/:id", GetLessonHandler(lessonService))
-	publicRouter.PUT("/lesson/:id", UpdateLessonHandler(lessonService))
-	publicRouter.POST("/course", AddCourseHandler(courseService))
+	publicRouter.PUT("/lesson/:id", requireContentAdmin, UpdateLessonHandler(lessonService))
+	publicRouter.POST("/course", requireContentAdmin, AddCourseHandler(courseService))
 	publicRouter.GET("/course/:id", GetCourseHandler(courseService))
 }
 
@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"example/goserver/classroom"
+	"example/goserver/cohort"
 	"example/goserver/datacube"
 	"example/goserver/engagement"
 	"example/goserver/lessons"
 	"example/goserver/parameterdata"
+	"example/goserver/prediction"
 	"example/goserver/question" // replace with your project path
 	"example/goserver/quiz"     // replace with your project path
+	"example/goserver/review"
+	"example/goserver/scoring"
 	"example/goserver/test"
 	"example/goserver/upload" // replace with your project path
 	"example/goserver/user"   // replace with your project path
@@ -44,18 +49,44 @@ func main() {
 	}
 	defer client.Disconnect(ctx)
 
+	// Run a command-line tool instead of the server, e.g. "goserver import-questions questions.csv"
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), client, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Create a new UserService
 	userService := user.NewUserService(client)
+	if google := user.NewGoogleProviderFromEnv(); google != nil {
+		userService.RegisterIdentityProvider("google", google)
+	}
 
 	// Create a new QuestionService
-	questionService := question.NewQuestionService(client)
+	questionService, err := question.NewQuestionService(ctx, client)
+	if err != nil {
+		fmt.Println("Error creating question service:", err)
+		return
+	}
 
 	// Create a new EngagementService
-	engagementService := engagement.NewEngagementService(client) // Remove questionService parameter
+	engagementService, err := engagement.NewEngagementService(ctx, client)
+	if err != nil {
+		fmt.Println("Error creating engagement service:", err)
+		return
+	}
 
 	// Create a new DataCubeService
 	dataCubeService := datacube.NewDataCubeService(client, questionService)
 
+	// Keep the stored data cubes up to date as answers are graded
+	engagementService.OnStatusChange(dataCubeService.ApplyStatusChange)
+
+	// Move the counts when questions are created, edited, published, retired or deleted
+	questionService.OnQuestionChange(engagementService.ApplyQuestionChange)
+	questionService.OnQuestionChange(dataCubeService.ApplyQuestionChange)
+
 	lessonService := lessons.NewLessonService(client)
 	courseService := lessons.NewCourseService(client)
 
@@ -92,6 +123,33 @@ func main() {
 		return
 	}
 
+	scoringService, err := scoring.NewScoringService(ctx, client)
+	if err != nil {
+		fmt.Println("Error creating scoring service:", err)
+		return
+	}
+
+	classService, err := classroom.NewClassService(ctx, client)
+	if err != nil {
+		fmt.Println("Error creating class service:", err)
+		return
+	}
+
+	// Move users whose subscription has ended back to the free tier
+	go userService.RunSubscriptionExpiry(context.Background(), time.Hour)
+
+	// Submit timed test modules whose deadline has passed
+	go testService.RunDeadlineSweeper(context.Background(), time.Minute, quizService, questionService, engagementService)
+
+	// Recompute the cohort distributions used for peer comparison once a day
+	cohortService := cohort.NewCohortService(client)
+	go cohortService.RunCohortStats(context.Background(), 24*time.Hour, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+
+	// Recalculate score predictions once a user's practice session has ended
+	predictionService := prediction.NewPredictionService(client)
+	engagementService.OnStatusChange(predictionService.RecordActivity)
+	go predictionService.RunPredictionUpdater(context.Background(), 5*time.Minute, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+
 	// Set up Gin router
 	router := gin.Default()
 
@@ -111,23 +169,23 @@ func main() {
 
 	// Register routes
 	user.RegisterRoutes(router, userService)
-	engagement.RegisterRoutes(router, engagementService)
+	engagement.RegisterRoutes(router, engagementService, userService)
 
 	// Add the upload route
-	publicRoutes.POST("/upload", func(c *gin.Context) {
+	publicRoutes.POST("/upload", user.RequireRole(userService, user.RoleContentAdmin), func(c *gin.Context) {
 		upload.UploadHandler(c.Writer, c.Request)
 	})
 
 	// Create a group for routes that require JWT authentication
 	authenticated := router.Group("/")
-	authenticated.Use(user.JWTMiddleware(userService))
+	authenticated.Use(user.StrictJWTMiddleware(userService))
 	// Register routes that require authentication
-	question.RegisterRoutes(publicRoutes, authenticated, questionService, userService)
+	question.RegisterRoutes(publicRoutes, authenticated, questionService, userService, engagementService)
 
-	lessons.RegisterRoutes(publicRoutes, lessonService, courseService)
+	lessons.RegisterRoutes(publicRoutes, lessonService, courseService, userService)
 
 	// Add the datacube routes
-	datacube.RegisterRoutes(publicRoutes, authenticated, dataCubeService)
+	datacube.RegisterRoutes(publicRoutes, authenticated, dataCubeService, userService)
 
 	parameterdata.RegisterRoutes(publicRoutes, nil)
 
@@ -135,13 +193,23 @@ func main() {
 	// For example, if you have a route for getting a user's profile that requires authentication:
 	// authenticated.GET("/user/:id", func(c *gin.Context) { getUser(c, userService) })
 
-	video.RegisterRoutes(publicRoutes, videoService, youtubeService.Service)
+	video.RegisterRoutes(publicRoutes, videoService, youtubeService.Service, userService)
 
 	videoengagement.RegisterRoutes(publicRoutes, videoEngagementService)
 
-	quiz.RegisterRoutes(publicRoutes, quizService, questionService, engagementService)
+	quiz.RegisterRoutes(publicRoutes, quizService, questionService, engagementService, userService, dataCubeService)
+
+	review.RegisterRoutes(publicRoutes, engagementService, quizService)
+
+	scoring.RegisterRoutes(publicRoutes, scoringService, userService)
+
+	test.RegisterRoutes(publicRoutes, testService, quizService, questionService, engagementService, scoringService, userService)
+
+	classroom.RegisterRoutes(publicRoutes, classService, userService, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+
+	cohort.RegisterRoutes(publicRoutes, cohortService, userService, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
 
-	test.RegisterRoutes(publicRoutes, testService, quizService, questionService, engagementService)
+	prediction.RegisterRoutes(publicRoutes, predictionService, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
 
 	// Determine the port to listen on
 	port := os.Getenv("PORT")
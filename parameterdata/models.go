@@ -75,6 +75,44 @@ var ReadingTopicsList = []*Topic{
 	},
 }
 
+// TopicPath is where a topic sits in the topic lists
+type TopicPath struct {
+	Subject     string
+	ParentTopic string
+	// Subtopic is false for a parent topic, which is its own parent topic. Questions are tagged with subtopics.
+	Subtopic bool
+}
+
+// FindTopic returns the subject and parent topic of a topic or subtopic, and false if the topic is unknown.
+// Reading topics that are their own only subtopic are found as subtopics.
+func FindTopic(name string) (TopicPath, bool) {
+	subjects := []struct {
+		name   string
+		topics []*Topic
+	}{
+		{"Math", MathTopicsList},
+		{"Reading", ReadingTopicsList},
+	}
+
+	for _, subject := range subjects {
+		for _, parent := range subject.topics {
+			for _, child := range parent.Children {
+				if child.Name == name {
+					return TopicPath{Subject: subject.name, ParentTopic: parent.Name, Subtopic: true}, true
+				}
+			}
+		}
+	}
+	for _, subject := range subjects {
+		for _, parent := range subject.topics {
+			if parent.Name == name {
+				return TopicPath{Subject: subject.name, ParentTopic: parent.Name}, true
+			}
+		}
+	}
+	return TopicPath{}, false
+}
+
 type LessonModule struct {
 	Name     string   `json:"Name"`
 	VideoIDs []string `json:"VideoIDs"`
@@ -125,6 +163,49 @@ var PracticeModules = []*PracticeModule{
 	},
 }
 
+// AdaptiveSection is one section of a multistage test. Every student takes the routing module,
+// then either the easy or the hard second-stage module depending on their routing score.
+type AdaptiveSection struct {
+	Name             string   `json:"Name"`
+	RoutingModule    []string `json:"RoutingModule"`
+	EasyModule       []string `json:"EasyModule"`
+	HardModule       []string `json:"HardModule"`
+	RoutingThreshold float64  `json:"RoutingThreshold"` // fraction correct needed to be routed to the hard module
+}
+
+type AdaptiveTestRepresentation struct {
+	Name     string             `json:"Name"`
+	Sections []*AdaptiveSection `json:"Sections"`
+}
+
+var AdaptiveTests = []*AdaptiveTestRepresentation{
+	{
+		Name: "Adaptive practice test 1",
+		Sections: []*AdaptiveSection{
+			{
+				Name:             "Reading",
+				RoutingModule:    []string{"65bad3c908992ac645d86bc5", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				EasyModule:       []string{"65bae26b08992ac645d86bc7", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				HardModule:       []string{"65bae36808992ac645d86bc8", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				RoutingThreshold: 0.6,
+			},
+			{
+				Name:             "Math",
+				RoutingModule:    []string{"65bae54a08992ac645d86bc9", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				EasyModule:       []string{"65bae26b08992ac645d86bc7", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				HardModule:       []string{"65bae36808992ac645d86bc8", "65bae13d08992ac645d86bc6", "65bc543a08992ac645d86bed", "65bb098408992ac645d86bcd", "65bb1e5108992ac645d86bda"},
+				RoutingThreshold: 0.6,
+			},
+		},
+	},
+}
+
+// ModuleTimeLimits are the time limits for one module of each section, in minutes
+var ModuleTimeLimits = map[string]int{
+	"Reading": 32,
+	"Math":    35,
+}
+
 type TestRepresentation struct {
 	Name          string     `json:"Name"`
 	QuestionLists [][]string `json:"QuestionLists"`
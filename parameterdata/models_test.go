@@ -0,0 +1,23 @@
+package parameterdata
+
+import "testing"
+
+func TestFindTopic(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   TopicPath
+		wantOK bool
+	}{
+		{"Linear functions", TopicPath{Subject: "Math", ParentTopic: "Algebra", Subtopic: true}, true},
+		{"Algebra", TopicPath{Subject: "Math", ParentTopic: "Algebra"}, true},
+		{"Craft and structure", TopicPath{Subject: "Reading", ParentTopic: "Craft and structure", Subtopic: true}, true},
+		{"Calculus", TopicPath{}, false},
+		{"", TopicPath{}, false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := FindTopic(tt.name); got != tt.want || ok != tt.wantOK {
+			t.Errorf("FindTopic(%q) = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
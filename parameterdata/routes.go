@@ -12,7 +12,8 @@ func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup)
 	publicRouter.GET("/lessonmodule", getLessonModule())
 	publicRouter.GET("/practicemodule", getPracticeModule())
 	publicRouter.GET("/testrepresentation", getTestRepresentation())
-	// Existing code...
+	publicRouter.GET("/adaptivetestrepresentation", getAdaptiveTestRepresentation())
+	// All parameter data is compiled into the server, so there are no mutating routes to protect here
 }
 
 func getTestRepresentation() gin.HandlerFunc {
@@ -37,6 +38,36 @@ func getTestRepresentation() gin.HandlerFunc {
 	}
 }
 
+func getAdaptiveTestRepresentation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("name")
+		var testRepresentation *AdaptiveTestRepresentation
+		for _, representation := range AdaptiveTests {
+			if representation.Name == name {
+				testRepresentation = representation
+				break
+			}
+		}
+
+		if testRepresentation == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "adaptive test representation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, testRepresentation)
+	}
+}
+
+// FindAdaptiveTest returns the adaptive test with the given name, or nil if there is none
+func FindAdaptiveTest(name string) *AdaptiveTestRepresentation {
+	for _, representation := range AdaptiveTests {
+		if representation.Name == name {
+			return representation
+		}
+	}
+	return nil
+}
+
 func getTopicList() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -0,0 +1,44 @@
+package prediction
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WeakTopic is a topic holding the predicted score back
+type WeakTopic struct {
+	Topic     string  `json:"Topic" bson:"topic"`
+	Domain    string  `json:"Domain" bson:"domain"`
+	Accuracy  float64 `json:"Accuracy" bson:"accuracy"`   // smoothed proportion correct, weighted by difficulty
+	Attempted int     `json:"Attempted" bson:"attempted"` // questions answered in the topic
+	// PotentialGain is roughly how many scaled points mastering the topic would add
+	PotentialGain float64 `json:"PotentialGain" bson:"potential_gain"`
+}
+
+// SectionPrediction is the predicted scaled score for one section with a 95% confidence interval.
+// PracticeScore and TestScore are the two estimates it combines, when there was enough data for them.
+type SectionPrediction struct {
+	Section       string      `json:"Section" bson:"section"`
+	Score         int         `json:"Score" bson:"score"`
+	Low           int         `json:"Low" bson:"low"`
+	High          int         `json:"High" bson:"high"`
+	PracticeScore *int        `json:"PracticeScore,omitempty" bson:"practice_score,omitempty"`
+	TestScore     *int        `json:"TestScore,omitempty" bson:"test_score,omitempty"`
+	Responses     int         `json:"Responses" bson:"responses"`
+	Tests         int         `json:"Tests" bson:"tests"`
+	WeakTopics    []WeakTopic `json:"WeakTopics" bson:"weak_topics"`
+}
+
+// Prediction is a user's predicted test scores. It is stored so it only has to be recalculated
+// once the user finishes a practice session.
+type Prediction struct {
+	UserID   primitive.ObjectID  `json:"UserID" bson:"user_id"`
+	Sections []SectionPrediction `json:"Sections" bson:"sections"`
+	// The total is only predicted when both sections are
+	Total        *int      `json:"Total,omitempty" bson:"total,omitempty"`
+	TotalLow     *int      `json:"TotalLow,omitempty" bson:"total_low,omitempty"`
+	TotalHigh    *int      `json:"TotalHigh,omitempty" bson:"total_high,omitempty"`
+	LastActivity time.Time `json:"LastActivity" bson:"last_activity"`
+	ComputedAt   time.Time `json:"ComputedAt" bson:"computed_at"`
+}
@@ -0,0 +1,55 @@
+package prediction
+
+import (
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/test"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *PredictionService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) {
+	publicRouter.GET("/prediction", getPrediction(service, dataCubeService, testService, quizService, questionService, engagementService, scoringService))
+}
+
+// getPrediction returns the user's predicted scores. The prediction is recalculated after each practice session,
+// or straight away with /prediction?refresh=true
+func getPrediction(service *PredictionService, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var prediction *Prediction
+		if c.Query("refresh") != "true" {
+			prediction, err = service.GetPrediction(c, userIDObj)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if prediction == nil {
+			prediction, err = service.ComputePrediction(c, userIDObj, dataCubeService, testService, quizService, questionService, engagementService, scoringService)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, prediction)
+	}
+}
@@ -0,0 +1,499 @@
+package prediction
+
+import (
+	"context"
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/test"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// SessionGap is how long a user must be inactive before their practice session counts as finished
+	SessionGap = 30 * time.Minute
+	// MinPracticeResponses is the fewest answers in a section needed to predict its score from practice
+	MinPracticeResponses = 20
+	// MaxWeakTopics is how many weak topics are returned for each section
+	MaxWeakTopics = 3
+	// RecentWindow is how far back answers count towards the user's recent accuracy
+	RecentWindow = 14 * 24 * time.Hour
+
+	// testStandardError is the measurement error of a single practice test score, in scaled points
+	testStandardError = 40.0
+	// practiceModelError allows for practice questions not behaving exactly like test questions, in scaled points
+	practiceModelError = 40.0
+	// testHalfLife is how quickly older test scores lose weight
+	testHalfLife = 60 * 24 * time.Hour
+	// recentPriorWeight is how many recent answers it takes for recent accuracy to count as much as lifetime accuracy
+	recentPriorWeight = 20.0
+	z95               = 1.96
+)
+
+// domainWeights are the share of each section's questions in each domain on the digital SAT
+var domainWeights = map[string]float64{
+	"Algebra":                           0.35,
+	"Advanced math":                     0.35,
+	"Problem solving and data analysis": 0.15,
+	"Geometry and trigonometry":         0.15,
+	"Information and ideas":             0.26,
+	"Craft and structure":               0.28,
+	"Expression of ideas":               0.20,
+	"Standard English conventions":      0.26,
+}
+
+// difficultyMix is the share of test questions at each difficulty and the data cube column their accuracy is read from
+var difficultyMix = []struct {
+	column string
+	share  float64
+}{
+	{"easy", 0.3},
+	{"medium", 0.4},
+	{"hardextreme", 0.3},
+}
+
+// sections lists the domains in each scoring section
+var sections = []struct {
+	name    string
+	domains []*parameterdata.Topic
+}{
+	{scoring.SectionMath, parameterdata.MathTopicsList},
+	{scoring.SectionReading, parameterdata.ReadingTopicsList},
+}
+
+type PredictionService struct {
+	collection           *mongo.Collection
+	engagementCollection *mongo.Collection
+}
+
+func NewPredictionService(client *mongo.Client) *PredictionService {
+	collection := client.Database("test").Collection("score_predictions")
+	engagementCollection := client.Database("test").Collection("engagements")
+	return &PredictionService{collection: collection, engagementCollection: engagementCollection}
+}
+
+// RecordActivity notes when the user last answered a question, so their prediction is recalculated
+// once the session is over. It is registered as an engagement status listener.
+func (s *PredictionService) RecordActivity(ctx context.Context, change engagement.StatusChange) error {
+	if !change.Published {
+		return nil
+	}
+
+	// The server time is recorded rather than the time of the answer, which the client sets, so a
+	// timestamp in the future cannot keep the user from ever being swept for recalculation
+	update := bson.M{"$max": bson.M{"last_activity": time.Now()}}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"user_id": change.UserID}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error recording activity for prediction: %w", err)
+	}
+	return nil
+}
+
+// scoreEstimate is a scaled score estimate and its variance
+type scoreEstimate struct {
+	score    float64
+	variance float64
+}
+
+// combine takes the precision-weighted average of independent estimates
+func combine(estimates []scoreEstimate) scoreEstimate {
+	precision := 0.0
+	weighted := 0.0
+	for _, estimate := range estimates {
+		precision += 1 / estimate.variance
+		weighted += estimate.score / estimate.variance
+	}
+	return scoreEstimate{score: weighted / precision, variance: 1 / precision}
+}
+
+// practiceEstimate is the expected proportion of a section's test questions the user would answer correctly
+type practiceEstimate struct {
+	proportion float64
+	variance   float64
+	responses  int
+	topics     []WeakTopic
+}
+
+// estimatePractice predicts the proportion correct on a section from the user's data cube, weighting the smoothed
+// accuracy in each topic and difficulty by how often they appear on the test. Each topic's PotentialGain is
+// the proportion it would add if answered perfectly; it is converted to scaled points by the caller.
+func estimatePractice(dataCube *datacube.DataCube, domains []*parameterdata.Topic) practiceEstimate {
+	estimate := practiceEstimate{}
+
+	for _, domain := range domains {
+		if len(domain.Children) == 0 {
+			continue
+		}
+		topicWeight := domainWeights[domain.Name] / float64(len(domain.Children))
+
+		for _, topic := range domain.Children {
+			row := dataCube.Rows[topic.Name]
+			accuracy := 0.0
+			attempted := 0
+
+			for _, mix := range difficultyMix {
+				correct := datacube.CellValue(row.Cells["correct"], mix.column)
+				answered := datacube.CellValue(row.Cells["attempted"], mix.column)
+
+				// Laplace smoothing keeps a topic with a few answers from looking certain
+				p := (correct + 1) / (answered + 2)
+				weight := topicWeight * mix.share
+				estimate.proportion += weight * p
+				estimate.variance += weight * weight * p * (1 - p) / (answered + 3)
+
+				accuracy += mix.share * p
+				attempted += int(answered)
+			}
+
+			estimate.responses += attempted
+			estimate.topics = append(estimate.topics, WeakTopic{
+				Topic:         topic.Name,
+				Domain:        domain.Name,
+				Accuracy:      accuracy,
+				Attempted:     attempted,
+				PotentialGain: topicWeight * (1 - accuracy),
+			})
+		}
+	}
+
+	return estimate
+}
+
+// scaledAt converts a proportion correct into a scaled score using the section's conversion table
+func scaledAt(table *scoring.ConversionTable, proportion float64) float64 {
+	proportion = math.Max(0, math.Min(1, proportion))
+	return float64(table.Convert(proportion*float64(table.MaxRaw), table.MaxRaw))
+}
+
+// recentAnswers counts the questions the user has answered since the given time, by section, with the latest
+// status of each. Like the data cube it is compared with, a question answered several times counts once.
+func (s *PredictionService) recentAnswers(ctx context.Context, userID primitive.ObjectID, since time.Time) (map[string][2]float64, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"user_id": userID}},
+		{"$unwind": bson.M{"path": "$attempts", "preserveNullAndEmptyArrays": true}},
+		// Engagements from before attempts were recorded only have the summary fields
+		{"$project": bson.M{
+			"question_id": 1,
+			"status":      bson.M{"$ifNull": bson.A{"$attempts.status", "$status"}},
+			"time":        bson.M{"$ifNull": bson.A{"$attempts.attempt_time", "$attempt_time"}},
+		}},
+		{"$match": bson.M{
+			"time":   bson.M{"$gte": since},
+			"status": bson.M{"$in": bson.A{engagement.StatusCorrect, engagement.StatusIncorrect, engagement.StatusOmitted}},
+		}},
+		{"$sort": bson.M{"time": 1}},
+		{"$group": bson.M{
+			"_id":    "$question_id",
+			"status": bson.M{"$last": "$status"},
+		}},
+		{"$lookup": bson.M{
+			"from":         "questions",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "question",
+		}},
+		{"$unwind": "$question"},
+		{"$match": bson.M{"question.state": question.PublishedFilter()}},
+		{"$group": bson.M{
+			"_id":      "$question.topic",
+			"answered": bson.M{"$sum": 1},
+			"correct":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", engagement.StatusCorrect}}, 1, 0}}},
+		}},
+	}
+
+	cursor, err := s.engagementCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error getting recent answers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var topics []struct {
+		Topic    string `bson:"_id"`
+		Answered int    `bson:"answered"`
+		Correct  int    `bson:"correct"`
+	}
+	if err = cursor.All(ctx, &topics); err != nil {
+		return nil, err
+	}
+
+	// Each section maps to its correct and answered counts
+	counts := make(map[string][2]float64)
+	for _, topic := range topics {
+		section := test.SectionForTopic(topic.Topic)
+		if section == "" {
+			continue
+		}
+		count := counts[section]
+		count[0] += float64(topic.Correct)
+		count[1] += float64(topic.Answered)
+		counts[section] = count
+	}
+	return counts, nil
+}
+
+// testEstimate averages the section scores of the user's completed tests, giving recent tests more weight
+func testEstimate(results []*test.TestResult, section string, now time.Time) (*scoreEstimate, int) {
+	totalWeight := 0.0
+	squaredWeight := 0.0
+	weighted := 0.0
+	count := 0
+
+	for _, result := range results {
+		answered := false
+		for _, stat := range result.TestStats.Stats {
+			if stat.Name == section && stat.Total > 0 {
+				answered = true
+			}
+		}
+		if !answered {
+			continue
+		}
+
+		score := result.MathScaled
+		if section == scoring.SectionReading {
+			score = result.ReadingScaled
+		}
+
+		age := now.Sub(result.Test.AttemptTime)
+		weight := math.Pow(0.5, age.Hours()/testHalfLife.Hours())
+		totalWeight += weight
+		squaredWeight += weight * weight
+		weighted += weight * score
+		count++
+	}
+
+	if count == 0 {
+		return nil, 0
+	}
+
+	return &scoreEstimate{
+		score:    weighted / totalWeight,
+		variance: testStandardError * testStandardError * squaredWeight / (totalWeight * totalWeight),
+	}, count
+}
+
+// roundScore rounds a score to the nearest 10, within the given range
+func roundScore(score, min, max float64) int {
+	score = math.Max(min, math.Min(max, score))
+	return int(math.Round(score/10) * 10)
+}
+
+// predictSection combines the practice and test estimates for one section, returning the prediction and the
+// variance of its score, or nil when there are neither
+func predictSection(section string, domains []*parameterdata.Topic, table *scoring.ConversionTable, dataCube *datacube.DataCube, recent [2]float64, results []*test.TestResult, now time.Time) (*SectionPrediction, float64) {
+	prediction := &SectionPrediction{Section: section, WeakTopics: []WeakTopic{}}
+	var estimates []scoreEstimate
+
+	practice := estimatePractice(dataCube, domains)
+	prediction.Responses = practice.responses
+
+	if practice.responses >= MinPracticeResponses {
+		// Students who have improved lately answer more correctly now than their lifetime accuracy suggests
+		subject := dataCube.Rows[section]
+		lifetimeAnswered := datacube.CellValue(subject.Cells["attempted"], "total")
+		if recent[1] > 0 && lifetimeAnswered > 0 {
+			lifetimeAccuracy := datacube.CellValue(subject.Cells["correct"], "total") / lifetimeAnswered
+			recentWeight := recent[1] / (recent[1] + recentPriorWeight)
+			practice.proportion += recentWeight * (recent[0]/recent[1] - lifetimeAccuracy)
+		}
+		practice.proportion = math.Max(0, math.Min(1, practice.proportion))
+
+		// The spread of the proportion is carried through the conversion curve to scaled points
+		spread := z95 * math.Sqrt(practice.variance)
+		low := scaledAt(table, practice.proportion-spread)
+		high := scaledAt(table, practice.proportion+spread)
+		conversionError := (high - low) / (2 * z95)
+
+		score := scaledAt(table, practice.proportion)
+		practiceScore := int(score)
+		prediction.PracticeScore = &practiceScore
+		estimates = append(estimates, scoreEstimate{
+			score:    score,
+			variance: conversionError*conversionError + practiceModelError*practiceModelError,
+		})
+
+		// Topic gains are proportions of the section, so they are scaled by the slope of the curve around the estimate
+		lower := math.Max(0, practice.proportion-0.05)
+		upper := math.Min(1, practice.proportion+0.05)
+		slope := (scaledAt(table, upper) - scaledAt(table, lower)) / (upper - lower)
+		for i := range practice.topics {
+			practice.topics[i].PotentialGain = math.Round(practice.topics[i].PotentialGain * slope)
+		}
+		sort.SliceStable(practice.topics, func(i, j int) bool {
+			return practice.topics[i].PotentialGain > practice.topics[j].PotentialGain
+		})
+		for _, topic := range practice.topics {
+			if len(prediction.WeakTopics) == MaxWeakTopics || topic.PotentialGain <= 0 {
+				break
+			}
+			prediction.WeakTopics = append(prediction.WeakTopics, topic)
+		}
+	}
+
+	testScore, tests := testEstimate(results, section, now)
+	prediction.Tests = tests
+	if testScore != nil {
+		score := roundScore(testScore.score, scoring.MinSectionScore, scoring.MaxSectionScore)
+		prediction.TestScore = &score
+		estimates = append(estimates, *testScore)
+	}
+
+	if len(estimates) == 0 {
+		return nil, 0
+	}
+
+	combined := combine(estimates)
+	spread := z95 * math.Sqrt(combined.variance)
+	prediction.Score = roundScore(combined.score, scoring.MinSectionScore, scoring.MaxSectionScore)
+	prediction.Low = roundScore(combined.score-spread, scoring.MinSectionScore, scoring.MaxSectionScore)
+	prediction.High = roundScore(combined.score+spread, scoring.MinSectionScore, scoring.MaxSectionScore)
+
+	return prediction, combined.variance
+}
+
+// ComputePrediction predicts the user's section and total scores from their data cube, recent answers and
+// completed tests, and stores the prediction
+func (s *PredictionService) ComputePrediction(ctx context.Context, userID primitive.ObjectID, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) (*Prediction, error) {
+	// Answers given while the prediction is computed are newer than ComputedAt, so they trigger another recalculation
+	now := time.Now()
+
+	dataCube, _ := dataCubeService.GetDataCube(&userID)
+	if dataCube == nil {
+		var err error
+		dataCube, err = dataCubeService.ComputeDataCube(&userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recent, err := s.recentAnswers(ctx, userID, now.Add(-RecentWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	tests, err := testService.GetCompletedTests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*test.TestResult, 0, len(tests))
+	for _, completed := range tests {
+		result, err := testService.GetTestUnderlying(ctx, quizService, questionService, engagementService, scoringService, completed)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	prediction := &Prediction{UserID: userID, Sections: []SectionPrediction{}, ComputedAt: now}
+	total := 0.0
+	totalVariance := 0.0
+	for _, section := range sections {
+		table, err := scoringService.GetTable(ctx, "", section.name)
+		if err != nil {
+			return nil, err
+		}
+
+		sectionPrediction, variance := predictSection(section.name, section.domains, table, dataCube, recent[section.name], results, now)
+		if sectionPrediction == nil {
+			continue
+		}
+		prediction.Sections = append(prediction.Sections, *sectionPrediction)
+		total += float64(sectionPrediction.Score)
+		totalVariance += variance
+	}
+
+	if len(prediction.Sections) == len(sections) {
+		spread := z95 * math.Sqrt(totalVariance)
+		minTotal := float64(scoring.MinSectionScore * len(sections))
+		maxTotal := float64(scoring.MaxSectionScore * len(sections))
+		totalScore := roundScore(total, minTotal, maxTotal)
+		totalLow := roundScore(total-spread, minTotal, maxTotal)
+		totalHigh := roundScore(total+spread, minTotal, maxTotal)
+		prediction.Total = &totalScore
+		prediction.TotalLow = &totalLow
+		prediction.TotalHigh = &totalHigh
+	}
+
+	// last_activity is left alone, as it is only ever moved forward by RecordActivity
+	update := bson.M{"$set": bson.M{
+		"sections":    prediction.Sections,
+		"total":       prediction.Total,
+		"total_low":   prediction.TotalLow,
+		"total_high":  prediction.TotalHigh,
+		"computed_at": prediction.ComputedAt,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored Prediction
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, update, opts).Decode(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("error saving prediction: %w", err)
+	}
+
+	return &stored, nil
+}
+
+// GetPrediction returns the stored prediction for a user, or nil if it has not been computed yet
+func (s *PredictionService) GetPrediction(ctx context.Context, userID primitive.ObjectID) (*Prediction, error) {
+	var prediction Prediction
+	err := s.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&prediction)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting prediction for user: %w", err)
+	}
+
+	// A user who has answered questions but not finished a session yet has no prediction
+	if prediction.ComputedAt.IsZero() {
+		return nil, nil
+	}
+	return &prediction, nil
+}
+
+// RunPredictionUpdater recalculates every interval the predictions of users whose session has ended since their
+// prediction was last computed, until the context is cancelled
+func (s *PredictionService) RunPredictionUpdater(ctx context.Context, interval time.Duration, dataCubeService *datacube.DataCubeService, testService *test.TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			filter := bson.M{
+				"last_activity": bson.M{"$lte": time.Now().Add(-SessionGap)},
+				"$or": bson.A{
+					bson.M{"computed_at": bson.M{"$exists": false}},
+					bson.M{"$expr": bson.M{"$gt": bson.A{"$last_activity", "$computed_at"}}},
+				},
+			}
+			userIDs, err := s.collection.Distinct(ctx, "user_id", filter)
+			if err != nil {
+				fmt.Println("Error finding finished sessions:", err)
+				continue
+			}
+
+			for _, value := range userIDs {
+				userID, ok := value.(primitive.ObjectID)
+				if !ok {
+					continue
+				}
+				if _, err := s.ComputePrediction(ctx, userID, dataCubeService, testService, quizService, questionService, engagementService, scoringService); err != nil {
+					fmt.Println("Error updating prediction:", userID.Hex(), err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package prediction
+
+import (
+	"example/goserver/datacube"
+	"example/goserver/parameterdata"
+	"example/goserver/scoring"
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name         string
+		estimates    []scoreEstimate
+		wantScore    float64
+		wantVariance float64
+	}{
+		{"single estimate", []scoreEstimate{{score: 600, variance: 400}}, 600, 400},
+		{"equal variances average", []scoreEstimate{{score: 500, variance: 100}, {score: 700, variance: 100}}, 600, 50},
+		{"precise estimate counts more", []scoreEstimate{{score: 500, variance: 100}, {score: 800, variance: 200}}, 600, 200.0 / 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := combine(tt.estimates)
+			if !almostEqual(got.score, tt.wantScore) || !almostEqual(got.variance, tt.wantVariance) {
+				t.Errorf("combine() = %+v, want score %g variance %g", got, tt.wantScore, tt.wantVariance)
+			}
+		})
+	}
+}
+
+func TestScaledAt(t *testing.T) {
+	table := &scoring.ConversionTable{
+		MaxRaw:  100,
+		Entries: []scoring.ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 50, Scaled: 500}, {Raw: 100, Scaled: 800}},
+	}
+
+	tests := []struct {
+		proportion float64
+		want       float64
+	}{
+		{0, 200},
+		{0.25, 350},
+		{0.5, 500},
+		{0.9, 740},
+		{1, 800},
+		{-0.5, 200},
+		{1.5, 800},
+	}
+
+	for _, tt := range tests {
+		if got := scaledAt(table, tt.proportion); got != tt.want {
+			t.Errorf("scaledAt(%g) = %g, want %g", tt.proportion, got, tt.want)
+		}
+	}
+}
+
+func cubeWith(topic string, correct, attempted map[string]float64) *datacube.DataCube {
+	cell := func(values map[string]float64) datacube.Cell {
+		cell := datacube.Cell{Values: map[string]*float64{}}
+		for column, value := range values {
+			value := value
+			cell.Values[column] = &value
+		}
+		return cell
+	}
+
+	return &datacube.DataCube{Rows: map[string]datacube.Row{
+		topic: {Cells: map[string]datacube.Cell{"correct": cell(correct), "attempted": cell(attempted)}},
+	}}
+}
+
+func TestEstimatePractice(t *testing.T) {
+	domains := []*parameterdata.Topic{
+		{Name: "Algebra", Children: []*parameterdata.Topic{{Name: "First"}, {Name: "Second"}}},
+		{Name: "Geometry and trigonometry"},
+	}
+	topicWeight := domainWeights["Algebra"] / 2
+
+	t.Run("no answers", func(t *testing.T) {
+		got := estimatePractice(&datacube.DataCube{}, domains)
+		if got.responses != 0 {
+			t.Errorf("responses = %d, want 0", got.responses)
+		}
+		// Smoothing puts every topic without answers at one half
+		if !almostEqual(got.proportion, domainWeights["Algebra"]*0.5) {
+			t.Errorf("proportion = %g, want %g", got.proportion, domainWeights["Algebra"]*0.5)
+		}
+		if len(got.topics) != 2 {
+			t.Fatalf("got %d topics, want 2", len(got.topics))
+		}
+		for _, topic := range got.topics {
+			if !almostEqual(topic.Accuracy, 0.5) || !almostEqual(topic.PotentialGain, topicWeight*0.5) {
+				t.Errorf("topic %s = %+v, want accuracy 0.5 and gain %g", topic.Topic, topic, topicWeight*0.5)
+			}
+		}
+	})
+
+	t.Run("answers in one topic", func(t *testing.T) {
+		cube := cubeWith("First", map[string]float64{"easy": 8, "hardextreme": 0}, map[string]float64{"easy": 8, "hardextreme": 2})
+		got := estimatePractice(cube, domains)
+
+		if got.responses != 10 {
+			t.Errorf("responses = %d, want 10", got.responses)
+		}
+
+		// easy (8+1)/(8+2), medium 1/2, hard and extreme (0+1)/(2+2)
+		firstAccuracy := 0.3*0.9 + 0.4*0.5 + 0.3*0.25
+		if first := got.topics[0]; first.Topic != "First" || first.Attempted != 10 || !almostEqual(first.Accuracy, firstAccuracy) {
+			t.Errorf("first topic = %+v, want 10 attempted at accuracy %g", first, firstAccuracy)
+		}
+		if second := got.topics[1]; second.Attempted != 0 || !almostEqual(second.Accuracy, 0.5) {
+			t.Errorf("second topic = %+v, want no answers at accuracy 0.5", second)
+		}
+
+		want := topicWeight*firstAccuracy + topicWeight*0.5
+		if !almostEqual(got.proportion, want) {
+			t.Errorf("proportion = %g, want %g", got.proportion, want)
+		}
+		if got.variance <= 0 {
+			t.Errorf("variance = %g, want it to be positive", got.variance)
+		}
+	})
+}
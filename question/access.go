@@ -0,0 +1,91 @@
+package question
+
+import (
+	"example/goserver/user"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AccessPaid marks questions that only paid users can see in full
+const AccessPaid = "paid"
+
+// AccessPolicy decides how much of a question a user may see.
+// Every code path that returns questions to a client must pass them through a policy.
+type AccessPolicy struct {
+	// FullAccess is true for paid users and staff
+	FullAccess bool
+	// Drafts is true for content admins, who can see questions that are not published
+	Drafts bool
+}
+
+// PolicyForTier returns the policy for a user of the given tier
+func PolicyForTier(tier string) AccessPolicy {
+	return AccessPolicy{FullAccess: tier == user.TierPaid}
+}
+
+// PolicyFor returns the policy for the user making the request.
+// Tutors and admins can see everything so they can review and write content, whatever their tier.
+func PolicyFor(c *gin.Context, userService *user.UserService) AccessPolicy {
+	if userID, exists := c.Get("userID"); exists {
+		role, err := userService.FetchUserRoleFromDB(c.Request.Context(), userID.(string))
+		if err == nil && role != user.RoleStudent {
+			drafts := role == user.RoleContentAdmin || role == user.RoleSuperAdmin
+			return AccessPolicy{FullAccess: true, Drafts: drafts}
+		}
+	}
+
+	return PolicyForTier(userService.GetUserTier(c))
+}
+
+// IsPaid reports whether a question is paid content
+func (q *Question) IsPaid() bool {
+	return q.AccessOption != nil && *q.AccessOption == AccessPaid
+}
+
+// CanView reports whether the user may see the full question
+func (p AccessPolicy) CanView(q *Question) bool {
+	return p.FullAccess || q == nil || !q.IsPaid()
+}
+
+// Apply returns the question as the user may see it. Paid questions are redacted for free users:
+// the metadata needed to list them is kept, and the content and answers are removed.
+func (p AccessPolicy) Apply(q *Question) *Question {
+	if p.CanView(q) {
+		return q
+	}
+
+	return &Question{
+		ID:             q.ID,
+		AnswerType:     q.AnswerType,
+		Subject:        q.Subject,
+		Topic:          q.Topic,
+		Difficulty:     q.Difficulty,
+		AccessOption:   q.AccessOption,
+		CreationDate:   q.CreationDate,
+		LastEditedDate: q.LastEditedDate,
+		Locked:         true,
+	}
+}
+
+// ApplyAll applies the policy to each question in place
+func (p AccessPolicy) ApplyAll(questions []Question) {
+	for i := range questions {
+		questions[i] = *p.Apply(&questions[i])
+	}
+}
+
+// redactedDocumentFields are the fields removed from paid questions returned as documents by aggregation pipelines
+var redactedDocumentFields = []string{"Prompt", "AnswerChoices", "CorrectAnswerMultiple", "CorrectAnswerFree", "Text", "Explanation", "Images"}
+
+// ApplyDocument applies the policy to a question document with JSON field names, as produced by generateProjectStage
+func (p AccessPolicy) ApplyDocument(document bson.M) {
+	if p.FullAccess || document == nil || document["AccessOption"] != AccessPaid {
+		return
+	}
+
+	for _, field := range redactedDocumentFields {
+		delete(document, field)
+	}
+	document["Locked"] = true
+}
@@ -0,0 +1,350 @@
+package question
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	FormatJSONLines = "jsonl"
+	FormatCSV       = "csv"
+	FormatQTI       = "qti"
+)
+
+// choiceSeparator separates answer choices in the AnswerChoices column of a CSV file, so choices cannot contain it
+const choiceSeparator = "|"
+
+// csvColumns are the columns of an exported CSV file. Imports accept them in any order.
+var csvColumns = []string{"id", "Prompt", "Text", "AnswerType", "AnswerChoices", "CorrectAnswerMultiple", "CorrectAnswerFree", "Subject", "Topic", "Difficulty", "AccessOption", "Explanation"}
+
+// importContentFields are the question fields an import may set on an existing question.
+// The review workflow and revision fields are never taken from the file.
+var importContentFields = []string{"prompt", "text", "answer_type", "answer_choices", "correct_answer_multiple", "correct_answer_free", "subject", "topic", "difficulty", "access_option", "explanation", "images"}
+
+// ImportRow is one parsed row of an import file, with the problems found in it
+type ImportRow struct {
+	Row      int          `json:"Row"`
+	Question *Question    `json:"-"`
+	Errors   []FieldError `json:"Errors,omitempty"`
+}
+
+// ImportReport summarizes a bulk import. In a dry run nothing is written.
+type ImportReport struct {
+	DryRun  bool        `json:"DryRun"`
+	Total   int         `json:"Total"`
+	Created int         `json:"Created"`
+	Updated int         `json:"Updated"`
+	Failed  int         `json:"Failed"`
+	Errors  []ImportRow `json:"Errors"`
+}
+
+// DetectFormat guesses the import format from a file name
+func DetectFormat(filename string) string {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".csv"):
+		return FormatCSV
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".xml"):
+		return FormatQTI
+	default:
+		return FormatJSONLines
+	}
+}
+
+// ParseImport parses an import file in the given format into rows
+func ParseImport(format string, data []byte) ([]ImportRow, error) {
+	switch format {
+	case FormatJSONLines:
+		return parseJSONLines(bytes.NewReader(data))
+	case FormatCSV:
+		return parseCSV(bytes.NewReader(data))
+	case FormatQTI:
+		return parseQTI(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// parseJSONLines reads one question per line. Blank lines are skipped.
+func parseJSONLines(r io.Reader) ([]ImportRow, error) {
+	var rows []ImportRow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		q, errs := DecodeQuestion([]byte(text))
+		rows = append(rows, ImportRow{Row: line, Question: q, Errors: errs})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// parseCSV reads a CSV file with a header row naming the columns
+func parseCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if !contains(csvColumns, header[i]) {
+			return nil, fmt.Errorf("unknown column %q", header[i])
+		}
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows = append(rows, ImportRow{Row: line, Errors: []FieldError{{Message: err.Error()}}})
+			continue
+		}
+
+		var q Question
+		var rowErrors []FieldError
+		for i, value := range record {
+			if i >= len(header) {
+				rowErrors = append(rowErrors, FieldError{Message: "row has more fields than the header"})
+				break
+			}
+			if value == "" {
+				continue
+			}
+			value := value
+
+			switch header[i] {
+			case "id":
+				id, err := primitive.ObjectIDFromHex(value)
+				if err != nil {
+					rowErrors = append(rowErrors, FieldError{Field: "id", Message: "invalid ID"})
+					continue
+				}
+				q.ID = &id
+			case "Prompt":
+				q.Prompt = &value
+			case "Text":
+				q.Text = &value
+			case "AnswerType":
+				q.AnswerType = &value
+			case "AnswerChoices":
+				choices := strings.Split(value, choiceSeparator)
+				for j := range choices {
+					choices[j] = strings.TrimSpace(choices[j])
+				}
+				q.AnswerChoices = &choices
+			case "CorrectAnswerMultiple":
+				q.CorrectAnswerMultiple = &value
+			case "CorrectAnswerFree":
+				q.CorrectAnswerFree = &value
+			case "Subject":
+				q.Subject = &value
+			case "Topic":
+				q.Topic = &value
+			case "Difficulty":
+				q.Difficulty = &value
+			case "AccessOption":
+				q.AccessOption = &value
+			case "Explanation":
+				q.Explanation = &value
+			}
+		}
+
+		rows = append(rows, ImportRow{Row: line, Question: &q, Errors: rowErrors})
+	}
+
+	return rows, nil
+}
+
+// ImportQuestions validates the rows and saves the valid ones. Rows with the ID of an existing question update the
+// content fields they contain; others are created as drafts. Invalid rows are reported and skipped.
+// With dryRun nothing is saved. Each saved question gets a new revision by the author.
+func (s *QuestionService) ImportQuestions(ctx context.Context, rows []ImportRow, dryRun bool, author *primitive.ObjectID) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Total: len(rows), Errors: []ImportRow{}}
+	now := time.Now()
+
+	for _, row := range rows {
+		if row.Question != nil {
+			row.Errors = append(row.Errors, ValidateQuestion(row.Question)...)
+		}
+		if len(row.Errors) > 0 {
+			report.Failed++
+			report.Errors = append(report.Errors, row)
+			continue
+		}
+
+		// Imported questions go through review like any other, whatever the file says
+		q := row.Question
+		draft := StateDraft
+		q.State = &draft
+		q.Reviewers = nil
+		q.Comments = nil
+		q.RevisionID = nil
+		q.Revision = 0
+		q.CreationDate = now
+		q.LastEditedDate = now
+
+		var existing *Question
+		if q.ID != nil {
+			found, err := s.GetQuestion(ctx, *q.ID)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			existing = found
+		}
+
+		if existing == nil {
+			report.Created++
+			if dryRun {
+				continue
+			}
+			if _, err := s.CreateQuestion(ctx, q); err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			if _, err := s.SaveRevision(ctx, *q.ID, author, "Imported"); err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			continue
+		}
+
+		report.Updated++
+		if dryRun {
+			continue
+		}
+
+		set, err := importContent(q)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+		if _, err := s.collection.UpdateOne(ctx, bson.M{"_id": q.ID}, bson.M{"$set": set}); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+		updated, err := s.GetQuestion(ctx, *q.ID)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+		s.publishQuestionChange(ctx, *q.ID, existing, updated)
+		if _, err := s.SaveRevision(ctx, *q.ID, author, "Imported"); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+	}
+
+	return report, nil
+}
+
+// importContent returns the content fields set in an imported question, for updating an existing question
+func importContent(q *Question) (bson.M, error) {
+	data, err := bson.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"last_edited_date": q.LastEditedDate}
+	for _, field := range importContentFields {
+		if value, ok := fields[field]; ok {
+			set[field] = value
+		}
+	}
+	return set, nil
+}
+
+// FindQuestions returns the questions matching the optional subject and topic, for export
+func (s *QuestionService) FindQuestions(ctx context.Context, subject string, topic string) ([]Question, error) {
+	filter := bson.M{}
+	if subject != "" {
+		filter["subject"] = subject
+	}
+	if topic != "" {
+		filter["topic"] = topic
+	}
+
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	questions := []Question{}
+	if err := cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// ExportQuestions writes the questions in the given format, which can be imported again
+func ExportQuestions(w io.Writer, format string, questions []Question) error {
+	switch format {
+	case FormatJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, q := range questions {
+			if err := encoder.Encode(q); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		return exportCSV(w, questions)
+	case FormatQTI:
+		return exportQTI(w, questions)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func exportCSV(w io.Writer, questions []Question) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, q := range questions {
+		id := ""
+		if q.ID != nil {
+			id = q.ID.Hex()
+		}
+		choices := ""
+		if q.AnswerChoices != nil {
+			choices = strings.Join(*q.AnswerChoices, choiceSeparator)
+		}
+
+		record := []string{id, deref(q.Prompt), deref(q.Text), deref(q.AnswerType), choices, deref(q.CorrectAnswerMultiple), deref(q.CorrectAnswerFree), deref(q.Subject), deref(q.Topic), deref(q.Difficulty), deref(q.AccessOption), deref(q.Explanation)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
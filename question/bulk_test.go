@@ -0,0 +1,178 @@
+package question
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"questions.csv", FormatCSV},
+		{"QUESTIONS.CSV", FormatCSV},
+		{"package.zip", FormatQTI},
+		{"item.xml", FormatQTI},
+		{"questions.jsonl", FormatJSONLines},
+		{"questions", FormatJSONLines},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONLines(t *testing.T) {
+	data := "{\"Prompt\":\"first\"}\n\n  \n{\"Prompt\":\"second\",\"Bogus\":1}\n{\"Prompt\":\"third\"}"
+
+	rows, err := parseJSONLines(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseJSONLines() error = %v", err)
+	}
+
+	wantRows := []int{1, 4, 5}
+	if len(rows) != len(wantRows) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(wantRows))
+	}
+	for i, row := range rows {
+		if row.Row != wantRows[i] {
+			t.Errorf("row %d numbered %d, want the line number %d", i, row.Row, wantRows[i])
+		}
+	}
+	if rows[0].Question == nil || *rows[0].Question.Prompt != "first" || len(rows[0].Errors) > 0 {
+		t.Errorf("first row = %+v, want prompt first without errors", rows[0])
+	}
+	if len(rows[1].Errors) != 1 || rows[1].Errors[0].Field != "Bogus" {
+		t.Errorf("second row errors = %v, want unknown field Bogus", rows[1].Errors)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	t.Run("columns in any order", func(t *testing.T) {
+		data := "\ufeffTopic,Prompt,AnswerType,AnswerChoices,CorrectAnswerMultiple,Difficulty,id\n" +
+			"Circles,What is the area?,multiple-choice, pi | 2pi ,A,easy," + id.Hex() + "\n" +
+			"Percentages,\"Compute 10%, then round\",free-response,,,medium,\n"
+
+		rows, err := parseCSV(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("parseCSV() error = %v", err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("got %d rows, want 2", len(rows))
+		}
+
+		first := rows[0]
+		if first.Row != 2 || len(first.Errors) > 0 {
+			t.Errorf("first row = %+v, want row 2 without errors", first)
+		}
+		if first.Question.ID == nil || *first.Question.ID != id {
+			t.Errorf("ID = %v, want %s", first.Question.ID, id.Hex())
+		}
+		if !reflect.DeepEqual(*first.Question.AnswerChoices, []string{"pi", "2pi"}) {
+			t.Errorf("AnswerChoices = %v, want [pi 2pi]", *first.Question.AnswerChoices)
+		}
+
+		second := rows[1].Question
+		if *second.Prompt != "Compute 10%, then round" {
+			t.Errorf("Prompt = %q, want the quoted field", *second.Prompt)
+		}
+		if second.ID != nil || second.AnswerChoices != nil || second.CorrectAnswerMultiple != nil {
+			t.Errorf("empty fields were set: %+v", second)
+		}
+	})
+
+	t.Run("row problems", func(t *testing.T) {
+		data := "id,Prompt\nnot-an-id,p\nx,p,extra\n"
+
+		rows, err := parseCSV(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("parseCSV() error = %v", err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("got %d rows, want 2", len(rows))
+		}
+		if len(rows[0].Errors) != 1 || rows[0].Errors[0].Field != "id" {
+			t.Errorf("first row errors = %v, want an invalid id", rows[0].Errors)
+		}
+		if len(rows[1].Errors) == 0 || rows[1].Errors[len(rows[1].Errors)-1].Message != "row has more fields than the header" {
+			t.Errorf("second row errors = %v, want too many fields", rows[1].Errors)
+		}
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		if _, err := parseCSV(strings.NewReader("Prompt,Hint\np,h\n")); err == nil {
+			t.Error("parseCSV() accepted an unknown column")
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		if _, err := parseCSV(strings.NewReader("")); err == nil {
+			t.Error("parseCSV() accepted a file without a header")
+		}
+	})
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	questions := []Question{
+		{
+			ID:                    &id,
+			Prompt:                stringPtr("Which is larger?"),
+			Text:                  stringPtr("A <passage> & more"),
+			AnswerType:            stringPtr(AnswerTypeMultipleChoice),
+			AnswerChoices:         &[]string{"1/2", "2/3", "3/4"},
+			CorrectAnswerMultiple: stringPtr("C"),
+			Subject:               stringPtr("Math"),
+			Topic:                 stringPtr("Percentages"),
+			Difficulty:            stringPtr("hard"),
+			AccessOption:          stringPtr(AccessPaid),
+			Explanation:           stringPtr("3/4 is 0.75"),
+		},
+		{
+			Prompt:            stringPtr("Solve x + 1 = 3"),
+			AnswerType:        stringPtr(AnswerTypeFreeResponse),
+			CorrectAnswerFree: stringPtr("2"),
+			Subject:           stringPtr("Math"),
+			Topic:             stringPtr("Linear equations in 1 variable"),
+			Difficulty:        stringPtr("easy"),
+		},
+	}
+
+	for _, format := range []string{FormatJSONLines, FormatCSV, FormatQTI} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportQuestions(&buf, format, questions); err != nil {
+				t.Fatalf("ExportQuestions() error = %v", err)
+			}
+
+			rows, err := ParseImport(format, buf.Bytes())
+			if err != nil {
+				t.Fatalf("ParseImport() error = %v", err)
+			}
+			if len(rows) != len(questions) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(questions))
+			}
+
+			for i, row := range rows {
+				if len(row.Errors) > 0 {
+					t.Fatalf("row %d errors = %v", row.Row, row.Errors)
+				}
+				if errs := ValidateQuestion(row.Question); len(errs) > 0 {
+					t.Errorf("row %d does not validate: %v", row.Row, errs)
+				}
+				if !reflect.DeepEqual(*row.Question, questions[i]) {
+					t.Errorf("row %d = %+v, want %+v", row.Row, *row.Question, questions[i])
+				}
+			}
+		})
+	}
+}
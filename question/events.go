@@ -0,0 +1,40 @@
+package question
+
+import (
+	"context"
+	"example/goserver/engagement"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuestionListener is called after a question has been created, edited, moved through the review workflow or deleted
+type QuestionListener func(ctx context.Context, change engagement.QuestionChange) error
+
+// OnQuestionChange registers a listener for question changes. Listeners must be registered before the server starts.
+func (s *QuestionService) OnQuestionChange(listener QuestionListener) {
+	s.questionListeners = append(s.questionListeners, listener)
+}
+
+// publishQuestionChange notifies the listeners if the question moved to another subject, topic or difficulty,
+// or was published, unpublished, created or deleted. before is nil for a new question and after for a deleted one.
+func (s *QuestionService) publishQuestionChange(ctx context.Context, id primitive.ObjectID, before, after *Question) {
+	change := engagement.QuestionChange{QuestionID: id}
+	if before != nil {
+		key := before.EngagementKey()
+		change.Old = &key
+	}
+	if after != nil {
+		key := after.EngagementKey()
+		change.New = &key
+	}
+	if change.Old != nil && change.New != nil && *change.Old == *change.New {
+		return
+	}
+
+	for _, listener := range s.questionListeners {
+		if err := listener(ctx, change); err != nil {
+			log.Printf("Error handling change to question %s: %v", id.Hex(), err)
+		}
+	}
+}
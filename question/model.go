@@ -22,6 +22,14 @@ type Question struct {
 	Images                *[]Image            `bson:"images,omitempty" json:"Images,omitempty"`
 	CreationDate          time.Time           `bson:"creation_date,omitempty" json:"CreationDate,omitempty"`
 	LastEditedDate        time.Time           `bson:"last_edited_date,omitempty" json:"LastEditedDate,omitempty"`
+	RevisionID            *primitive.ObjectID `bson:"revision_id,omitempty" json:"RevisionID,omitempty"`
+	Revision              int                 `bson:"revision,omitempty" json:"Revision,omitempty"`
+	// State is the review workflow state. Questions from before the workflow have no state and count as published.
+	State     *string              `bson:"state,omitempty" json:"State,omitempty"`
+	Reviewers []primitive.ObjectID `bson:"reviewers,omitempty" json:"Reviewers,omitempty"`
+	Comments  []ReviewComment      `bson:"comments,omitempty" json:"Comments,omitempty"`
+	// Locked is set when paid content has been removed because the user does not have access
+	Locked bool `bson:"-" json:"Locked,omitempty"`
 }
 
 type Image struct {
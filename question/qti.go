@@ -0,0 +1,348 @@
+package question
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IMS QTI 2.1 support. A package is a zip with an imsmanifest.xml listing one assessmentItem file per question.
+// QTI has no fields for our subject, topic, difficulty and access option, so they are stored as "key:value"
+// keywords in the LOM metadata of each manifest resource.
+
+const qtiNamespace = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+
+const qtiItemType = "imsqti_item_xmlv2p1"
+
+// qtiResponseID is the response identifier used for the single interaction in each item
+const qtiResponseID = "RESPONSE"
+
+type qtiManifest struct {
+	Resources []qtiResource `xml:"resources>resource"`
+}
+
+type qtiResource struct {
+	Identifier string   `xml:"identifier,attr"`
+	Type       string   `xml:"type,attr"`
+	Href       string   `xml:"href,attr"`
+	Keywords   []string `xml:"metadata>lom>general>keyword>string"`
+}
+
+// qtiMarkup keeps the raw content of an element, which may contain XHTML
+type qtiMarkup struct {
+	Inner      string `xml:",innerxml"`
+	Identifier string `xml:"identifier,attr"`
+	Class      string `xml:"class,attr"`
+}
+
+type qtiItem struct {
+	Identifier string `xml:"identifier,attr"`
+	Title      string `xml:"title,attr"`
+	Responses  []struct {
+		Identifier string   `xml:"identifier,attr"`
+		BaseType   string   `xml:"baseType,attr"`
+		Values     []string `xml:"correctResponse>value"`
+	} `xml:"responseDeclaration"`
+	Body struct {
+		Paragraphs []qtiMarkup `xml:"p"`
+		Divs       []qtiMarkup `xml:"div"`
+		Choice     *struct {
+			Prompt  qtiMarkup   `xml:"prompt"`
+			Choices []qtiMarkup `xml:"simpleChoice"`
+		} `xml:"choiceInteraction"`
+	} `xml:"itemBody"`
+	Feedback []qtiMarkup `xml:"modalFeedback"`
+}
+
+// textContent returns the text in a fragment of markup, without the tags
+func textContent(markup string) string {
+	decoder := xml.NewDecoder(strings.NewReader("<x>" + markup + "</x>"))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if data, ok := token.(xml.CharData); ok {
+			text.Write(data)
+		}
+	}
+	return strings.TrimSpace(strings.Join(strings.Fields(text.String()), " "))
+}
+
+// parseQTI reads a QTI package, or a single assessmentItem file
+func parseQTI(data []byte) ([]ImportRow, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		// Not a zip, so try a single item without metadata
+		q, err := parseQTIItem(data, nil)
+		if err != nil {
+			return []ImportRow{{Row: 1, Errors: []FieldError{{Message: err.Error()}}}}, nil
+		}
+		return []ImportRow{{Row: 1, Question: q}}, nil
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range reader.File {
+		files[path.Clean(file.Name)] = file
+	}
+
+	manifestFile, ok := files["imsmanifest.xml"]
+	if !ok {
+		return nil, errors.New("package has no imsmanifest.xml")
+	}
+	manifestData, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest qtiManifest
+	if err := xml.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid imsmanifest.xml: %w", err)
+	}
+
+	var rows []ImportRow
+	row := 0
+	for _, resource := range manifest.Resources {
+		if !strings.HasPrefix(resource.Type, "imsqti_item") {
+			continue
+		}
+		row++
+
+		file, ok := files[path.Clean(resource.Href)]
+		if !ok {
+			rows = append(rows, ImportRow{Row: row, Errors: []FieldError{{Message: "missing file " + resource.Href}}})
+			continue
+		}
+		itemData, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := parseQTIItem(itemData, resource.Keywords)
+		if err != nil {
+			rows = append(rows, ImportRow{Row: row, Errors: []FieldError{{Message: resource.Href + ": " + err.Error()}}})
+			continue
+		}
+		rows = append(rows, ImportRow{Row: row, Question: q})
+	}
+
+	return rows, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, 10*1024*1024))
+}
+
+// parseQTIItem converts an assessmentItem with a choiceInteraction or textEntryInteraction into a question
+func parseQTIItem(data []byte, keywords []string) (*Question, error) {
+	var item qtiItem
+	if err := xml.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("invalid assessmentItem: %w", err)
+	}
+
+	q := &Question{}
+	if id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(item.Identifier, "Q")); err == nil {
+		q.ID = &id
+	}
+
+	var correct []string
+	for _, response := range item.Responses {
+		if response.Identifier == qtiResponseID || len(item.Responses) == 1 {
+			correct = response.Values
+		}
+	}
+
+	for _, div := range item.Body.Divs {
+		if div.Class == "passage" {
+			text := textContent(div.Inner)
+			q.Text = &text
+		}
+	}
+
+	if item.Body.Choice != nil {
+		answerType := AnswerTypeMultipleChoice
+		q.AnswerType = &answerType
+
+		prompt := textContent(item.Body.Choice.Prompt.Inner)
+		if prompt != "" {
+			q.Prompt = &prompt
+		}
+
+		choices := make([]string, len(item.Body.Choice.Choices))
+		for i, choice := range item.Body.Choice.Choices {
+			choices[i] = textContent(choice.Inner)
+			// The correct response refers to a choice identifier, which we store as a letter
+			if len(correct) > 0 && choice.Identifier == strings.TrimSpace(correct[0]) {
+				letter := string(rune('A' + i))
+				q.CorrectAnswerMultiple = &letter
+			}
+		}
+		q.AnswerChoices = &choices
+	} else {
+		answerType := AnswerTypeFreeResponse
+		q.AnswerType = &answerType
+
+		var promptParts []string
+		for _, p := range item.Body.Paragraphs {
+			if text := textContent(p.Inner); text != "" {
+				promptParts = append(promptParts, text)
+			}
+		}
+		if len(promptParts) > 0 {
+			prompt := strings.Join(promptParts, "\n")
+			q.Prompt = &prompt
+		}
+		if len(correct) > 0 {
+			answer := strings.Join(correct, ",")
+			q.CorrectAnswerFree = &answer
+		}
+	}
+
+	for _, feedback := range item.Feedback {
+		if explanation := textContent(feedback.Inner); explanation != "" {
+			q.Explanation = &explanation
+			break
+		}
+	}
+
+	for _, keyword := range keywords {
+		key, value, found := strings.Cut(keyword, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "subject":
+			q.Subject = &value
+		case "topic":
+			q.Topic = &value
+		case "difficulty":
+			q.Difficulty = &value
+		case "access":
+			q.AccessOption = &value
+		}
+	}
+
+	return q, nil
+}
+
+// escapeXML escapes text for use inside an element
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// qtiItemXML writes a question as a QTI 2.1 assessmentItem
+func qtiItemXML(q Question, identifier string) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<assessmentItem xmlns="%s" identifier="%s" title="%s" adaptive="false" timeDependent="false">`+"\n",
+		qtiNamespace, identifier, escapeXML(deref(q.Topic)))
+
+	isChoice := q.AnswerType != nil && normalizeAnswerType(*q.AnswerType) == AnswerTypeMultipleChoice
+	if isChoice {
+		correct := ""
+		if q.CorrectAnswerMultiple != nil && q.AnswerChoices != nil {
+			if index := correctChoiceIndex(*q.CorrectAnswerMultiple, *q.AnswerChoices); index >= 0 {
+				correct = string(rune('A' + index))
+			}
+		}
+		fmt.Fprintf(&b, `  <responseDeclaration identifier="%s" cardinality="single" baseType="identifier"><correctResponse><value>%s</value></correctResponse></responseDeclaration>`+"\n", qtiResponseID, correct)
+	} else {
+		fmt.Fprintf(&b, `  <responseDeclaration identifier="%s" cardinality="single" baseType="string"><correctResponse><value>%s</value></correctResponse></responseDeclaration>`+"\n", qtiResponseID, escapeXML(deref(q.CorrectAnswerFree)))
+	}
+
+	b.WriteString("  <itemBody>\n")
+	if q.Text != nil && *q.Text != "" {
+		fmt.Fprintf(&b, "    <div class=\"passage\">%s</div>\n", escapeXML(*q.Text))
+	}
+	if isChoice {
+		fmt.Fprintf(&b, `    <choiceInteraction responseIdentifier="%s" shuffle="false" maxChoices="1">`+"\n", qtiResponseID)
+		fmt.Fprintf(&b, "      <prompt>%s</prompt>\n", escapeXML(deref(q.Prompt)))
+		if q.AnswerChoices != nil {
+			for i, choice := range *q.AnswerChoices {
+				fmt.Fprintf(&b, "      <simpleChoice identifier=\"%c\">%s</simpleChoice>\n", 'A'+i, escapeXML(choice))
+			}
+		}
+		b.WriteString("    </choiceInteraction>\n")
+	} else {
+		fmt.Fprintf(&b, "    <p>%s</p>\n", escapeXML(deref(q.Prompt)))
+		fmt.Fprintf(&b, "    <div class=\"response\"><textEntryInteraction responseIdentifier=\"%s\"/></div>\n", qtiResponseID)
+	}
+	b.WriteString("  </itemBody>\n")
+
+	if q.Explanation != nil && *q.Explanation != "" {
+		fmt.Fprintf(&b, "  <modalFeedback outcomeIdentifier=\"FEEDBACK\" identifier=\"EXPLANATION\" showHide=\"show\">%s</modalFeedback>\n", escapeXML(*q.Explanation))
+	}
+	b.WriteString("</assessmentItem>\n")
+
+	return b.String()
+}
+
+// exportQTI writes a QTI package with one item per question
+func exportQTI(w io.Writer, questions []Question) error {
+	archive := zip.NewWriter(w)
+
+	var manifest strings.Builder
+	manifest.WriteString(xml.Header)
+	manifest.WriteString(`<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="MANIFEST">` + "\n  <resources>\n")
+
+	for i, q := range questions {
+		identifier := fmt.Sprintf("ITEM%d", i+1)
+		if q.ID != nil {
+			identifier = "Q" + q.ID.Hex()
+		}
+		href := "items/" + identifier + ".xml"
+
+		file, err := archive.Create(href)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, qtiItemXML(q, identifier)); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&manifest, "    <resource identifier=\"R%s\" type=\"%s\" href=\"%s\">\n      <metadata><lom xmlns=\"http://ltsc.ieee.org/xsd/LOM\"><general>", identifier, qtiItemType, href)
+		for _, keyword := range [][2]string{{"subject", deref(q.Subject)}, {"topic", deref(q.Topic)}, {"difficulty", deref(q.Difficulty)}, {"access", deref(q.AccessOption)}} {
+			if keyword[1] != "" {
+				fmt.Fprintf(&manifest, "<keyword><string>%s:%s</string></keyword>", keyword[0], escapeXML(keyword[1]))
+			}
+		}
+		fmt.Fprintf(&manifest, "</general></lom></metadata>\n      <file href=\"%s\"/>\n    </resource>\n", href)
+	}
+	manifest.WriteString("  </resources>\n</manifest>\n")
+
+	file, err := archive.Create("imsmanifest.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, manifest.String()); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
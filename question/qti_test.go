@@ -0,0 +1,95 @@
+package question
+
+import "testing"
+
+func TestTextContent(t *testing.T) {
+	tests := []struct {
+		markup string
+		want   string
+	}{
+		{"plain", "plain"},
+		{"<b>bold</b> and <i>italic</i>", "bold and italic"},
+		{"  spread\n  over   lines ", "spread over lines"},
+		{"x &lt; y &amp;&amp; z", "x < y && z"},
+	}
+
+	for _, tt := range tests {
+		if got := textContent(tt.markup); got != tt.want {
+			t.Errorf("textContent(%q) = %q, want %q", tt.markup, got, tt.want)
+		}
+	}
+}
+
+func TestParseQTIItem(t *testing.T) {
+	t.Run("choice interaction", func(t *testing.T) {
+		item := `<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="item-7">
+  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">
+    <correctResponse><value>ChoiceC</value></correctResponse>
+  </responseDeclaration>
+  <itemBody>
+    <div class="passage">A <em>short</em> passage</div>
+    <choiceInteraction responseIdentifier="RESPONSE" maxChoices="1">
+      <prompt>Pick <b>one</b></prompt>
+      <simpleChoice identifier="ChoiceA">red</simpleChoice>
+      <simpleChoice identifier="ChoiceB">green</simpleChoice>
+      <simpleChoice identifier="ChoiceC">blue</simpleChoice>
+    </choiceInteraction>
+  </itemBody>
+  <modalFeedback outcomeIdentifier="FEEDBACK" identifier="F">Blue is <b>right</b></modalFeedback>
+</assessmentItem>`
+
+		q, err := parseQTIItem([]byte(item), []string{"topic: Words in context", "difficulty:medium", "unrelated"})
+		if err != nil {
+			t.Fatalf("parseQTIItem() error = %v", err)
+		}
+		if q.ID != nil {
+			t.Errorf("ID = %v, want none for a foreign identifier", q.ID)
+		}
+		if *q.AnswerType != AnswerTypeMultipleChoice || *q.Prompt != "Pick one" || *q.Text != "A short passage" {
+			t.Errorf("question = %+v, want a multiple choice with prompt and passage", q)
+		}
+		if len(*q.AnswerChoices) != 3 || q.CorrectAnswerMultiple == nil || *q.CorrectAnswerMultiple != "C" {
+			t.Errorf("choices %v with correct %v, want three choices with C correct", *q.AnswerChoices, q.CorrectAnswerMultiple)
+		}
+		if *q.Explanation != "Blue is right" {
+			t.Errorf("Explanation = %q, want the feedback text", *q.Explanation)
+		}
+		if *q.Topic != "Words in context" || *q.Difficulty != "medium" || q.Subject != nil {
+			t.Errorf("metadata = topic %v difficulty %v subject %v, want it from the keywords", q.Topic, q.Difficulty, q.Subject)
+		}
+	})
+
+	t.Run("text entry interaction", func(t *testing.T) {
+		item := `<assessmentItem identifier="free">
+  <responseDeclaration identifier="ANSWER" cardinality="single" baseType="string">
+    <correctResponse><value>12</value></correctResponse>
+  </responseDeclaration>
+  <itemBody>
+    <p>What is 3 times 4?</p>
+    <p>Give a whole number.</p>
+    <div><textEntryInteraction responseIdentifier="ANSWER"/></div>
+  </itemBody>
+</assessmentItem>`
+
+		q, err := parseQTIItem([]byte(item), nil)
+		if err != nil {
+			t.Fatalf("parseQTIItem() error = %v", err)
+		}
+		if *q.AnswerType != AnswerTypeFreeResponse || *q.CorrectAnswerFree != "12" {
+			t.Errorf("question = %+v, want a free response with answer 12", q)
+		}
+		if *q.Prompt != "What is 3 times 4?\nGive a whole number." {
+			t.Errorf("Prompt = %q, want both paragraphs", *q.Prompt)
+		}
+	})
+
+	t.Run("not XML", func(t *testing.T) {
+		rows, err := parseQTI([]byte("not xml"))
+		if err != nil {
+			t.Fatalf("parseQTI() error = %v", err)
+		}
+		if len(rows) != 1 || len(rows[0].Errors) != 1 {
+			t.Errorf("rows = %+v, want one row with an error", rows)
+		}
+	})
+}
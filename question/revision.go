@@ -0,0 +1,219 @@
+package question
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRevisionNotFound is returned when a question has no revision with the requested number
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// maxRevisionAttempts is how many times saving a revision is tried when concurrent edits take the next number
+const maxRevisionAttempts = 5
+
+// QuestionRevision is an immutable snapshot of a question, saved every time the question is created or changed
+type QuestionRevision struct {
+	ID             *primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	QuestionID     primitive.ObjectID  `bson:"question_id" json:"QuestionID"`
+	Number         int                 `bson:"number" json:"Number"`
+	Question       Question            `bson:"question" json:"Question"`
+	Author         *primitive.ObjectID `bson:"author,omitempty" json:"Author,omitempty"`
+	Note           string              `bson:"note,omitempty" json:"Note,omitempty"`
+	LastEditedDate time.Time           `bson:"last_edited_date" json:"LastEditedDate"`
+}
+
+// FieldChange is one field that differs between two revisions
+type FieldChange struct {
+	Field string      `json:"Field"`
+	From  interface{} `json:"From"`
+	To    interface{} `json:"To"`
+}
+
+// SaveRevision snapshots the current state of a question as its next revision
+// and marks the question with the new revision, so engagements can record which version they were graded against.
+// Revision numbers are unique per question; if a concurrent edit takes the next number, the snapshot is taken again.
+func (s *QuestionService) SaveRevision(ctx context.Context, questionID primitive.ObjectID, author *primitive.ObjectID, note string) (*QuestionRevision, error) {
+	for attempt := 1; ; attempt++ {
+		revision, err := s.insertRevision(ctx, questionID, author, note)
+		if mongo.IsDuplicateKeyError(err) && attempt < maxRevisionAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// A later revision saved concurrently stays the current one
+		filter := bson.M{"_id": questionID, "$or": bson.A{
+			bson.M{"revision": bson.M{"$exists": false}},
+			bson.M{"revision": bson.M{"$lt": revision.Number}},
+		}}
+		_, err = s.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revision_id": revision.ID, "revision": revision.Number}})
+		if err != nil {
+			return nil, err
+		}
+
+		return revision, nil
+	}
+}
+
+// insertRevision snapshots the question as the revision after the latest one
+func (s *QuestionService) insertRevision(ctx context.Context, questionID primitive.ObjectID, author *primitive.ObjectID, note string) (*QuestionRevision, error) {
+	question, err := s.GetQuestion(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	number := 1
+	latest, err := s.GetLatestRevision(ctx, questionID)
+	if err != nil && err != ErrRevisionNotFound {
+		return nil, err
+	}
+	if latest != nil {
+		number = latest.Number + 1
+	}
+
+	snapshot := *question
+	snapshot.RevisionID = nil
+	snapshot.Revision = 0
+
+	revision := &QuestionRevision{
+		QuestionID:     questionID,
+		Number:         number,
+		Question:       snapshot,
+		Author:         author,
+		Note:           note,
+		LastEditedDate: question.LastEditedDate,
+	}
+	if revision.LastEditedDate.IsZero() {
+		revision.LastEditedDate = time.Now()
+	}
+
+	result, err := s.revisionCollection.InsertOne(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+	revisionID := result.InsertedID.(primitive.ObjectID)
+	revision.ID = &revisionID
+
+	return revision, nil
+}
+
+// GetRevisions returns the revisions of a question, newest first
+func (s *QuestionService) GetRevisions(ctx context.Context, questionID primitive.ObjectID) ([]QuestionRevision, error) {
+	cursor, err := s.revisionCollection.Find(ctx, bson.M{"question_id": questionID}, options.Find().SetSort(bson.M{"number": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []QuestionRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a question by its number
+func (s *QuestionService) GetRevision(ctx context.Context, questionID primitive.ObjectID, number int) (*QuestionRevision, error) {
+	var revision QuestionRevision
+	err := s.revisionCollection.FindOne(ctx, bson.M{"question_id": questionID, "number": number}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetLatestRevision returns the most recent revision of a question
+func (s *QuestionService) GetLatestRevision(ctx context.Context, questionID primitive.ObjectID) (*QuestionRevision, error) {
+	var revision QuestionRevision
+	opts := options.FindOne().SetSort(bson.M{"number": -1})
+	err := s.revisionCollection.FindOne(ctx, bson.M{"question_id": questionID}, opts).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RollbackQuestion restores the content of an earlier revision. The rollback is saved as a new revision,
+// so the history is never rewritten.
+func (s *QuestionService) RollbackQuestion(ctx context.Context, questionID primitive.ObjectID, number int, author *primitive.ObjectID) (*QuestionRevision, error) {
+	target, err := s.GetRevision(ctx, questionID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.GetQuestion(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the content is rolled back; the question stays where it is in the review workflow
+	restored := target.Question
+	restored.ID = &questionID
+	restored.LastEditedDate = time.Now()
+	restored.State = current.State
+	restored.Reviewers = current.Reviewers
+	restored.Comments = current.Comments
+
+	if _, err := s.collection.ReplaceOne(ctx, bson.M{"_id": questionID}, restored); err != nil {
+		return nil, err
+	}
+	s.publishQuestionChange(ctx, questionID, current, &restored)
+
+	return s.SaveRevision(ctx, questionID, author, "Rolled back to revision "+strconv.Itoa(number))
+}
+
+// DiffRevisions lists the question fields that changed between two revisions
+func DiffRevisions(from, to *QuestionRevision) ([]FieldChange, error) {
+	fromFields, err := revisionFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := revisionFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(questionFields))
+	for field := range questionFields {
+		if field != "CreationDate" && field != "LastEditedDate" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			changes = append(changes, FieldChange{Field: field, From: fromFields[field], To: toFields[field]})
+		}
+	}
+	return changes, nil
+}
+
+// revisionFields returns the question in a revision keyed by its JSON field names
+func revisionFields(revision *QuestionRevision) (map[string]interface{}, error) {
+	data, err := json.Marshal(revision.Question)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
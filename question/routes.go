@@ -1,10 +1,12 @@
 package question
 
 import (
+	"example/goserver/engagement"
 	"example/goserver/user"
-	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	// replace with your project path
@@ -13,12 +15,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var questionService *QuestionService
 
 // RegisterRoutes registers the question routes
-func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup, service *QuestionService, userService *user.UserService) {
+func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup, service *QuestionService, userService *user.UserService, engagementService *engagement.EngagementService) {
 	questionService = service
 
 	// Public route, accessible to both authenticated and unauthenticated users
@@ -28,35 +31,70 @@ func RegisterRoutes(publicRouter *gin.RouterGroup, authRouter *gin.RouterGroup,
 	publicRouter.GET("/question/:id", getQuestion(userService))
 	publicRouter.GET("/questions", getQuestions(userService, questionService))
 	publicRouter.GET("/questions/data", getQuestionStatistics(questionService))
-	publicRouter.GET("/questionsbyid", getQuestionsByIDHandler(questionService))
-	publicRouter.PUT("/questions", updateAllQuestions(questionService)) // Add this line
-
-	// Assuming these are admin-only routes, you can keep them under authenticated routes
-	// and add further authorization checks as needed
-	publicRouter.POST("/questions", createQuestion)
-	publicRouter.PUT("/questions/:id", updateQuestion)
-	publicRouter.DELETE("/questions/:id", deleteQuestion)
+	publicRouter.GET("/questionsbyid", getQuestionsByIDHandler(userService, questionService))
+
+	// Admin-only routes
+	requireContentAdmin := user.RequireRole(userService, user.RoleContentAdmin)
+	publicRouter.PUT("/questions", user.RequireRole(userService), updateAllQuestions(questionService)) // super admins only
+	publicRouter.POST("/questions", requireContentAdmin, createQuestion)
+	publicRouter.PUT("/questions/:id", requireContentAdmin, updateQuestion(engagementService))
+	publicRouter.DELETE("/questions/:id", requireContentAdmin, deleteQuestion)
+	publicRouter.POST("/questions/import", requireContentAdmin, importQuestions(questionService))
+	publicRouter.GET("/questions/export", requireContentAdmin, exportQuestions(questionService))
+	publicRouter.GET("/questions/:id/revisions", requireContentAdmin, getRevisions(questionService))
+	publicRouter.GET("/questions/:id/revisions/diff", requireContentAdmin, diffRevisions(questionService))
+	publicRouter.POST("/questions/:id/revisions/:number/rollback", requireContentAdmin, rollbackQuestion(questionService, engagementService))
+
+	// Review workflow
+	publicRouter.GET("/questions/review", requireContentAdmin, getReviewQueue(questionService))
+	publicRouter.POST("/questions/:id/submit", requireContentAdmin, submitQuestion(questionService))
+	publicRouter.POST("/questions/:id/review", requireContentAdmin, reviewQuestion(questionService, userService))
+	publicRouter.POST("/questions/:id/state", requireContentAdmin, setQuestionState(questionService))
+	publicRouter.POST("/questions/:id/comments", requireContentAdmin, addQuestionComment(questionService))
 }
 
 // createQuestion handles the POST /questions route
 func createQuestion(c *gin.Context) {
-	var question Question
-	if err := c.ShouldBindJSON(&question); err != nil {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	question, fieldErrors := DecodeQuestion(body)
+	if fieldErrors == nil {
+		fieldErrors = ValidateQuestion(question)
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question", "fields": fieldErrors})
+		return
+	}
+
 	// Set the creation and last edited dates to the current time
 	currentTime := time.Now()
 	question.CreationDate = currentTime
 	question.LastEditedDate = currentTime
 
-	result, err := questionService.CreateQuestion(c, &question)
+	question.RevisionID = nil
+	question.Revision = 0
+
+	// New questions go through review before students can see them
+	draft := StateDraft
+	question.State = &draft
+	question.Reviewers = nil
+	question.Comments = nil
+
+	result, err := questionService.CreateQuestion(c, question)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if _, err := questionService.SaveRevision(c, *question.ID, authorID(c), "Created"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusCreated, result)
 }
 
@@ -70,9 +108,7 @@ func getQuestion(userService *user.UserService) gin.HandlerFunc {
 		}
 
 		// Attempt to get user ID from JWT token
-		userTier := userService.GetUserTier(c)
-
-		fmt.Println("userTier", userTier)
+		policy := PolicyFor(c, userService)
 
 		question, err := questionService.GetQuestion(c, id)
 		if err != nil {
@@ -80,8 +116,12 @@ func getQuestion(userService *user.UserService) gin.HandlerFunc {
 			return
 		}
 
-		// Dereference question.AccessOption before comparing
-		if question.AccessOption != nil && *question.AccessOption == "paid" && userTier != "paid" {
+		if !question.IsPublished() && !policy.Drafts {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+			return
+		}
+
+		if !policy.CanView(question) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
@@ -90,7 +130,7 @@ func getQuestion(userService *user.UserService) gin.HandlerFunc {
 	}
 }
 
-func getQuestionsByIDHandler(questionService *QuestionService) gin.HandlerFunc {
+func getQuestionsByIDHandler(userService *user.UserService, questionService *QuestionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		questionIDs := c.QueryArray("ids")
 
@@ -112,11 +152,25 @@ func getQuestionsByIDHandler(questionService *QuestionService) gin.HandlerFunc {
 			return
 		}
 
+		// Questions that are not published are left out, as if they did not exist, unless the user may see drafts
+		policy := PolicyFor(c, userService)
+		if !policy.Drafts {
+			published := questions[:0]
+			for _, question := range questions {
+				if question.IsPublished() {
+					published = append(published, question)
+				}
+			}
+			questions = published
+		}
+
+		policy.ApplyAll(questions)
+
 		c.JSON(http.StatusOK, questions)
 	}
 }
 
-func getQuestionsByIDOld(questionService *QuestionService) gin.HandlerFunc {
+func getQuestionsByIDOld(userService *user.UserService, questionService *QuestionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		var userIDObj *primitive.ObjectID
@@ -155,6 +209,11 @@ func getQuestionsByIDOld(questionService *QuestionService) gin.HandlerFunc {
 			return
 		}
 
+		policy := PolicyFor(c, userService)
+		for _, q := range questions {
+			q.Question = policy.Apply(q.Question)
+		}
+
 		// return object with: array of questions: number of total questions, number of answered questions (i.e., status != null), and number of correct questions (i.e., status == "correct")
 		numTotal := len(questions)
 		numAnswered := 0
@@ -276,7 +335,7 @@ func getQuestions(userService *user.UserService, questionService *QuestionServic
 		skip := (page - 1) * pageSize
 
 		// Attempt to get user ID from JWT token
-		userTier := userService.GetUserTier(c)
+		policy := PolicyFor(c, userService)
 
 		// Get the user's attempted question IDs
 		// Attempt to get user ID from context
@@ -292,7 +351,7 @@ func getQuestions(userService *user.UserService, questionService *QuestionServic
 			userIDObj = &userIDObjTemp
 		}
 
-		questions, totalQuestions, err := questionService.GetQuestions(c, difficulty, topic, answerStatus, answerType, skip, pageSize, userTier, userIDObj, subject, sortOption, sortDirection)
+		questions, totalQuestions, err := questionService.GetQuestions(c, difficulty, topic, answerStatus, answerType, skip, pageSize, policy, userIDObj, subject, sortOption, sortDirection, c.Query("state"))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -330,89 +389,114 @@ func updateAllQuestions(questionService *QuestionService) gin.HandlerFunc {
 	}
 }
 
-// updateQuestion handles the PUT /questions/:id route
-func updateQuestion(c *gin.Context) {
-	// Parse the ID
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
-
-	// Parse the request body
-	var questionUpdate map[string]interface{}
-	if err := c.ShouldBindJSON(&questionUpdate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// updateQuestion handles the PUT /questions/:id route. A new revision is saved,
+// and "regrade=true" regrades past engagements against the updated question.
+func updateQuestion(engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Parse the ID
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
 
-	// Normalize the field names to match the existing schema
-	normalizedUpdate := normalizeFieldNames(questionUpdate)
+		// Parse the request body
+		var questionUpdate map[string]interface{}
+		if err := c.ShouldBindJSON(&questionUpdate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	// Set the LastEditedDate to the current date and time
-	normalizedUpdate["last_edited_date"] = time.Now().UTC()
+		// Normalize the field names to match the existing schema
+		normalizedUpdate, fieldErrors := normalizeFieldNames(questionUpdate)
+		if len(fieldErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question", "fields": fieldErrors})
+			return
+		}
 
-	// If CreationDate is not provided, fetch the existing question to get its CreationDate
-	if _, ok := normalizedUpdate["creation_date"]; !ok {
 		existingQuestion, err := questionService.GetQuestionByID(c.Request.Context(), id)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate the question as it will be after the update
+		updatedQuestion, fieldErrors := mergeQuestionUpdate(existingQuestion, questionUpdate)
+		if fieldErrors == nil {
+			fieldErrors = ValidateQuestion(updatedQuestion)
+		}
+		if len(fieldErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question", "fields": fieldErrors})
+			return
+		}
+
+		// Store the normalized answer type and the subject filled in from the topic
+		if _, ok := normalizedUpdate["answer_type"]; ok {
+			normalizedUpdate["answer_type"] = updatedQuestion.AnswerType
+		}
+		if existingQuestion.Subject == nil || *existingQuestion.Subject != *updatedQuestion.Subject {
+			normalizedUpdate["subject"] = updatedQuestion.Subject
+		}
+
+		// Set the LastEditedDate to the current date and time
+		normalizedUpdate["last_edited_date"] = time.Now().UTC()
+
+		// If CreationDate is not provided, keep the existing question's CreationDate
+		if _, ok := normalizedUpdate["creation_date"]; !ok {
+			normalizedUpdate["creation_date"] = existingQuestion.CreationDate
+		}
+
+		// Update the question
+		result, err := questionService.UpdateQuestion(c.Request.Context(), id, normalizedUpdate)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		normalizedUpdate["creation_date"] = existingQuestion.CreationDate
-	}
 
-	// Update the question
-	result, err := questionService.UpdateQuestion(c.Request.Context(), id, normalizedUpdate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		revision, err := questionService.SaveRevision(c, id, authorID(c), c.Query("note"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	// Return the updated question
-	c.JSON(http.StatusOK, result)
+		// Past engagements keep the grade from their revision unless the admin asks for a regrade
+		var regrade *engagement.RegradeResult
+		if c.Query("regrade") == "true" {
+			regrade, err = engagementService.RegradeQuestion(c, id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Return the update result and the new revision
+		c.JSON(http.StatusOK, gin.H{
+			"MatchedCount":  result.MatchedCount,
+			"ModifiedCount": result.ModifiedCount,
+			"Revision":      revision,
+			"Regrade":       regrade,
+		})
+	}
 }
 
-// normalizeFieldNames converts the incoming JSON field names to match the existing schema
-func normalizeFieldNames(update map[string]interface{}) bson.M {
+// normalizeFieldNames converts the incoming JSON field names to match the existing schema.
+// Fields that are not part of the schema are reported instead of being written to the document.
+func normalizeFieldNames(update map[string]interface{}) (bson.M, []FieldError) {
 	normalized := bson.M{}
+	var fieldErrors []FieldError
 
 	for key, value := range update {
-		switch key {
-		case "Prompt":
-			normalized["prompt"] = value
-		case "AnswerType":
-			normalized["answer_type"] = value
-		case "AnswerChoices":
-			normalized["answer_choices"] = value
-		case "CorrectAnswerMultiple":
-			normalized["correct_answer_multiple"] = value
-		case "CorrectAnswerFree":
-			normalized["correct_answer_free"] = value
-		case "Text":
-			normalized["text"] = value
-		case "Subject":
-			normalized["subject"] = value
-		case "Topic":
-			normalized["topic"] = value
-		case "Difficulty":
-			normalized["difficulty"] = value
-		case "AccessOption":
-			normalized["access_option"] = value
-		case "Explanation":
-			normalized["explanation"] = value
-		case "Images":
-			normalized["images"] = value
-		case "CreationDate":
-			normalized["creation_date"] = value
-		case "LastEditedDate":
-			normalized["last_edited_date"] = value
-		default:
-			normalized[key] = value
+		if name, ok := questionFields[key]; ok {
+			normalized[name] = value
+		} else if !contains(readOnlyFields, key) {
+			fieldErrors = append(fieldErrors, FieldError{Field: key, Message: "unknown field"})
 		}
 	}
 
-	return normalized
+	return normalized, fieldErrors
 }
 
 // deleteQuestion handles the DELETE /questions/:id route
@@ -431,3 +515,384 @@ func deleteQuestion(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+// importQuestions handles the POST /questions/import route. The file is sent as the "file" form field or as the request body.
+// The format comes from the "format" query parameter or the file name, and "dryRun=true" only validates.
+func importQuestions(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.Query("format")
+		dryRun := c.Query("dryRun") == "true"
+
+		var data []byte
+		if fileHeader, err := c.FormFile("file"); err == nil {
+			if format == "" {
+				format = DetectFormat(fileHeader.Filename)
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer file.Close()
+			data, err = io.ReadAll(file)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			data, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if format == "" {
+			format = FormatJSONLines
+		}
+
+		rows, err := ParseImport(format, data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := questionService.ImportQuestions(c, rows, dryRun, authorID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// exportQuestions handles the GET /questions/export route, optionally filtered by subject and topic
+func exportQuestions(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", FormatJSONLines)
+
+		contentTypes := map[string]string{
+			FormatJSONLines: "application/x-ndjson",
+			FormatCSV:       "text/csv",
+			FormatQTI:       "application/zip",
+		}
+		extensions := map[string]string{FormatJSONLines: "jsonl", FormatCSV: "csv", FormatQTI: "zip"}
+		contentType, ok := contentTypes[format]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown format"})
+			return
+		}
+
+		questions, err := questionService.FindQuestions(c, c.Query("subject"), c.Query("topic"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", "attachment; filename=questions."+extensions[format])
+		if err := ExportQuestions(c.Writer, format, questions); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// authorID returns the ID of the logged in user making a change, if any
+func authorID(c *gin.Context) *primitive.ObjectID {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// getRevisions handles the GET /questions/:id/revisions route, newest revision first
+func getRevisions(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		revisions, err := questionService.GetRevisions(c, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, revisions)
+	}
+}
+
+// diffRevisions handles the GET /questions/:id/revisions/diff route.
+// It compares revision "from" with revision "to", which defaults to the latest revision.
+func diffRevisions(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		fromNumber, err := strconv.Atoi(c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision"})
+			return
+		}
+		from, err := questionService.GetRevision(c, id, fromNumber)
+		if err == ErrRevisionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var to *QuestionRevision
+		if c.Query("to") == "" {
+			to, err = questionService.GetLatestRevision(c, id)
+		} else {
+			toNumber, convErr := strconv.Atoi(c.Query("to"))
+			if convErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision"})
+				return
+			}
+			to, err = questionService.GetRevision(c, id, toNumber)
+		}
+		if err == ErrRevisionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes, err := DiffRevisions(from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"From": from.Number, "To": to.Number, "Changes": changes})
+	}
+}
+
+// rollbackQuestion handles the POST /questions/:id/revisions/:number/rollback route.
+// "regrade=true" regrades past engagements against the restored question.
+func rollbackQuestion(questionService *QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+		number, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+			return
+		}
+
+		revision, err := questionService.RollbackQuestion(c, id, number, authorID(c))
+		if err == ErrRevisionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var regrade *engagement.RegradeResult
+		if c.Query("regrade") == "true" {
+			regrade, err = engagementService.RegradeQuestion(c, id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"Revision": revision, "Regrade": regrade})
+	}
+}
+
+// writeWorkflowError responds with the status matching a review workflow error
+func writeWorkflowError(c *gin.Context, err error) {
+	switch err {
+	case mongo.ErrNoDocuments:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+	case ErrInvalidTransition:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case ErrNotReviewer, ErrSelfReview:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case ErrEmptyComment, ErrNoReviewers:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// getReviewQueue handles the GET /questions/review route, listing the questions waiting for the user's review
+func getReviewQueue(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewer := authorID(c)
+		if reviewer == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		questions, err := questionService.GetReviewQueue(c, *reviewer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, questions)
+	}
+}
+
+// submitQuestion handles the POST /questions/:id/submit route, sending a draft for review
+func submitQuestion(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var request struct {
+			Reviewers []string `json:"Reviewers"`
+			Comment   string   `json:"Comment"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reviewers := make([]primitive.ObjectID, 0, len(request.Reviewers))
+		for _, reviewer := range request.Reviewers {
+			reviewerID, err := primitive.ObjectIDFromHex(reviewer)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reviewer ID"})
+				return
+			}
+			reviewers = append(reviewers, reviewerID)
+		}
+
+		author := authorID(c)
+		if author == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		question, err := questionService.SubmitForReview(c, id, author, reviewers, request.Comment)
+		if err != nil {
+			writeWorkflowError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, question)
+	}
+}
+
+// reviewQuestion handles the POST /questions/:id/review route. Approving publishes the question;
+// otherwise it goes back to draft and the comment is required.
+func reviewQuestion(questionService *QuestionService, userService *user.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var request struct {
+			Approve bool   `json:"Approve"`
+			Comment string `json:"Comment"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reviewer := authorID(c)
+		if reviewer == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+		role, err := userService.FetchUserRoleFromDB(c, reviewer.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		question, err := questionService.ReviewQuestion(c, id, *reviewer, role == user.RoleSuperAdmin, request.Approve, request.Comment)
+		if err != nil {
+			writeWorkflowError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, question)
+	}
+}
+
+// setQuestionState handles the POST /questions/:id/state route, used to retire a question or reopen a retired one
+func setQuestionState(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var request struct {
+			State   string `json:"State" binding:"required"`
+			Comment string `json:"Comment"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !contains(ValidStates, request.State) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+			return
+		}
+
+		question, err := questionService.SetQuestionState(c, id, request.State, authorID(c), request.Comment)
+		if err != nil {
+			writeWorkflowError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, question)
+	}
+}
+
+// addQuestionComment handles the POST /questions/:id/comments route
+func addQuestionComment(questionService *QuestionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var request struct {
+			Text string `json:"Text"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		question, err := questionService.AddComment(c, id, authorID(c), strings.TrimSpace(request.Text))
+		if err != nil {
+			writeWorkflowError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, question)
+	}
+}
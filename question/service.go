@@ -22,21 +22,46 @@ import (
 )
 
 type QuestionService struct {
-	collection *mongo.Collection
+	collection         *mongo.Collection
+	revisionCollection *mongo.Collection
+	questionListeners  []QuestionListener
 }
 
 // Modify this function to remove the engagementService parameter
-func NewQuestionService(client *mongo.Client) *QuestionService { // Modify this line
+func NewQuestionService(ctx context.Context, client *mongo.Client) (*QuestionService, error) { // Modify this line
 	collection := client.Database("test").Collection("questions")
+	revisionCollection := client.Database("test").Collection("question_revisions")
+
+	// Revision numbers are unique per question, so concurrent edits cannot both save the same number
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "question_id", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := revisionCollection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		return nil, fmt.Errorf("could not create index: %w", err)
+	}
+
 	return &QuestionService{
-		collection: collection,
+		collection:         collection,
+		revisionCollection: revisionCollection,
 		// Remove the engagementService field
-	}
+	}, nil
 }
 
 // ... (other methods here) ...
 func (s *QuestionService) CreateQuestion(ctx context.Context, question *Question) (*mongo.InsertOneResult, error) {
-	return s.collection.InsertOne(ctx, question)
+	result, err := s.collection.InsertOne(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		question.ID = &id
+	}
+	if question.ID != nil {
+		s.publishQuestionChange(ctx, *question.ID, nil, question)
+	}
+	return result, nil
 }
 
 func (s *QuestionService) GetQuestion(ctx context.Context, id primitive.ObjectID) (*Question, error) {
@@ -93,6 +118,42 @@ func (s *QuestionService) GetQuestionsByID(ctx context.Context, ids []primitive.
 	return questions, nil
 }
 
+// GetCandidateQuestions returns the ID, topic and difficulty of every question matching the filters.
+// Paid questions are only included if includePaid is true.
+func (s *QuestionService) GetCandidateQuestions(ctx context.Context, subject string, topics []string, difficulties []string, excludeIDs []primitive.ObjectID, includePaid bool) ([]Question, error) {
+	filter := bson.M{}
+	if subject != "" {
+		filter["subject"] = subject
+	}
+	if len(topics) > 0 {
+		filter["topic"] = bson.M{"$in": topics}
+	}
+	if len(difficulties) > 0 {
+		filter["difficulty"] = bson.M{"$in": difficulties}
+	}
+	if len(excludeIDs) > 0 {
+		filter["_id"] = bson.M{"$nin": excludeIDs}
+	}
+	if !includePaid {
+		filter["access_option"] = bson.M{"$ne": "paid"}
+	}
+	filter["state"] = PublishedFilter()
+
+	projection := bson.M{"_id": 1, "topic": 1, "difficulty": 1, "subject": 1, "access_option": 1}
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var questions []Question
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+
+	return questions, nil
+}
+
 func (s *QuestionService) GetQuestionsByIDOld(ctx context.Context, questionids []primitive.ObjectID, userID *primitive.ObjectID) ([]*QuestionWithStatus, error) {
 	// Create the initial pipeline with the match stage
 	pipeline := []bson.M{
@@ -623,10 +684,15 @@ func (s *QuestionService) executeCombinedCubeStatsPipeline(ctx context.Context,
 
 // GetQuestions retrieves questions from the database
 // based on the provided difficulty, topic, and limit
-func (s *QuestionService) GetQuestions(ctx context.Context, difficulties string, topics string, answerStatus string, answerType string, skip, pageSize int64, userTier string, userID *primitive.ObjectID, subject string, sortOption string, sortDirection string) ([]bson.M, int64, error) {
+func (s *QuestionService) GetQuestions(ctx context.Context, difficulties string, topics string, answerStatus string, answerType string, skip, pageSize int64, policy AccessPolicy, userID *primitive.ObjectID, subject string, sortOption string, sortDirection string, state string) ([]bson.M, int64, error) {
 
 	filter := s.createFilter(difficulties, topics, answerType, subject)
 
+	// Students only see published questions, while content admins can list drafts
+	if stateMatch := stateFilter(state, policy); stateMatch != nil {
+		filter["state"] = stateMatch
+	}
+
 	// Create the initial pipeline with the match stage
 	pipeline := []bson.M{
 		{"$match": filter},
@@ -675,6 +741,17 @@ func (s *QuestionService) GetQuestions(ctx context.Context, difficulties string,
 		return nil, 0, err
 	}
 
+	for _, result := range results {
+		switch questionDocument := result["Question"].(type) {
+		case bson.M:
+			policy.ApplyDocument(questionDocument)
+		case bson.D:
+			redacted := questionDocument.Map()
+			policy.ApplyDocument(redacted)
+			result["Question"] = redacted
+		}
+	}
+
 	return results, totalQuestions, nil
 }
 
@@ -792,6 +869,10 @@ func (S *QuestionService) addEngagementIdToPipeline(pipeline []bson.M) []bson.M
 
 func (s *QuestionService) createInitialPipeline(userID *primitive.ObjectID) []bson.M {
 	return []bson.M{
+		// Statistics only count published questions
+		{
+			"$match": bson.M{"state": PublishedFilter()},
+		},
 		{
 			"$lookup": bson.M{
 				"from":         "engagements",
@@ -1274,9 +1355,28 @@ func (s *QuestionService) GetMaskedQuestions(ctx context.Context, jsonFields str
 
 // UpdateQuestion updates a question in the database
 func (s *QuestionService) UpdateQuestion(ctx context.Context, id primitive.ObjectID, update bson.M) (*mongo.UpdateResult, error) {
-	return s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	before, err := s.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := s.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.publishQuestionChange(ctx, id, before, after)
+
+	return result, nil
 }
 
+// keyFields are the question fields that decide where its engagements are counted
+var keyFields = []string{"subject", "topic", "difficulty", "state"}
+
 func (s *QuestionService) UpdateAllQuestions(ctx context.Context, update bson.M) (*mongo.UpdateResult, error) {
 	// Check if update is empty
 	if len(update) == 0 {
@@ -1290,14 +1390,54 @@ func (s *QuestionService) UpdateAllQuestions(ctx context.Context, update bson.M)
 		}
 	}
 
+	// Questions that move to another topic, difficulty or state are published as changed one by one
+	var before []Question
+	for _, field := range keyFields {
+		if _, ok := update[field]; ok {
+			var err error
+			before, err = s.FindQuestions(ctx, "", "")
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
 	filter := bson.M{} // This is an empty filter which will match all documents in the collection.
-	return s.collection.UpdateMany(ctx, filter, bson.M{"$set": update})
+	result, err := s.collection.UpdateMany(ctx, filter, bson.M{"$set": update})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range before {
+		after, err := s.GetQuestion(ctx, *before[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		s.publishQuestionChange(ctx, *before[i].ID, &before[i], after)
+	}
+
+	return result, nil
 }
 
 // DeleteQuestion deletes a question from the database
 
 func (s *QuestionService) DeleteQuestion(ctx context.Context, id primitive.ObjectID) (*mongo.DeleteResult, error) {
-	return s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	before, err := s.GetQuestion(ctx, id)
+	if err == mongo.ErrNoDocuments {
+		return &mongo.DeleteResult{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, err
+	}
+	if result.DeletedCount > 0 {
+		s.publishQuestionChange(ctx, id, before, nil)
+	}
+	return result, nil
 }
 
 // jsonToBsonFieldName finds the BSON field name for a given JSON field name.
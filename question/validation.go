@@ -0,0 +1,206 @@
+package question
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example/goserver/parameterdata"
+	"strings"
+)
+
+const (
+	AnswerTypeMultipleChoice = "multiple-choice"
+	AnswerTypeFreeResponse   = "free-response"
+)
+
+// ValidDifficulties are the difficulties a question can have, easiest first
+var ValidDifficulties = []string{"easy", "medium", "hard", "extreme"}
+
+// ValidAccessOptions are the allowed values of AccessOption. An empty access option means free.
+var ValidAccessOptions = []string{"free", AccessPaid}
+
+// FieldError describes a problem with one field of a question
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+// questionFields maps the JSON field names of a question to the names stored in the database
+var questionFields = map[string]string{
+	"Prompt":                "prompt",
+	"AnswerType":            "answer_type",
+	"AnswerChoices":         "answer_choices",
+	"CorrectAnswerMultiple": "correct_answer_multiple",
+	"CorrectAnswerFree":     "correct_answer_free",
+	"Text":                  "text",
+	"Subject":               "subject",
+	"Topic":                 "topic",
+	"Difficulty":            "difficulty",
+	"AccessOption":          "access_option",
+	"Explanation":           "explanation",
+	"Images":                "images",
+	"CreationDate":          "creation_date",
+	"LastEditedDate":        "last_edited_date",
+}
+
+// readOnlyFields are sent back by clients that edit a question they fetched, and are ignored on update
+var readOnlyFields = []string{"id", "Locked", "RevisionID", "Revision", "State", "Reviewers", "Comments"}
+
+// decodeFieldError turns a JSON decoding error into a field error
+func decodeFieldError(err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return FieldError{Field: typeErr.Field, Message: "must be " + typeErr.Type.String()}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return FieldError{Field: strings.Trim(field, `"`), Message: "unknown field"}
+	}
+	return FieldError{Message: err.Error()}
+}
+
+// DecodeQuestion parses a question from JSON, rejecting fields that are not part of the schema
+func DecodeQuestion(data []byte) (*Question, []FieldError) {
+	var q Question
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&q); err != nil {
+		return nil, []FieldError{decodeFieldError(err)}
+	}
+	return &q, nil
+}
+
+// mergeQuestionUpdate applies a partial update in JSON field names to a copy of the existing question
+func mergeQuestionUpdate(existing *Question, update map[string]interface{}) (*Question, []FieldError) {
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return nil, []FieldError{{Message: err.Error()}}
+	}
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, []FieldError{{Message: err.Error()}}
+	}
+	for key, value := range update {
+		if _, ok := questionFields[key]; ok {
+			merged[key] = value
+		}
+	}
+
+	if data, err = json.Marshal(merged); err != nil {
+		return nil, []FieldError{{Message: err.Error()}}
+	}
+	return DecodeQuestion(data)
+}
+
+// normalizeAnswerType accepts common spellings such as "Multiple Choice" or "free_response"
+func normalizeAnswerType(answerType string) string {
+	normalized := strings.ToLower(strings.TrimSpace(answerType))
+	normalized = strings.NewReplacer(" ", "-", "_", "-").Replace(normalized)
+	switch {
+	case strings.Contains(normalized, "multiple"):
+		return AnswerTypeMultipleChoice
+	case strings.Contains(normalized, "free"):
+		return AnswerTypeFreeResponse
+	default:
+		return normalized
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(value *string) bool {
+	return value == nil || strings.TrimSpace(*value) == ""
+}
+
+// correctChoiceIndex resolves the correct answer of a multiple choice question, given as a letter or as the choice text
+func correctChoiceIndex(correct string, choices []string) int {
+	correct = strings.TrimSpace(correct)
+	if len(correct) == 1 {
+		letter := strings.ToUpper(correct)[0]
+		if letter >= 'A' && int(letter-'A') < len(choices) {
+			return int(letter - 'A')
+		}
+	}
+	for i, choice := range choices {
+		if strings.EqualFold(strings.TrimSpace(choice), correct) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateQuestion checks that a question can be shown and graded.
+// The answer type is normalized in place, and a missing subject is filled in from the topic.
+func ValidateQuestion(q *Question) []FieldError {
+	var errs []FieldError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Field: field, Message: message})
+	}
+
+	if isBlank(q.Prompt) && isBlank(q.Text) {
+		add("Prompt", "prompt or text is required")
+	}
+
+	if isBlank(q.AnswerType) {
+		add("AnswerType", "answer type is required")
+	} else {
+		answerType := normalizeAnswerType(*q.AnswerType)
+		q.AnswerType = &answerType
+
+		switch answerType {
+		case AnswerTypeMultipleChoice:
+			if q.AnswerChoices == nil || len(*q.AnswerChoices) < 2 {
+				add("AnswerChoices", "multiple choice questions need at least two choices")
+			} else {
+				for i, choice := range *q.AnswerChoices {
+					if strings.TrimSpace(choice) == "" {
+						add("AnswerChoices", "choice "+string(rune('A'+i))+" is empty")
+					}
+				}
+			}
+			if isBlank(q.CorrectAnswerMultiple) {
+				add("CorrectAnswerMultiple", "correct answer is required")
+			} else if q.AnswerChoices != nil && correctChoiceIndex(*q.CorrectAnswerMultiple, *q.AnswerChoices) < 0 {
+				add("CorrectAnswerMultiple", "correct answer does not match any choice")
+			}
+		case AnswerTypeFreeResponse:
+			if isBlank(q.CorrectAnswerFree) {
+				add("CorrectAnswerFree", "correct answer is required")
+			}
+		default:
+			add("AnswerType", "answer type must be "+AnswerTypeMultipleChoice+" or "+AnswerTypeFreeResponse)
+		}
+	}
+
+	if isBlank(q.Topic) {
+		add("Topic", "topic is required")
+	} else if path, ok := parameterdata.FindTopic(*q.Topic); !ok {
+		add("Topic", "unknown topic "+*q.Topic)
+	} else if isBlank(q.Subject) {
+		q.Subject = &path.Subject
+	} else if !strings.EqualFold(*q.Subject, path.Subject) {
+		add("Subject", "topic "+*q.Topic+" belongs to "+path.Subject)
+	}
+
+	if isBlank(q.Difficulty) {
+		add("Difficulty", "difficulty is required")
+	} else if !contains(ValidDifficulties, *q.Difficulty) {
+		add("Difficulty", "difficulty must be one of "+strings.Join(ValidDifficulties, ", "))
+	}
+
+	if q.AccessOption != nil && *q.AccessOption != "" && !contains(ValidAccessOptions, *q.AccessOption) {
+		add("AccessOption", "access option must be one of "+strings.Join(ValidAccessOptions, ", "))
+	}
+
+	if q.State != nil && *q.State != "" && !contains(ValidStates, *q.State) {
+		add("State", "state must be one of "+strings.Join(ValidStates, ", "))
+	}
+
+	return errs
+}
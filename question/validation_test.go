@@ -0,0 +1,166 @@
+package question
+
+import (
+	"reflect"
+	"testing"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestNormalizeAnswerType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"multiple-choice", AnswerTypeMultipleChoice},
+		{"Multiple Choice", AnswerTypeMultipleChoice},
+		{"free_response", AnswerTypeFreeResponse},
+		{" Free Response ", AnswerTypeFreeResponse},
+		{"essay", "essay"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeAnswerType(tt.in); got != tt.want {
+			t.Errorf("normalizeAnswerType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCorrectChoiceIndex(t *testing.T) {
+	choices := []string{"4", "8", "B", "16"}
+
+	tests := []struct {
+		correct string
+		want    int
+	}{
+		{"A", 0},
+		{"b", 1},
+		{" D ", 3},
+		{"16", 3},
+		{"E", -1},
+		{"32", -1},
+	}
+
+	for _, tt := range tests {
+		if got := correctChoiceIndex(tt.correct, choices); got != tt.want {
+			t.Errorf("correctChoiceIndex(%q) = %d, want %d", tt.correct, got, tt.want)
+		}
+	}
+}
+
+func validQuestion() *Question {
+	return &Question{
+		Prompt:                stringPtr("What is 2 + 2?"),
+		AnswerType:            stringPtr("Multiple Choice"),
+		AnswerChoices:         &[]string{"3", "4"},
+		CorrectAnswerMultiple: stringPtr("B"),
+		Topic:                 stringPtr("Linear functions"),
+		Difficulty:            stringPtr("easy"),
+	}
+}
+
+func TestValidateQuestion(t *testing.T) {
+	tests := []struct {
+		name       string
+		edit       func(q *Question)
+		wantFields []string
+	}{
+		{"valid", func(q *Question) {}, nil},
+		{"text instead of prompt", func(q *Question) { q.Prompt = nil; q.Text = stringPtr("Passage") }, nil},
+		{"no prompt or text", func(q *Question) { q.Prompt = stringPtr(" ") }, []string{"Prompt"}},
+		{"no answer type", func(q *Question) { q.AnswerType = nil }, []string{"AnswerType"}},
+		{"unknown answer type", func(q *Question) { q.AnswerType = stringPtr("essay") }, []string{"AnswerType"}},
+		{"one choice", func(q *Question) { q.AnswerChoices = &[]string{"4"}; q.CorrectAnswerMultiple = stringPtr("A") }, []string{"AnswerChoices"}},
+		{"empty choice", func(q *Question) { q.AnswerChoices = &[]string{"4", " "}; q.CorrectAnswerMultiple = stringPtr("A") }, []string{"AnswerChoices"}},
+		{"correct answer not a choice", func(q *Question) { q.CorrectAnswerMultiple = stringPtr("C") }, []string{"CorrectAnswerMultiple"}},
+		{"free response without answer", func(q *Question) { q.AnswerType = stringPtr("free-response") }, []string{"CorrectAnswerFree"}},
+		{"unknown topic", func(q *Question) { q.Topic = stringPtr("Calculus") }, []string{"Topic"}},
+		{"subject does not match topic", func(q *Question) { q.Subject = stringPtr("Reading") }, []string{"Subject"}},
+		{"subject ignores case", func(q *Question) { q.Subject = stringPtr("math") }, nil},
+		{"bad difficulty", func(q *Question) { q.Difficulty = stringPtr("trivial") }, []string{"Difficulty"}},
+		{"bad access option", func(q *Question) { q.AccessOption = stringPtr("premium") }, []string{"AccessOption"}},
+		{"bad state", func(q *Question) { q.State = stringPtr("archived") }, []string{"State"}},
+		{"several problems", func(q *Question) { q.Topic = nil; q.Difficulty = nil }, []string{"Topic", "Difficulty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := validQuestion()
+			tt.edit(q)
+
+			var fields []string
+			for _, err := range ValidateQuestion(q) {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("ValidateQuestion() errors on %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+
+	t.Run("normalizes answer type and fills subject", func(t *testing.T) {
+		q := validQuestion()
+		ValidateQuestion(q)
+		if *q.AnswerType != AnswerTypeMultipleChoice {
+			t.Errorf("AnswerType = %q, want %q", *q.AnswerType, AnswerTypeMultipleChoice)
+		}
+		if q.Subject == nil || *q.Subject != "Math" {
+			t.Errorf("Subject = %v, want Math", q.Subject)
+		}
+	})
+}
+
+func TestDecodeQuestion(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantField string
+		wantErr   bool
+	}{
+		{"valid", `{"Prompt":"p","Topic":"Circles"}`, "", false},
+		{"unknown field", `{"Prompt":"p","Hint":"h"}`, "Hint", true},
+		{"wrong type", `{"Prompt":"p","AnswerChoices":"A|B"}`, "AnswerChoices", true},
+		{"not JSON", `Prompt`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, errs := DecodeQuestion([]byte(tt.data))
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("DecodeQuestion() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.wantErr {
+				if errs[0].Field != tt.wantField {
+					t.Errorf("error field = %q, want %q", errs[0].Field, tt.wantField)
+				}
+			} else if q == nil || *q.Prompt != "p" {
+				t.Errorf("DecodeQuestion() = %+v, want prompt p", q)
+			}
+		})
+	}
+}
+
+func TestMergeQuestionUpdate(t *testing.T) {
+	existing := validQuestion()
+	existing.State = stringPtr(StatePublished)
+
+	merged, errs := mergeQuestionUpdate(existing, map[string]interface{}{
+		"Difficulty": "hard",
+		"State":      StateDraft,
+		"Revision":   7,
+	})
+	if len(errs) > 0 {
+		t.Fatalf("mergeQuestionUpdate() errors = %v", errs)
+	}
+	if *merged.Difficulty != "hard" {
+		t.Errorf("Difficulty = %q, want hard", *merged.Difficulty)
+	}
+	if *merged.State != StatePublished || merged.Revision != 0 {
+		t.Errorf("read-only fields changed: state %q, revision %d", *merged.State, merged.Revision)
+	}
+	if *existing.Difficulty != "easy" {
+		t.Errorf("existing question changed to %q", *existing.Difficulty)
+	}
+}
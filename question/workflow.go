@@ -0,0 +1,249 @@
+package question
+
+import (
+	"context"
+	"errors"
+	"example/goserver/engagement"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Review workflow states. New questions start as drafts and are only shown to students once published.
+const (
+	StateDraft     = "draft"
+	StateInReview  = "in-review"
+	StatePublished = "published"
+	StateRetired   = "retired"
+)
+
+// ValidStates are the allowed values of State
+var ValidStates = []string{StateDraft, StateInReview, StatePublished, StateRetired}
+
+// stateTransitions lists the states each state can move to
+var stateTransitions = map[string][]string{
+	StateDraft:     {StateInReview},
+	StateInReview:  {StatePublished, StateDraft},
+	StatePublished: {StateRetired},
+	StateRetired:   {StateDraft, StatePublished},
+}
+
+var (
+	ErrInvalidTransition = errors.New("the question cannot move to that state")
+	ErrNotReviewer       = errors.New("only an assigned reviewer can review this question")
+	ErrEmptyComment      = errors.New("comment cannot be empty")
+	ErrNoReviewers       = errors.New("at least one reviewer must be assigned")
+	ErrSelfReview        = errors.New("authors cannot review their own questions")
+)
+
+// ReviewComment is a note left on a question by an author or reviewer
+type ReviewComment struct {
+	Author *primitive.ObjectID `bson:"author,omitempty" json:"Author,omitempty"`
+	Text   string              `bson:"text" json:"Text"`
+	// Transition records the state change the comment was left with, such as "draft -> in-review"
+	Transition string    `bson:"transition,omitempty" json:"Transition,omitempty"`
+	Date       time.Time `bson:"date" json:"Date"`
+}
+
+// PublishedFilter matches the state field of published questions, including those from before the workflow
+func PublishedFilter() bson.M {
+	return bson.M{"$in": bson.A{nil, "", StatePublished}}
+}
+
+// CurrentState returns the workflow state of the question
+func (q *Question) CurrentState() string {
+	if q.State == nil || *q.State == "" {
+		return StatePublished
+	}
+	return *q.State
+}
+
+// IsPublished reports whether students can see the question
+func (q *Question) IsPublished() bool {
+	return q.CurrentState() == StatePublished
+}
+
+// Submitter returns the user who last sent the question for review, or nil if it is unknown
+func (q *Question) Submitter() *primitive.ObjectID {
+	for i := len(q.Comments) - 1; i >= 0; i-- {
+		if q.Comments[i].Transition == StateDraft+" -> "+StateInReview {
+			return q.Comments[i].Author
+		}
+	}
+	return nil
+}
+
+// EngagementKey returns the key the question's engagement statuses are counted under
+func (q *Question) EngagementKey() engagement.QuestionKey {
+	return engagement.QuestionKey{
+		Subject:    deref(q.Subject),
+		Topic:      deref(q.Topic),
+		Difficulty: deref(q.Difficulty),
+		Published:  q.IsPublished(),
+	}
+}
+
+// Submitter returns whether the user is assigned to review the question.
+// The user who submitted the question is never its reviewer.
+func (q *Question) IsReviewer(userID primitive.ObjectID) bool {
+	if submitter := q.Submitter(); submitter != nil && *submitter == userID {
+		return false
+	}
+	for _, reviewer := range q.Reviewers {
+		if reviewer == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// stateFilter returns the state filter for a question listing, or nil for questions in any state.
+// Only users who can see drafts may ask for other states than published.
+func stateFilter(state string, policy AccessPolicy) interface{} {
+	if state == "" || state == StatePublished || !policy.Drafts {
+		return PublishedFilter()
+	}
+	if state == "all" {
+		return nil
+	}
+	return state
+}
+
+// transition moves a question to a new state, recording the comment. The update only applies
+// if the question is still in the state it was read in, so two reviewers cannot both act on it.
+func (s *QuestionService) transition(ctx context.Context, q *Question, to string, author *primitive.ObjectID, text string, set bson.M) (*Question, error) {
+	from := q.CurrentState()
+	if !contains(stateTransitions[from], to) {
+		return nil, ErrInvalidTransition
+	}
+
+	if set == nil {
+		set = bson.M{}
+	}
+	set["state"] = to
+
+	update := bson.M{"$set": set}
+	comment := ReviewComment{Author: author, Text: text, Transition: from + " -> " + to, Date: time.Now()}
+	update["$push"] = bson.M{"comments": comment}
+
+	filter := bson.M{"_id": q.ID, "state": q.State}
+	if from == StatePublished {
+		filter["state"] = PublishedFilter()
+	}
+
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrInvalidTransition
+	}
+
+	updated, err := s.GetQuestion(ctx, *q.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishQuestionChange(ctx, *q.ID, q, updated)
+	return updated, nil
+}
+
+// SubmitForReview moves a draft into review and assigns its reviewers. At least one reviewer
+// other than the author is required.
+func (s *QuestionService) SubmitForReview(ctx context.Context, id primitive.ObjectID, author *primitive.ObjectID, reviewers []primitive.ObjectID, text string) (*Question, error) {
+	if len(reviewers) == 0 {
+		return nil, ErrNoReviewers
+	}
+	for _, reviewer := range reviewers {
+		if author != nil && reviewer == *author {
+			return nil, ErrSelfReview
+		}
+	}
+
+	q, err := s.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(ctx, q, StateInReview, author, text, bson.M{"reviewers": reviewers})
+}
+
+// ReviewQuestion publishes a question in review, or sends it back to draft with the reviewer's comment.
+// Super admins can review any question but their own; other reviewers must be assigned to it.
+func (s *QuestionService) ReviewQuestion(ctx context.Context, id primitive.ObjectID, reviewer primitive.ObjectID, isSuperAdmin bool, approve bool, text string) (*Question, error) {
+	q, err := s.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if submitter := q.Submitter(); submitter != nil && *submitter == reviewer {
+		return nil, ErrSelfReview
+	}
+	if !isSuperAdmin && !q.IsReviewer(reviewer) {
+		return nil, ErrNotReviewer
+	}
+	if q.CurrentState() != StateInReview {
+		return nil, ErrInvalidTransition
+	}
+
+	if approve {
+		return s.transition(ctx, q, StatePublished, &reviewer, text, nil)
+	}
+	if text == "" {
+		return nil, ErrEmptyComment
+	}
+	return s.transition(ctx, q, StateDraft, &reviewer, text, nil)
+}
+
+// SetQuestionState moves a question to a state outside of review, such as retiring a published question
+// or reopening a retired one as a draft
+func (s *QuestionService) SetQuestionState(ctx context.Context, id primitive.ObjectID, state string, author *primitive.ObjectID, text string) (*Question, error) {
+	q, err := s.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drafts can only be published through review
+	if state == StateInReview || (state == StatePublished && q.CurrentState() != StateRetired) {
+		return nil, ErrInvalidTransition
+	}
+
+	return s.transition(ctx, q, state, author, text, nil)
+}
+
+// AddComment leaves a comment on a question without changing its state
+func (s *QuestionService) AddComment(ctx context.Context, id primitive.ObjectID, author *primitive.ObjectID, text string) (*Question, error) {
+	if text == "" {
+		return nil, ErrEmptyComment
+	}
+
+	comment := ReviewComment{Author: author, Text: text, Date: time.Now()}
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$push": bson.M{"comments": comment}})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return s.GetQuestion(ctx, id)
+}
+
+// GetReviewQueue returns the questions in review that the reviewer is assigned to, oldest first
+func (s *QuestionService) GetReviewQueue(ctx context.Context, reviewer primitive.ObjectID) ([]Question, error) {
+	filter := bson.M{"state": StateInReview, "reviewers": reviewer}
+
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"last_edited_date": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	questions := []Question{}
+	if err := cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
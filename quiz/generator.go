@@ -0,0 +1,229 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"example/goserver/datacube"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxGeneratedQuestions caps the size of a generated practice set
+const MaxGeneratedQuestions = 100
+
+// GeneratorRequest describes the practice set a student wants.
+// DifficultyMix gives the number of questions per difficulty; if it is empty, Count questions of any difficulty are picked.
+type GeneratorRequest struct {
+	Subject        string         `json:"Subject"`
+	Topics         []string       `json:"Topics"`
+	DifficultyMix  map[string]int `json:"DifficultyMix"`
+	Count          int            `json:"Count"`
+	ExcludeCorrect bool           `json:"ExcludeCorrect"`
+	PrioritizeWeak bool           `json:"PrioritizeWeak"`
+	Name           *string        `json:"Name"`
+}
+
+// subjectTopics returns the topic tree for a subject
+func subjectTopics(subject string) []*parameterdata.Topic {
+	switch strings.ToLower(subject) {
+	case "math":
+		return parameterdata.MathTopicsList
+	case "reading":
+		return parameterdata.ReadingTopicsList
+	default:
+		return append(append([]*parameterdata.Topic{}, parameterdata.MathTopicsList...), parameterdata.ReadingTopicsList...)
+	}
+}
+
+// resolveTopics expands parent topics into their subtopics, since questions are tagged with subtopics
+func resolveTopics(subject string, topics []string) []string {
+	tree := subjectTopics(subject)
+
+	if len(topics) == 0 {
+		var all []string
+		for _, topic := range tree {
+			for _, subtopic := range topic.Children {
+				all = append(all, subtopic.Name)
+			}
+		}
+		return all
+	}
+
+	var resolved []string
+	for _, name := range topics {
+		expanded := false
+		for _, topic := range tree {
+			if topic.Name == name {
+				for _, subtopic := range topic.Children {
+					resolved = append(resolved, subtopic.Name)
+				}
+				expanded = true
+				break
+			}
+		}
+		if !expanded {
+			resolved = append(resolved, name)
+		}
+	}
+	return resolved
+}
+
+// topicWeights favours topics where the student's accuracy in the data cube is low
+func topicWeights(dataCube *datacube.DataCube, topics []string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, topic := range topics {
+		weights[topic] = 1
+
+		if dataCube == nil {
+			continue
+		}
+		row, ok := dataCube.Rows[topic]
+		if !ok {
+			continue
+		}
+
+		attempted := row.Cells["attempted"].Values["total"]
+		accuracy := row.Cells["accuracy"].Values["total"]
+		if attempted == nil || *attempted == 0 || accuracy == nil {
+			continue
+		}
+
+		// Keep a floor so strong topics still show up occasionally
+		weights[topic] = 1 - *accuracy + 0.1
+	}
+	return weights
+}
+
+// pickWeighted draws n questions without replacement, with each question's chance proportional to its topic weight
+func pickWeighted(random *rand.Rand, candidates []question.Question, weights map[string]float64, n int) []primitive.ObjectID {
+	pool := append([]question.Question{}, candidates...)
+	var picked []primitive.ObjectID
+
+	for len(picked) < n && len(pool) > 0 {
+		total := 0.0
+		for _, candidate := range pool {
+			total += candidateWeight(candidate, weights)
+		}
+
+		target := random.Float64() * total
+		index := len(pool) - 1
+		for i, candidate := range pool {
+			target -= candidateWeight(candidate, weights)
+			if target <= 0 {
+				index = i
+				break
+			}
+		}
+
+		picked = append(picked, *pool[index].ID)
+		pool = append(pool[:index], pool[index+1:]...)
+	}
+
+	return picked
+}
+
+func candidateWeight(candidate question.Question, weights map[string]float64) float64 {
+	if weights == nil || candidate.Topic == nil {
+		return 1
+	}
+	if weight, ok := weights[*candidate.Topic]; ok {
+		return weight
+	}
+	return 1
+}
+
+// GeneratePracticeQuiz builds a quiz for the user from the request's constraints.
+// Paid questions are only used when the access policy lets the user see them.
+func (qs *QuizService) GeneratePracticeQuiz(ctx context.Context, request GeneratorRequest, userID primitive.ObjectID, policy question.AccessPolicy, questionService *question.QuestionService, engagementService *engagement.EngagementService, dataCubeService *datacube.DataCubeService) (*Quiz, error) {
+	total := request.Count
+	var difficulties []string
+	if len(request.DifficultyMix) > 0 {
+		total = 0
+		for difficulty, count := range request.DifficultyMix {
+			if count < 0 {
+				return nil, fmt.Errorf("invalid count for difficulty %s", difficulty)
+			}
+			difficulties = append(difficulties, difficulty)
+			total += count
+		}
+	}
+	if total <= 0 {
+		return nil, errors.New("practice set must have at least one question")
+	}
+	if total > MaxGeneratedQuestions {
+		return nil, fmt.Errorf("practice set cannot have more than %d questions", MaxGeneratedQuestions)
+	}
+
+	topics := resolveTopics(request.Subject, request.Topics)
+
+	var excludeIDs []primitive.ObjectID
+	if request.ExcludeCorrect {
+		correctIDs, err := engagementService.GetCorrectQuestionIDs(ctx, &userID)
+		if err != nil {
+			return nil, err
+		}
+		excludeIDs = correctIDs
+	}
+
+	candidates, err := questionService.GetCandidateQuestions(ctx, "", topics, difficulties, excludeIDs, policy.FullAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	var weights map[string]float64
+	if request.PrioritizeWeak {
+		dataCube, err := dataCubeService.GetDataCube(&userID)
+		if err != nil {
+			dataCube, err = dataCubeService.ComputeDataCube(&userID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		weights = topicWeights(dataCube, topics)
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var questionIDs []primitive.ObjectID
+
+	if len(request.DifficultyMix) > 0 {
+		byDifficulty := make(map[string][]question.Question)
+		for _, candidate := range candidates {
+			if candidate.Difficulty != nil {
+				byDifficulty[*candidate.Difficulty] = append(byDifficulty[*candidate.Difficulty], candidate)
+			}
+		}
+		for difficulty, count := range request.DifficultyMix {
+			questionIDs = append(questionIDs, pickWeighted(random, byDifficulty[difficulty], weights, count)...)
+		}
+		random.Shuffle(len(questionIDs), func(i, j int) {
+			questionIDs[i], questionIDs[j] = questionIDs[j], questionIDs[i]
+		})
+	} else {
+		questionIDs = pickWeighted(random, candidates, weights, total)
+	}
+
+	if len(questionIDs) == 0 {
+		return nil, errors.New("no questions match the requested constraints")
+	}
+
+	quizType := "practice"
+	quizName := request.Name
+	if quizName == nil {
+		name := "Practice - " + time.Now().Format("2006-01-02 15:04:05")
+		quizName = &name
+	}
+
+	quizID, err := qs.InitializeQuiz(ctx, questionIDs, userID, &quizType, quizName)
+	if err != nil {
+		return nil, err
+	}
+
+	return qs.GetQuiz(ctx, quizID)
+}
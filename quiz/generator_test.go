@@ -0,0 +1,140 @@
+package quiz
+
+import (
+	"example/goserver/datacube"
+	"example/goserver/question"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResolveTopics(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		topics  []string
+		want    []string
+	}{
+		{"parent expands", "math", []string{"Geometry and trigonometry"}, []string{"Area and volume formulas", "Lines, angles, and triangles", "Right triangles and trigonometry", "Circles"}},
+		{"subtopic kept", "math", []string{"Percentages"}, []string{"Percentages"}},
+		{"mixed", "Math", []string{"Percentages", "Advanced math"}, []string{"Percentages", "Equivalent expressions", "Nonlinear equations in 1 variable", "Systems of equations in 2 variables", "Nonlinear functions"}},
+		{"reading parent is its own subtopic", "reading", []string{"Craft and structure"}, []string{"Craft and structure"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveTopics(tt.subject, tt.topics); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveTopics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no topics means the whole subject", func(t *testing.T) {
+		got := resolveTopics("math", nil)
+		if len(got) != 20 || got[0] != "Linear equations in 1 variable" {
+			t.Errorf("resolveTopics() = %v, want the 20 math subtopics", got)
+		}
+	})
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestTopicWeights(t *testing.T) {
+	row := func(attempted, accuracy *float64) datacube.Row {
+		return datacube.Row{Cells: map[string]datacube.Cell{
+			"attempted": {Values: map[string]*float64{"total": attempted}},
+			"accuracy":  {Values: map[string]*float64{"total": accuracy}},
+		}}
+	}
+	cube := &datacube.DataCube{Rows: map[string]datacube.Row{
+		"Percentages": row(floatPtr(10), floatPtr(0.3)),
+		"Circles":     row(floatPtr(4), floatPtr(1)),
+		"Unattempted": row(floatPtr(0), nil),
+	}}
+	topics := []string{"Percentages", "Circles", "Unattempted", "Missing"}
+
+	want := map[string]float64{"Percentages": 0.8, "Circles": 0.1, "Unattempted": 1, "Missing": 1}
+	got := topicWeights(cube, topics)
+	for topic, weight := range want {
+		if math.Abs(got[topic]-weight) > 1e-9 {
+			t.Errorf("weight of %s = %g, want %g", topic, got[topic], weight)
+		}
+	}
+
+	for topic, weight := range topicWeights(nil, topics) {
+		if weight != 1 {
+			t.Errorf("weight of %s without a cube = %g, want 1", topic, weight)
+		}
+	}
+}
+
+func candidates(topic string, n int) []question.Question {
+	var questions []question.Question
+	for i := 0; i < n; i++ {
+		id := primitive.NewObjectID()
+		topic := topic
+		questions = append(questions, question.Question{ID: &id, Topic: &topic})
+	}
+	return questions
+}
+
+func TestPickWeighted(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name string
+		pool []question.Question
+		n    int
+		want int
+	}{
+		{"fewer than the pool", candidates("Circles", 10), 4, 4},
+		{"whole pool", candidates("Circles", 5), 5, 5},
+		{"more than the pool", candidates("Circles", 3), 8, 3},
+		{"empty pool", nil, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := pickWeighted(random, tt.pool, nil, tt.n)
+			if len(picked) != tt.want {
+				t.Fatalf("picked %d questions, want %d", len(picked), tt.want)
+			}
+			seen := make(map[primitive.ObjectID]bool)
+			for _, id := range picked {
+				if seen[id] {
+					t.Errorf("question %s picked twice", id.Hex())
+				}
+				seen[id] = true
+			}
+		})
+	}
+
+	t.Run("pool is not modified", func(t *testing.T) {
+		pool := candidates("Circles", 4)
+		before := append([]question.Question{}, pool...)
+		pickWeighted(random, pool, nil, 2)
+		if !reflect.DeepEqual(pool, before) {
+			t.Errorf("pickWeighted() changed the candidate pool")
+		}
+	})
+
+	t.Run("weak topics are picked more often", func(t *testing.T) {
+		pool := append(candidates("Percentages", 1), candidates("Circles", 1)...)
+		weights := map[string]float64{"Percentages": 0.9, "Circles": 0.1}
+
+		weakFirst := 0
+		for i := 0; i < 1000; i++ {
+			if pickWeighted(random, pool, weights, 1)[0] == *pool[0].ID {
+				weakFirst++
+			}
+		}
+		if weakFirst < 850 || weakFirst > 950 {
+			t.Errorf("weak topic picked %d times in 1000, want about 900", weakFirst)
+		}
+	})
+}
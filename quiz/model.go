@@ -16,6 +16,12 @@ type Quiz struct {
 	UserID                     primitive.ObjectID          `json:"UserID,omitempty" bson:"user_id,omitempty"`
 	AttemptTime                time.Time                   `json:"AttemptTime,omitempty" bson:"attempt_time,omitempty"`
 	QuestionEngagementIDCombos []QuestionEngagementIDCombo `json:"QuestionEngagementIDCombos,omitempty" bson:"question_engagement_id_combos,omitempty"`
+	TimeLimit                  time.Duration               `json:"TimeLimit,omitempty" bson:"time_limit,omitempty"`
+	Elapsed                    time.Duration               `json:"Elapsed,omitempty" bson:"elapsed,omitempty"`
+	StartedTime                *time.Time                  `json:"StartedTime,omitempty" bson:"started_time,omitempty"`
+	Deadline                   *time.Time                  `json:"Deadline,omitempty" bson:"deadline,omitempty"`
+	Submitted                  bool                        `json:"Submitted,omitempty" bson:"submitted,omitempty"`
+	SubmittedTime              *time.Time                  `json:"SubmittedTime,omitempty" bson:"submitted_time,omitempty"`
 }
 
 type QuestionEngagementIDCombo struct {
@@ -8,22 +8,25 @@ import (
 	"net/url"
 	"time"
 
+	"example/goserver/datacube"
 	"example/goserver/engagement"
 	"example/goserver/question"
+	"example/goserver/user"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func RegisterRoutes(publicRouter *gin.RouterGroup, service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) {
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, userService *user.UserService, dataCubeService *datacube.DataCubeService) {
 	// Add this line to create a new route for getQuiz
 	publicRouter.POST("/quiz", initializeQuiz(service))
+	publicRouter.POST("/quiz/generate", generatePracticeQuiz(service, questionService, engagementService, userService, dataCubeService))
 	// publicRouter.PATCH("/quizzes/:quizID/engagements/:engagementID", updateQuiz(service))
 	publicRouter.PATCH("/quiz/:quizID", updateQuizHandler(service))
 	publicRouter.GET("/quiz", getQuiz(service))
-	publicRouter.GET("/quiz/:id/underlying", getQuizUnderlying(service, questionService, engagementService))
+	publicRouter.GET("/quiz/:id/underlying", getQuizUnderlying(service, questionService, engagementService, userService))
 	publicRouter.GET("/quizzes", getQuizzesForUser(service))
-	publicRouter.GET("quizzes/underlying", getQuizzesUnderlyingForUser(service, questionService, engagementService))
+	publicRouter.GET("quizzes/underlying", getQuizzesUnderlyingForUser(service, questionService, engagementService, userService))
 }
 
 func initializeQuiz(service *QuizService) gin.HandlerFunc {
@@ -60,6 +63,38 @@ func initializeQuiz(service *QuizService) gin.HandlerFunc {
 	}
 }
 
+func generatePracticeQuiz(service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, userService *user.UserService, dataCubeService *datacube.DataCubeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request GeneratorRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		policy := question.PolicyFor(c, userService)
+
+		quiz, err := service.GeneratePracticeQuiz(c, request, userIDObj, policy, questionService, engagementService, dataCubeService)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, quiz)
+	}
+}
+
 func (s *QuizService) InitializeQuizHelper(c context.Context, questionIDList []string, quizType *string, quizName *string) (primitive.ObjectID, error) {
 	userID, exists := c.Value("userID").(string)
 	if !exists {
@@ -116,6 +151,10 @@ func updateQuizHandler(service *QuizService) gin.HandlerFunc {
 
 		// call UpdateQuizWithCombos to update the quiz
 		quizID, err = service.UpdateQuizWithCombos(c.Request.Context(), quizID, req.QEIDArray)
+		if err == engagement.ErrQuizClosed {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -232,7 +271,7 @@ func getQuiz(service *QuizService) gin.HandlerFunc {
 	}
 }
 
-func getQuizUnderlying(service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+func getQuizUnderlying(service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, userService *user.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		quizID, err := primitive.ObjectIDFromHex(c.Param("id"))
 
@@ -254,11 +293,13 @@ func getQuizUnderlying(service *QuizService, questionService *question.QuestionS
 			return
 		}
 
+		result.ApplyAccessPolicy(question.PolicyFor(c, userService))
+
 		c.JSON(http.StatusOK, result)
 	}
 }
 
-func getQuizzesUnderlyingForUser(service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+func getQuizzesUnderlyingForUser(service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, userService *user.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		results, err := GetQuizzesUnderlyingForUser(c, service, questionService, engagementService)
 		if err != nil {
@@ -266,6 +307,11 @@ func getQuizzesUnderlyingForUser(service *QuizService, questionService *question
 			return
 		}
 
+		policy := question.PolicyFor(c, userService)
+		for _, result := range results {
+			result.ApplyAccessPolicy(policy)
+		}
+
 		c.JSON(http.StatusOK, results)
 	}
 }
@@ -285,6 +331,11 @@ func GetQuizzesUnderlyingForUser(ctx context.Context, service *QuizService, ques
 		return nil, fmt.Errorf("invalid user ID: %v", err)
 	}
 
+	return GetQuizzesUnderlyingForUserID(ctx, service, questionService, engagementService, userIDObj)
+}
+
+// GetQuizzesUnderlyingForUserID returns the results of every quiz taken by the given user
+func GetQuizzesUnderlyingForUserID(ctx context.Context, service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, userIDObj primitive.ObjectID) ([]*QuizResult, error) {
 	var quizType *string = nil
 
 	quizzes, err := service.GetQuizzesForUser(ctx, userIDObj, quizType)
@@ -315,10 +366,26 @@ func GetQuizzesUnderlyingForUser(ctx context.Context, service *QuizService, ques
 	return results, nil
 }
 
+// ApplyAccessPolicy redacts the questions in the result that the user is not allowed to see
+func (r *QuizResult) ApplyAccessPolicy(policy question.AccessPolicy) {
+	if r == nil {
+		return
+	}
+	for i := range r.Questions {
+		r.Questions[i].Question = policy.Apply(r.Questions[i].Question)
+	}
+}
+
 func (s *QuizService) GetQuizUnderlying(ctx context.Context, service *QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, quiz Quiz) (*QuizResult, error) {
 
 	questionEngagementCombos := make([]QuestionEngagementCombo, len(quiz.QuestionEngagementIDCombos))
 
+	// The quiz's own answers hold its result; the shared engagement may have been answered again since
+	quizAnswers, err := engagementService.GetQuizAnswers(ctx, quiz.UserID, quiz.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting quiz answers: %w", err)
+	}
+
 	// for each question engagement combo in the quiz, get the question and engagement
 	for i, qeid := range quiz.QuestionEngagementIDCombos {
 		question, err := questionService.GetQuestion(ctx, *qeid.QuestionID)
@@ -331,6 +398,9 @@ func (s *QuizService) GetQuizUnderlying(ctx context.Context, service *QuizServic
 			if err != nil {
 				return nil, fmt.Errorf("error getting engagement: %w", err)
 			}
+			if answer, ok := quizAnswers[*qeid.QuestionID]; ok {
+				engagement.ApplyQuizAnswer(answer)
+			}
 
 			questionEngagementCombos[i] = QuestionEngagementCombo{
 				Question:   question,
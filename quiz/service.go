@@ -2,6 +2,7 @@ package quiz
 
 import (
 	"context"
+	"example/goserver/engagement"
 	"fmt"
 	"time"
 
@@ -92,6 +93,12 @@ func (qs *QuizService) InitializeQuiz(ctx context.Context, questionIDs []primiti
 	return quiz.ID, nil
 }
 
+// DeleteQuiz removes a quiz, such as one whose setup could not be finished
+func (qs *QuizService) DeleteQuiz(ctx context.Context, quizID primitive.ObjectID) error {
+	_, err := qs.collection.DeleteOne(ctx, bson.M{"_id": quizID})
+	return err
+}
+
 func (qs *QuizService) GetQuiz(ctx context.Context, quizID primitive.ObjectID) (*Quiz, error) {
 	// Create a filter to find the quiz
 	filter := bson.M{"_id": quizID}
@@ -215,6 +222,10 @@ func (qs *QuizService) UpdateQuizWithCombos(ctx context.Context, quizID primitiv
 		return primitive.NilObjectID, fmt.Errorf("error finding quiz: %w", err)
 	}
 
+	if !quiz.AcceptsAnswers(time.Now()) {
+		return primitive.NilObjectID, engagement.ErrQuizClosed
+	}
+
 	// Create a map to track existing question IDs
 	existingQuestions := make(map[primitive.ObjectID]int)
 	for i, combo := range quiz.QuestionEngagementIDCombos {
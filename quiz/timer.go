@@ -0,0 +1,212 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TimerNotStarted = "not_started"
+	TimerRunning    = "running"
+	TimerPaused     = "paused"
+	TimerExpired    = "expired"
+	TimerSubmitted  = "submitted"
+)
+
+// TimerStatus works out the state of a quiz's timer at the given time
+func (quiz *Quiz) TimerStatus(now time.Time) string {
+	switch {
+	case quiz.Submitted:
+		return TimerSubmitted
+	case quiz.Deadline != nil && now.After(*quiz.Deadline):
+		return TimerExpired
+	case quiz.StartedTime != nil:
+		return TimerRunning
+	case quiz.TimeLimit > 0 && quiz.Elapsed > 0:
+		return TimerPaused
+	default:
+		return TimerNotStarted
+	}
+}
+
+// RemainingTime returns how much time is left on a quiz's timer
+func (quiz *Quiz) RemainingTime(now time.Time) time.Duration {
+	if quiz.TimeLimit == 0 {
+		return 0
+	}
+
+	remaining := quiz.TimeLimit - quiz.Elapsed
+	if quiz.StartedTime != nil {
+		remaining -= now.Sub(*quiz.StartedTime)
+	}
+
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// AcceptsAnswers reports whether answers can still be logged for the quiz.
+// A timed quiz only accepts answers while its clock is running.
+func (quiz *Quiz) AcceptsAnswers(now time.Time) bool {
+	status := quiz.TimerStatus(now)
+	return status == TimerRunning || (status == TimerNotStarted && quiz.TimeLimit == 0)
+}
+
+// ArmQuizTimer sets the time limit of a quiz that has not been started, so it accepts no answers until its clock is started
+func (qs *QuizService) ArmQuizTimer(ctx context.Context, quizID primitive.ObjectID, limit time.Duration) error {
+	filter := bson.M{"_id": quizID, "started_time": bson.M{"$exists": false}, "submitted": bson.M{"$ne": true}}
+	_, err := qs.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"time_limit": limit}})
+	if err != nil {
+		return fmt.Errorf("error setting quiz time limit: %w", err)
+	}
+
+	return nil
+}
+
+// StartQuizTimer starts the timer for a quiz with the given time limit
+func (qs *QuizService) StartQuizTimer(ctx context.Context, quizID primitive.ObjectID, limit time.Duration) (*Quiz, error) {
+	quiz, err := qs.GetQuiz(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	if quiz.TimerStatus(time.Now()) != TimerNotStarted {
+		return nil, errors.New("quiz timer has already been started")
+	}
+
+	now := time.Now()
+	deadline := now.Add(limit)
+	quiz.TimeLimit = limit
+	quiz.StartedTime = &now
+	quiz.Deadline = &deadline
+
+	return quiz, qs.saveTimer(ctx, quiz)
+}
+
+// PauseQuizTimer stops the clock on a running quiz
+func (qs *QuizService) PauseQuizTimer(ctx context.Context, quizID primitive.ObjectID) (*Quiz, error) {
+	quiz, err := qs.checkExpired(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if quiz.TimerStatus(now) != TimerRunning {
+		return nil, errors.New("quiz timer is not running")
+	}
+
+	quiz.Elapsed += now.Sub(*quiz.StartedTime)
+	quiz.StartedTime = nil
+	quiz.Deadline = nil
+
+	return quiz, qs.saveTimer(ctx, quiz)
+}
+
+// ResumeQuizTimer restarts the clock on a paused quiz
+func (qs *QuizService) ResumeQuizTimer(ctx context.Context, quizID primitive.ObjectID) (*Quiz, error) {
+	quiz, err := qs.GetQuiz(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if quiz.TimerStatus(now) != TimerPaused {
+		return nil, errors.New("quiz timer is not paused")
+	}
+
+	deadline := now.Add(quiz.TimeLimit - quiz.Elapsed)
+	quiz.StartedTime = &now
+	quiz.Deadline = &deadline
+
+	return quiz, qs.saveTimer(ctx, quiz)
+}
+
+// GetQuizTimer returns the quiz, submitting it first if its deadline has passed
+func (qs *QuizService) GetQuizTimer(ctx context.Context, quizID primitive.ObjectID) (*Quiz, error) {
+	return qs.checkExpired(ctx, quizID)
+}
+
+// checkExpired fetches a quiz and submits it if its time has run out
+func (qs *QuizService) checkExpired(ctx context.Context, quizID primitive.ObjectID) (*Quiz, error) {
+	quiz, err := qs.GetQuiz(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	if quiz.TimerStatus(time.Now()) == TimerExpired {
+		if err := qs.SubmitQuiz(ctx, quizID, *quiz.Deadline); err != nil {
+			return nil, err
+		}
+		return qs.GetQuiz(ctx, quizID)
+	}
+
+	return quiz, nil
+}
+
+// SubmitQuiz closes a quiz so no more answers can be logged for it
+func (qs *QuizService) SubmitQuiz(ctx context.Context, quizID primitive.ObjectID, submittedTime time.Time) error {
+	_, err := qs.collection.UpdateOne(ctx, bson.M{"_id": quizID, "submitted": bson.M{"$ne": true}}, bson.M{
+		"$set":   bson.M{"submitted": true, "submitted_time": submittedTime},
+		"$unset": bson.M{"started_time": "", "deadline": ""},
+	})
+	if err != nil {
+		return fmt.Errorf("error submitting quiz: %w", err)
+	}
+
+	return nil
+}
+
+// SubmitExpiredQuizzes submits every quiz whose deadline has passed and returns their IDs
+func (qs *QuizService) SubmitExpiredQuizzes(ctx context.Context) ([]primitive.ObjectID, error) {
+	filter := bson.M{"deadline": bson.M{"$lt": time.Now()}, "submitted": bson.M{"$ne": true}}
+	cursor, err := qs.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error finding expired quizzes: %w", err)
+	}
+
+	var quizzes []*Quiz
+	if err = cursor.All(ctx, &quizzes); err != nil {
+		return nil, err
+	}
+
+	var ids []primitive.ObjectID
+	for _, quiz := range quizzes {
+		if err := qs.SubmitQuiz(ctx, quiz.ID, *quiz.Deadline); err != nil {
+			return ids, err
+		}
+		ids = append(ids, quiz.ID)
+	}
+
+	return ids, nil
+}
+
+func (qs *QuizService) saveTimer(ctx context.Context, quiz *Quiz) error {
+	set := bson.M{"time_limit": quiz.TimeLimit, "elapsed": quiz.Elapsed}
+	unset := bson.M{}
+
+	if quiz.StartedTime != nil {
+		set["started_time"] = quiz.StartedTime
+		set["deadline"] = quiz.Deadline
+	} else {
+		unset["started_time"] = ""
+		unset["deadline"] = ""
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	_, err := qs.collection.UpdateOne(ctx, bson.M{"_id": quiz.ID}, update)
+	if err != nil {
+		return fmt.Errorf("error updating quiz timer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package review
+
+import (
+	"context"
+	"example/goserver/engagement"
+	"example/goserver/quiz"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultDailyLimit is the number of questions in a daily review set
+const DefaultDailyLimit = 20
+
+func RegisterRoutes(publicRouter *gin.RouterGroup, engagementService *engagement.EngagementService, quizService *quiz.QuizService) {
+	publicRouter.GET("/review/due", getDueReviews(engagementService))
+	publicRouter.POST("/review/quiz", createReviewQuiz(engagementService, quizService))
+}
+
+// getDailyReviewSet returns the engagements due for review by the end of today
+func getDailyReviewSet(c *gin.Context, engagementService *engagement.EngagementService) ([]*engagement.Engagement, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+		return nil, false
+	}
+
+	userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(DefaultDailyLimit)), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return nil, false
+	}
+
+	now := time.Now()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
+
+	engagements, err := engagementService.GetDueEngagements(c, userIDObj, endOfDay, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return engagements, true
+}
+
+func getDueReviews(engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		engagements, ok := getDailyReviewSet(c, engagementService)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"numDue":      len(engagements),
+			"engagements": engagements,
+		})
+	}
+}
+
+func createReviewQuiz(engagementService *engagement.EngagementService, quizService *quiz.QuizService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		engagements, ok := getDailyReviewSet(c, engagementService)
+		if !ok {
+			return
+		}
+
+		if len(engagements) == 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "No questions due for review"})
+			return
+		}
+
+		quizID, err := initializeReviewQuiz(c, quizService, engagements)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"quizID": quizID})
+	}
+}
+
+// initializeReviewQuiz turns a review set into a quiz for the user
+func initializeReviewQuiz(ctx context.Context, quizService *quiz.QuizService, engagements []*engagement.Engagement) (primitive.ObjectID, error) {
+	questionIDs := make([]primitive.ObjectID, 0, len(engagements))
+	for _, dueEngagement := range engagements {
+		if dueEngagement.QuestionID != nil {
+			questionIDs = append(questionIDs, *dueEngagement.QuestionID)
+		}
+	}
+
+	quizType := "review"
+	quizName := "Review - " + time.Now().Format("2006-01-02 15:04:05")
+	return quizService.InitializeQuiz(ctx, questionIDs, *engagements[0].UserID, &quizType, &quizName)
+}
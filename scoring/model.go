@@ -0,0 +1,73 @@
+package scoring
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	SectionMath    = "Math"
+	SectionReading = "Reading"
+
+	MinSectionScore = 200
+	MaxSectionScore = 800
+)
+
+// ConversionTable maps raw section scores to 200-800 scaled scores for one section of one test.
+// An empty TestName makes the table the default for that section.
+type ConversionTable struct {
+	ID                *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	TestName          string              `json:"TestName" bson:"test_name"`
+	Section           string              `json:"Section" bson:"section"`
+	MaxRaw            int                 `json:"MaxRaw" bson:"max_raw"`
+	Entries           []ConversionEntry   `json:"Entries" bson:"entries"`
+	WeightedRaw       bool                `json:"WeightedRaw,omitempty" bson:"weighted_raw,omitempty"`
+	DifficultyWeights map[string]float64  `json:"DifficultyWeights,omitempty" bson:"difficulty_weights,omitempty"`
+}
+
+// ConversionEntry is one point on the conversion curve. Raw scores between entries are interpolated.
+type ConversionEntry struct {
+	Raw    int `json:"Raw" bson:"raw"`
+	Scaled int `json:"Scaled" bson:"scaled"`
+}
+
+// DefaultDifficultyWeights are used for weighted raw scoring when a table does not set its own.
+var DefaultDifficultyWeights = map[string]float64{
+	"easy":    1,
+	"medium":  1.5,
+	"hard":    2,
+	"extreme": 2.5,
+}
+
+// DefaultTables approximate the published digital SAT curves and are used when no table is configured.
+var DefaultTables = []*ConversionTable{
+	{
+		Section: SectionMath,
+		MaxRaw:  44,
+		Entries: []ConversionEntry{
+			{Raw: 0, Scaled: 200},
+			{Raw: 5, Scaled: 290},
+			{Raw: 10, Scaled: 380},
+			{Raw: 15, Scaled: 440},
+			{Raw: 20, Scaled: 490},
+			{Raw: 25, Scaled: 540},
+			{Raw: 30, Scaled: 590},
+			{Raw: 35, Scaled: 650},
+			{Raw: 40, Scaled: 720},
+			{Raw: 44, Scaled: 800},
+		},
+	},
+	{
+		Section: SectionReading,
+		MaxRaw:  54,
+		Entries: []ConversionEntry{
+			{Raw: 0, Scaled: 200},
+			{Raw: 6, Scaled: 280},
+			{Raw: 12, Scaled: 360},
+			{Raw: 18, Scaled: 420},
+			{Raw: 24, Scaled: 470},
+			{Raw: 30, Scaled: 520},
+			{Raw: 36, Scaled: 570},
+			{Raw: 42, Scaled: 630},
+			{Raw: 48, Scaled: 700},
+			{Raw: 54, Scaled: 800},
+		},
+	},
+}
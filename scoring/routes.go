@@ -0,0 +1,58 @@
+package scoring
+
+import (
+	"example/goserver/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *ScoringService, userService *user.UserService) {
+	publicRouter.GET("/scoring/tables", getTables(service))
+	publicRouter.GET("/scoring/table", getTable(service))
+	publicRouter.PUT("/scoring/table", user.RequireRole(userService, user.RoleContentAdmin), upsertTable(service))
+}
+
+func getTables(service *ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tables, err := service.GetTables(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, tables)
+	}
+}
+
+func getTable(service *ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		testName := c.Query("testName")
+		section := c.DefaultQuery("section", SectionMath)
+
+		table, err := service.GetTable(c, testName, section)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, table)
+	}
+}
+
+func upsertTable(service *ScoringService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table ConversionTable
+		if err := c.ShouldBindJSON(&table); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := service.UpsertTable(c, &table); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion table saved successfully"})
+	}
+}
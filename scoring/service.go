@@ -0,0 +1,221 @@
+package scoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ScoringService struct {
+	collection *mongo.Collection
+	fileTables []*ConversionTable
+}
+
+// NewScoringService creates a scoring service. If SCORE_TABLES_FILE is set, the tables in that
+// JSON file are used as fallbacks for tests that have no table stored in the database.
+func NewScoringService(ctx context.Context, client *mongo.Client) (*ScoringService, error) {
+	collection := client.Database("test").Collection("conversion_tables")
+
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "test_name", Value: 1},
+			{Key: "section", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		return nil, fmt.Errorf("could not create index: %w", err)
+	}
+
+	service := &ScoringService{collection: collection}
+
+	if path := os.Getenv("SCORE_TABLES_FILE"); path != "" {
+		tables, err := LoadTablesFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		service.fileTables = tables
+	}
+
+	return service, nil
+}
+
+// LoadTablesFromFile reads a JSON array of conversion tables
+func LoadTablesFromFile(path string) ([]*ConversionTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading conversion tables: %w", err)
+	}
+
+	var tables []*ConversionTable
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("error parsing conversion tables: %w", err)
+	}
+
+	for _, table := range tables {
+		if err := ValidateTable(table); err != nil {
+			return nil, err
+		}
+	}
+
+	return tables, nil
+}
+
+// ValidateTable checks that a table is usable and sorts its entries by raw score
+func ValidateTable(table *ConversionTable) error {
+	if table.Section != SectionMath && table.Section != SectionReading {
+		return fmt.Errorf("invalid section: %s", table.Section)
+	}
+	if len(table.Entries) < 2 {
+		return errors.New("conversion table needs at least two entries")
+	}
+
+	sort.Slice(table.Entries, func(i, j int) bool {
+		return table.Entries[i].Raw < table.Entries[j].Raw
+	})
+
+	for i, entry := range table.Entries {
+		if entry.Scaled < MinSectionScore || entry.Scaled > MaxSectionScore {
+			return fmt.Errorf("scaled score %d is outside %d-%d", entry.Scaled, MinSectionScore, MaxSectionScore)
+		}
+		if i == 0 {
+			continue
+		}
+		previous := table.Entries[i-1]
+		if entry.Raw == previous.Raw {
+			return fmt.Errorf("raw score %d appears more than once", entry.Raw)
+		}
+		if entry.Scaled < previous.Scaled {
+			return fmt.Errorf("scaled score for raw %d is lower than for raw %d", entry.Raw, previous.Raw)
+		}
+	}
+
+	if table.MaxRaw == 0 {
+		table.MaxRaw = table.Entries[len(table.Entries)-1].Raw
+	}
+
+	return nil
+}
+
+// GetTable returns the table for a test section. It looks in the database first, then the
+// tables loaded from file, then falls back to the default table for the section.
+func (s *ScoringService) GetTable(ctx context.Context, testName string, section string) (*ConversionTable, error) {
+	var table ConversionTable
+	err := s.collection.FindOne(ctx, bson.M{"test_name": testName, "section": section}).Decode(&table)
+	if err == nil {
+		return &table, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("error getting conversion table: %w", err)
+	}
+
+	for _, fileTable := range s.fileTables {
+		if fileTable.TestName == testName && fileTable.Section == section {
+			return fileTable, nil
+		}
+	}
+
+	// A table with an empty test name is the default for the section
+	if testName != "" {
+		return s.GetTable(ctx, "", section)
+	}
+
+	for _, defaultTable := range DefaultTables {
+		if defaultTable.Section == section {
+			return defaultTable, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no conversion table for section %s", section)
+}
+
+// GetTables returns every table stored in the database
+func (s *ScoringService) GetTables(ctx context.Context) ([]*ConversionTable, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tables []*ConversionTable
+	if err = cursor.All(ctx, &tables); err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// UpsertTable creates or replaces the table for a test section
+func (s *ScoringService) UpsertTable(ctx context.Context, table *ConversionTable) error {
+	if err := ValidateTable(table); err != nil {
+		return err
+	}
+
+	table.ID = nil
+	filter := bson.M{"test_name": table.TestName, "section": table.Section}
+	_, err := s.collection.ReplaceOne(ctx, filter, table, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving conversion table: %w", err)
+	}
+
+	return nil
+}
+
+// Convert turns a raw score out of total questions into a scaled section score.
+// The raw score is rescaled onto the table's MaxRaw so shorter practice tests still map onto the full curve.
+func (table *ConversionTable) Convert(raw float64, total int) int {
+	if total <= 0 {
+		return MinSectionScore
+	}
+
+	tableRaw := raw / float64(total) * float64(table.MaxRaw)
+	entries := table.Entries
+
+	var scaled float64
+	switch {
+	case tableRaw <= float64(entries[0].Raw):
+		scaled = float64(entries[0].Scaled)
+	case tableRaw >= float64(entries[len(entries)-1].Raw):
+		scaled = float64(entries[len(entries)-1].Scaled)
+	default:
+		for i := 1; i < len(entries); i++ {
+			if tableRaw <= float64(entries[i].Raw) {
+				low, high := entries[i-1], entries[i]
+				fraction := (tableRaw - float64(low.Raw)) / float64(high.Raw-low.Raw)
+				scaled = float64(low.Scaled) + fraction*float64(high.Scaled-low.Scaled)
+				break
+			}
+		}
+	}
+
+	// Scaled scores are reported in steps of 10
+	rounded := int(math.Round(scaled/10) * 10)
+	if rounded < MinSectionScore {
+		return MinSectionScore
+	}
+	if rounded > MaxSectionScore {
+		return MaxSectionScore
+	}
+	return rounded
+}
+
+// Weight returns the raw score weight for a question of the given difficulty
+func (table *ConversionTable) Weight(difficulty string) float64 {
+	weights := table.DifficultyWeights
+	if len(weights) == 0 {
+		weights = DefaultDifficultyWeights
+	}
+	if weight, ok := weights[difficulty]; ok {
+		return weight
+	}
+	return 1
+}
@@ -0,0 +1,75 @@
+package scoring
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+	table := &ConversionTable{
+		Section: SectionMath,
+		MaxRaw:  40,
+		Entries: []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 20, Scaled: 500}, {Raw: 40, Scaled: 800}},
+	}
+
+	tests := []struct {
+		name  string
+		raw   float64
+		total int
+		want  int
+	}{
+		{"no questions", 5, 0, 200},
+		{"nothing correct", 0, 40, 200},
+		{"on an entry", 20, 40, 500},
+		{"between entries", 10, 40, 350},
+		{"rounds to tens", 11, 40, 370},
+		{"all correct", 40, 40, 800},
+		{"shorter test rescaled", 10, 20, 500},
+		{"weighted raw between counts", 7.5, 20, 430},
+		{"above the table", 50, 40, 800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Convert(tt.raw, tt.total); got != tt.want {
+				t.Errorf("Convert(%g, %d) = %d, want %d", tt.raw, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		section string
+		entries []ConversionEntry
+		wantErr bool
+	}{
+		{"increasing", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 10, Scaled: 800}}, false},
+		{"unsorted input", SectionReading, []ConversionEntry{{Raw: 10, Scaled: 800}, {Raw: 0, Scaled: 200}}, false},
+		{"flat step", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 1, Scaled: 200}, {Raw: 10, Scaled: 800}}, false},
+		{"unknown section", "Science", []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 10, Scaled: 800}}, true},
+		{"one entry", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 200}}, true},
+		{"scaled out of range", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 100}, {Raw: 10, Scaled: 800}}, true},
+		{"scaled decreases", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 5, Scaled: 600}, {Raw: 10, Scaled: 500}}, true},
+		{"repeated raw", SectionMath, []ConversionEntry{{Raw: 0, Scaled: 200}, {Raw: 5, Scaled: 400}, {Raw: 5, Scaled: 500}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &ConversionTable{Section: tt.section, Entries: tt.entries}
+			err := ValidateTable(table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && table.MaxRaw != table.Entries[len(table.Entries)-1].Raw {
+				t.Errorf("MaxRaw = %d, want the highest raw score", table.MaxRaw)
+			}
+		})
+	}
+}
+
+func TestDefaultTablesAreValid(t *testing.T) {
+	for _, table := range DefaultTables {
+		if err := ValidateTable(table); err != nil {
+			t.Errorf("default %s table: %v", table.Section, err)
+		}
+	}
+}
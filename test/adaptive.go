@@ -0,0 +1,256 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrTestCompleted   = errors.New("test is already completed")
+	ErrModuleSubmitted = errors.New("module is already submitted")
+)
+
+// CreateAdaptiveTest creates an adaptive test and initializes the routing module of its first section.
+// The remaining modules are created one at a time as the student submits each module.
+// Each module's timer starts as soon as it is created.
+func (s *TestService) CreateAdaptiveTest(ctx context.Context, representation parameterdata.AdaptiveTestRepresentation, userID primitive.ObjectID, quizService *quiz.QuizService) (primitive.ObjectID, error) {
+	if len(representation.Sections) == 0 {
+		return primitive.NilObjectID, errors.New("adaptive test has no sections")
+	}
+
+	// The test ID is chosen up front so the module quizzes can be named after it
+	testID := primitive.NewObjectID()
+	section := representation.Sections[0]
+	quizID, err := initializeModule(ctx, quizService, userID, testID, representation.Name, section, StageRouting)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	name := representation.Name
+	modules := []ModuleRoute{{Section: section.Name, Stage: StageRouting, QuizID: quizID}}
+	quizIDList := []primitive.ObjectID{quizID}
+
+	test := &Test{
+		ID:          testID,
+		UserID:      &userID,
+		QuizIDList:  &quizIDList,
+		Name:        &name,
+		AttemptTime: time.Now(),
+		Completed:   false,
+		Adaptive:    true,
+		Modules:     &modules,
+	}
+
+	if _, err := s.collection.InsertOne(ctx, test); err != nil {
+		_ = quizService.DeleteQuiz(ctx, quizID)
+		return primitive.NilObjectID, err
+	}
+
+	return testID, nil
+}
+
+// SubmitModule marks the current module of an adaptive test as submitted and initializes the next one.
+// After a routing module the student goes to the easy or hard module of the same section; after a
+// second-stage module they move on to the next section. It returns the updated test.
+func (s *TestService) SubmitModule(ctx context.Context, testID primitive.ObjectID, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) (*Test, error) {
+	test, err := s.GetTestByID(ctx, testID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !test.Adaptive || test.Modules == nil || len(*test.Modules) == 0 {
+		return nil, errors.New("test is not adaptive")
+	}
+	if test.Completed {
+		return nil, ErrTestCompleted
+	}
+
+	representation := parameterdata.FindAdaptiveTest(*test.Name)
+	if representation == nil {
+		return nil, fmt.Errorf("adaptive test representation %s not found", *test.Name)
+	}
+
+	modules := *test.Modules
+	currentIndex := len(modules) - 1
+	current := &modules[currentIndex]
+	if current.Submitted {
+		return nil, ErrModuleSubmitted
+	}
+
+	// Submitting the quiz first stops answers to it; submitting a quiz twice has no effect
+	if err := quizService.SubmitQuiz(ctx, current.QuizID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	sectionIndex := -1
+	for i, section := range representation.Sections {
+		if section.Name == current.Section {
+			sectionIndex = i
+			break
+		}
+	}
+	if sectionIndex < 0 {
+		return nil, fmt.Errorf("section %s not found in test", current.Section)
+	}
+
+	submittedField := fmt.Sprintf("modules.%d.submitted", currentIndex)
+	set := bson.M{submittedField: true}
+
+	var next *ModuleRoute
+	var nextSection *parameterdata.AdaptiveSection
+	if current.Stage == StageRouting {
+		score, err := moduleScore(ctx, current.QuizID, quizService, questionService, engagementService)
+		if err != nil {
+			return nil, err
+		}
+		current.RoutingScore = &score
+		set[fmt.Sprintf("modules.%d.routing_score", currentIndex)] = score
+
+		section := representation.Sections[sectionIndex]
+		stage := StageEasy
+		if score >= section.RoutingThreshold {
+			stage = StageHard
+		}
+		next = &ModuleRoute{Section: section.Name, Stage: stage}
+		nextSection = section
+	} else if sectionIndex+1 < len(representation.Sections) {
+		nextSection = representation.Sections[sectionIndex+1]
+		next = &ModuleRoute{Section: nextSection.Name, Stage: StageRouting}
+	}
+
+	quizIDList := []primitive.ObjectID{}
+	if test.QuizIDList != nil {
+		quizIDList = *test.QuizIDList
+	}
+
+	update := bson.M{"$set": set}
+	if next != nil {
+		// The next module is built before the current one is marked submitted, so a failure here leaves the test
+		// as it was and the submit can be retried
+		quizID, err := initializeModule(ctx, quizService, *test.UserID, testID, representation.Name, nextSection, next.Stage)
+		if err != nil {
+			if submitted, _ := s.moduleSubmitted(ctx, testID, currentIndex); submitted {
+				return nil, ErrModuleSubmitted
+			}
+			return nil, err
+		}
+		next.QuizID = quizID
+		update["$push"] = bson.M{"modules": *next, "quiz_id_list": quizID}
+	} else {
+		set["completed"] = true
+	}
+
+	// Marking the module submitted and adding the next one is a single update, so two submits at once cannot both add a module
+	claim, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": testID, "modules": bson.M{"$size": len(modules)}, submittedField: false},
+		update,
+	)
+	if err == nil && claim.MatchedCount == 0 {
+		err = ErrModuleSubmitted
+	}
+	if err != nil {
+		if next != nil {
+			_ = quizService.DeleteQuiz(ctx, next.QuizID)
+		}
+		if err == ErrModuleSubmitted {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error submitting module: %w", err)
+	}
+
+	current.Submitted = true
+	if next != nil {
+		modules = append(modules, *next)
+		quizIDList = append(quizIDList, next.QuizID)
+	} else {
+		test.Completed = true
+	}
+	test.Modules = &modules
+	test.QuizIDList = &quizIDList
+
+	return test, nil
+}
+
+// moduleSubmitted reports whether a module of a test has been marked submitted
+func (s *TestService) moduleSubmitted(ctx context.Context, testID primitive.ObjectID, index int) (bool, error) {
+	test, err := s.GetTestByID(ctx, testID)
+	if err != nil {
+		return false, err
+	}
+	return test.Modules != nil && index < len(*test.Modules) && (*test.Modules)[index].Submitted, nil
+}
+
+// initializeModule creates the quiz for one module of an adaptive test and starts its timer.
+// Quiz names are unique per user, so the name includes the test ID to let the same test be taken again.
+func initializeModule(ctx context.Context, quizService *quiz.QuizService, userID, testID primitive.ObjectID, testName string, section *parameterdata.AdaptiveSection, stage string) (primitive.ObjectID, error) {
+	var questionList []string
+	quizName := testName + " (" + testID.Hex() + ") - " + section.Name
+	switch stage {
+	case StageRouting:
+		questionList = section.RoutingModule
+		quizName += " Module 1"
+	case StageEasy:
+		questionList = section.EasyModule
+		quizName += " Module 2 (easy)"
+	case StageHard:
+		questionList = section.HardModule
+		quizName += " Module 2 (hard)"
+	default:
+		return primitive.NilObjectID, fmt.Errorf("invalid stage: %s", stage)
+	}
+
+	questionIDs := make([]primitive.ObjectID, len(questionList))
+	for i, id := range questionList {
+		questionID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return primitive.NilObjectID, fmt.Errorf("invalid question ID: %v", err)
+		}
+		questionIDs[i] = questionID
+	}
+
+	limit, err := moduleTimeLimit(section.Name)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	// The user is passed in rather than read from the request, since the deadline sweeper also creates modules
+	quizType := "test"
+	quizID, err := quizService.InitializeQuiz(ctx, questionIDs, userID, &quizType, &quizName)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if _, err := quizService.StartQuizTimer(ctx, quizID, limit); err != nil {
+		_ = quizService.DeleteQuiz(ctx, quizID)
+		return primitive.NilObjectID, err
+	}
+
+	return quizID, nil
+}
+
+// moduleScore returns the fraction of questions answered correctly in a module
+func moduleScore(ctx context.Context, quizID primitive.ObjectID, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) (float64, error) {
+	moduleQuiz, err := quizService.GetQuiz(ctx, quizID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := quizService.GetQuizUnderlying(ctx, quizService, questionService, engagementService, *moduleQuiz)
+	if err != nil {
+		return 0, err
+	}
+
+	if result.NumTotal == 0 {
+		return 0, nil
+	}
+	return float64(result.NumCorrect) / float64(result.NumTotal), nil
+}
@@ -14,6 +14,23 @@ type Test struct {
 	Name        *string               `json:"Name,omitempty" bson:"name,omitempty"`
 	AttemptTime time.Time             `json:"AttemptTime,omitempty" bson:"attempt_time,omitempty"`
 	Completed   bool                  `json:"Completed,omitempty" bson:"completed,omitempty"`
+	Adaptive    bool                  `json:"Adaptive,omitempty" bson:"adaptive,omitempty"`
+	Modules     *[]ModuleRoute        `json:"Modules,omitempty" bson:"modules,omitempty"`
+}
+
+const (
+	StageRouting = "routing"
+	StageEasy    = "easy"
+	StageHard    = "hard"
+)
+
+// ModuleRoute records one module of an adaptive test and how the student was routed to it
+type ModuleRoute struct {
+	Section      string             `json:"Section" bson:"section"`
+	Stage        string             `json:"Stage" bson:"stage"`
+	QuizID       primitive.ObjectID `json:"QuizID" bson:"quiz_id"`
+	Submitted    bool               `json:"Submitted" bson:"submitted"`
+	RoutingScore *float64           `json:"RoutingScore,omitempty" bson:"routing_score,omitempty"`
 }
 
 type TestStats struct {
@@ -1,10 +1,13 @@
 package test
 
 import (
+	"context"
 	"example/goserver/engagement"
 	"example/goserver/parameterdata"
 	"example/goserver/question"
 	"example/goserver/quiz"
+	"example/goserver/scoring"
+	"example/goserver/user"
 	"strconv"
 
 	"fmt"
@@ -12,17 +15,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func RegisterRoutes(publicRouter *gin.RouterGroup, service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) {
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService, userService *user.UserService) {
 	publicRouter.GET("/test", getTestByName(service))
 	publicRouter.GET("/test/:id", getTestByID(service))
 	publicRouter.POST("/test", createTest(service))
-	publicRouter.GET("/createalltests", createAllTests(service, quizService))
+	publicRouter.GET("/createalltests", createAllTests(service, quizService, questionService))
+	publicRouter.POST("/adaptivetest", createAdaptiveTest(service, quizService))
+	publicRouter.POST("/test/:id/submitmodule", submitModule(service, quizService, questionService, engagementService))
+	publicRouter.POST("/test/:id/module/:index/start", moduleTimerHandler(service, quizService, questionService, service.StartModule))
+	publicRouter.POST("/test/:id/module/:index/pause", moduleTimerHandler(service, quizService, questionService, service.PauseModule))
+	publicRouter.POST("/test/:id/module/:index/resume", moduleTimerHandler(service, quizService, questionService, service.ResumeModule))
+	publicRouter.GET("/test/:id/module/:index/time", moduleTimerHandler(service, quizService, questionService, service.GetModuleTimer))
 	publicRouter.PATCH("test/:id", updateTest(service))
 	publicRouter.GET("/tests", getTestsForUser(service))
-	publicRouter.GET("/test/:id/underlying", getTestUnderlying(service, quizService, questionService, engagementService))
-	publicRouter.GET("/tests/underlying", getTestsUnderlyingForUser(service, quizService, questionService, engagementService))
+	publicRouter.GET("/test/:id/underlying", getTestUnderlying(service, quizService, questionService, engagementService, scoringService, userService))
+	publicRouter.GET("/tests/underlying", getTestsUnderlyingForUser(service, quizService, questionService, engagementService, scoringService, userService))
 
 }
 
@@ -150,7 +160,7 @@ func getTestsForUser(service *TestService) gin.HandlerFunc {
 	}
 }
 
-func getTestsUnderlyingForUser(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+func getTestsUnderlyingForUser(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService, userService *user.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -175,13 +185,16 @@ func getTestsUnderlyingForUser(service *TestService, quizService *quiz.QuizServi
 			return
 		}
 
+		policy := question.PolicyFor(c, userService)
+
 		testResults := make([]TestResult, len(tests))
 		for i, test := range tests {
-			testResult, err := service.GetTestUnderlying(c, quizService, questionService, engagementService, test)
+			testResult, err := service.GetTestUnderlying(c, quizService, questionService, engagementService, scoringService, test)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			testResult.ApplyAccessPolicy(policy)
 			testResults[i] = *testResult
 		}
 
@@ -217,7 +230,7 @@ func updateTest(service *TestService) gin.HandlerFunc {
 	}
 }
 
-func getTestUnderlying(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+func getTestUnderlying(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService, userService *user.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		testID, err := primitive.ObjectIDFromHex(c.Param("id"))
 
@@ -233,20 +246,29 @@ func getTestUnderlying(service *TestService, quizService *quiz.QuizService, ques
 			return
 		}
 
-		testResult, err := service.GetTestUnderlying(c, quizService, questionService, engagementService, *test)
+		testResult, err := service.GetTestUnderlying(c, quizService, questionService, engagementService, scoringService, *test)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		testResult.ApplyAccessPolicy(question.PolicyFor(c, userService))
+
 		c.JSON(http.StatusOK, testResult)
 
 		// c.JSON(http.StatusOK, gin.H{"test": test, "quizResults": quizResults, "testStats": testStats, "mathScaled": mathScaled, "readingScaled": readingScaled, "totalScaled": totalScaled})
 	}
 }
 
-func (s *TestService) GetTestUnderlying(c *gin.Context, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, test Test) (*TestResult, error) {
+// ApplyAccessPolicy redacts the questions in every module that the user is not allowed to see
+func (r *TestResult) ApplyAccessPolicy(policy question.AccessPolicy) {
+	for i := range r.QuizResults {
+		r.QuizResults[i].ApplyAccessPolicy(policy)
+	}
+}
+
+func (s *TestService) GetTestUnderlying(c context.Context, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService, scoringService *scoring.ScoringService, test Test) (*TestResult, error) {
 
 	quizIDList := test.QuizIDList
 	//create array of quiz.QuizResult objects
@@ -387,8 +409,22 @@ func (s *TestService) GetTestUnderlying(c *gin.Context, quizService *quiz.QuizSe
 		},
 	}
 
-	mathScaled := 380
-	readingScaled := 380
+	testName := ""
+	if test.Name != nil {
+		testName = *test.Name
+	}
+
+	mathScaled, err := scaleSection(c, scoringService, testName, scoring.SectionMath, mathStat, quizResults)
+	if err != nil {
+		return nil, err
+	}
+
+	readingScaled, err := scaleSection(c, scoringService, testName, scoring.SectionReading, readingStat, quizResults)
+	if err != nil {
+		return nil, err
+	}
+
+	// The total score is the sum of the two section scores, 400-1600
 	totalScaled := mathScaled + readingScaled
 
 	return &TestResult{
@@ -401,7 +437,116 @@ func (s *TestService) GetTestUnderlying(c *gin.Context, quizService *quiz.QuizSe
 	}, nil
 }
 
-func createAllTests(service *TestService, quizService *quiz.QuizService) gin.HandlerFunc {
+func createAdaptiveTest(service *TestService, quizService *quiz.QuizService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{"message": "User not logged in"})
+			return
+		}
+
+		userIDObj, err := primitive.ObjectIDFromHex(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		representation := parameterdata.FindAdaptiveTest(c.Query("name"))
+		if representation == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "adaptive test representation not found"})
+			return
+		}
+
+		testID, err := service.CreateAdaptiveTest(c, *representation, userIDObj, quizService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"testID": testID})
+	}
+}
+
+// getOwnTest loads the test in the id parameter and checks it belongs to the logged in user.
+// It writes the error response and returns nil when it does not.
+func getOwnTest(c *gin.Context, service *TestService) *Test {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+		return nil
+	}
+
+	testID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test ID"})
+		return nil
+	}
+
+	test, err := service.GetTestByID(c, testID)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Test not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	if test.UserID == nil || test.UserID.Hex() != userID.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil
+	}
+
+	return test
+}
+
+func submitModule(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		owned := getOwnTest(c, service)
+		if owned == nil {
+			return
+		}
+
+		test, err := service.SubmitModule(c, owned.ID, quizService, questionService, engagementService)
+		if err == ErrModuleSubmitted || err == ErrTestCompleted {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, test)
+	}
+}
+
+// moduleTimerHandler handles the start, pause, resume and remaining time routes for a test module
+func moduleTimerHandler(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService, action func(context.Context, *Test, int, *quiz.QuizService, *question.QuestionService) (*ModuleTimer, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid module index"})
+			return
+		}
+
+		// Only the student taking the test can start, pause or resume its clock
+		test := getOwnTest(c, service)
+		if test == nil {
+			return
+		}
+
+		timer, err := action(c, test, index, quizService, questionService)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, timer)
+	}
+}
+
+func createAllTests(service *TestService, quizService *quiz.QuizService, questionService *question.QuestionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -418,7 +563,7 @@ func createAllTests(service *TestService, quizService *quiz.QuizService) gin.Han
 		// iterate through Tests from parameterdata and create them
 		// Iterate through Tests from parameterdata and create them
 		for _, test := range parameterdata.Tests {
-			_, err := service.CreateTestFromRepresentation(c, *test, userIDObj, quizService)
+			_, err := service.CreateTestFromRepresentation(c, *test, userIDObj, quizService, questionService)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -429,7 +574,9 @@ func createAllTests(service *TestService, quizService *quiz.QuizService) gin.Han
 	}
 }
 
-func (s *TestService) CreateTestFromRepresentation(c *gin.Context, testRepresentation parameterdata.TestRepresentation, userIDObj primitive.ObjectID, quizService *quiz.QuizService) (primitive.ObjectID, error) {
+// CreateTestFromRepresentation creates a fixed test with a quiz for each module. The modules' time limits are set
+// straight away, but each clock only starts when the student starts that module.
+func (s *TestService) CreateTestFromRepresentation(c *gin.Context, testRepresentation parameterdata.TestRepresentation, userIDObj primitive.ObjectID, quizService *quiz.QuizService, questionService *question.QuestionService) (primitive.ObjectID, error) {
 	quizIDListObjIDs := make([]primitive.ObjectID, len(testRepresentation.QuestionLists))
 	for i, questionList := range testRepresentation.QuestionLists {
 		quizName := testRepresentation.Name + " - Module " + strconv.Itoa(i+1)
@@ -447,5 +594,10 @@ func (s *TestService) CreateTestFromRepresentation(c *gin.Context, testRepresent
 		return primitive.NilObjectID, err
 	}
 
+	test := &Test{ID: testID, QuizIDList: &quizIDListObjIDs}
+	if err := s.armModules(c, test, quizService, questionService); err != nil {
+		return primitive.NilObjectID, err
+	}
+
 	return testID, nil
 }
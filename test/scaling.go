@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"example/goserver/parameterdata"
+	"example/goserver/quiz"
+	"example/goserver/scoring"
+)
+
+// SectionForTopic returns the scoring section that a question topic belongs to.
+// Sections are named after their subject, and only subtopics are scored.
+func SectionForTopic(topic string) string {
+	if path, ok := parameterdata.FindTopic(topic); ok && path.Subtopic {
+		return path.Subject
+	}
+	return ""
+}
+
+// weightedRaw sums the difficulty weights of the correct answers in a section and rescales the
+// result onto the section's question count, so it can be looked up in the same conversion table.
+func weightedRaw(table *scoring.ConversionTable, quizResults []quiz.QuizResult, section string, total int) float64 {
+	totalWeight := 0.0
+	correctWeight := 0.0
+
+	for _, quizResult := range quizResults {
+		for _, questionEngCombo := range quizResult.Questions {
+			if questionEngCombo.Question == nil || questionEngCombo.Question.Topic == nil {
+				continue
+			}
+			if SectionForTopic(*questionEngCombo.Question.Topic) != section {
+				continue
+			}
+
+			difficulty := ""
+			if questionEngCombo.Question.Difficulty != nil {
+				difficulty = *questionEngCombo.Question.Difficulty
+			}
+			weight := table.Weight(difficulty)
+
+			totalWeight += weight
+			if questionEngCombo.Engagement != nil && questionEngCombo.Engagement.Status != nil && *questionEngCombo.Engagement.Status == "correct" {
+				correctWeight += weight
+			}
+		}
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+	return correctWeight / totalWeight * float64(total)
+}
+
+// scaleSection converts the raw result for one section into a 200-800 scaled score
+func scaleSection(ctx context.Context, scoringService *scoring.ScoringService, testName string, section string, stat SmallStats, quizResults []quiz.QuizResult) (int, error) {
+	table, err := scoringService.GetTable(ctx, testName, section)
+	if err != nil {
+		return 0, err
+	}
+
+	raw := float64(stat.Correct)
+	if table.WeightedRaw {
+		raw = weightedRaw(table, quizResults, section, stat.Total)
+	}
+
+	return table.Convert(raw, stat.Total), nil
+}
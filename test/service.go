@@ -85,6 +85,60 @@ func (s *TestService) GetTestsForUser(c context.Context, userID primitive.Object
 	return tests, nil
 }
 
+// completedFilter matches completed tests. UpdateTest has always stored the flag as "Completed",
+// while adaptive tests use "completed".
+func completedFilter() bson.M {
+	return bson.M{"$or": bson.A{bson.M{"completed": true}, bson.M{"Completed": true}}}
+}
+
+// GetCompletedTests returns the user's completed tests, oldest first
+func (s *TestService) GetCompletedTests(ctx context.Context, userID primitive.ObjectID) ([]Test, error) {
+	filter := completedFilter()
+	filter["user_id"] = userID
+
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"attempt_time": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("error getting completed tests: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var tests []Test
+	if err = cursor.All(ctx, &tests); err != nil {
+		return nil, err
+	}
+
+	return tests, nil
+}
+
+// GetLatestCompletedTests returns the most recently attempted completed test of each user.
+// A nil userID returns the tests of every user.
+func (s *TestService) GetLatestCompletedTests(ctx context.Context, userID *primitive.ObjectID) ([]Test, error) {
+	match := completedFilter()
+	if userID != nil {
+		match["user_id"] = userID
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$sort": bson.M{"attempt_time": -1}},
+		{"$group": bson.M{"_id": "$user_id", "test": bson.M{"$first": "$$ROOT"}}},
+		{"$replaceRoot": bson.M{"newRoot": "$test"}},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error getting completed tests: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var tests []Test
+	if err = cursor.All(ctx, &tests); err != nil {
+		return nil, err
+	}
+
+	return tests, nil
+}
+
 func (s *TestService) UpdateTest(c *gin.Context, id primitive.ObjectID, completed bool) error {
 	result, err := s.collection.UpdateOne(
 		c,
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"example/goserver/engagement"
+	"example/goserver/parameterdata"
+	"example/goserver/question"
+	"example/goserver/quiz"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ModuleTimer is the timer state of one test module as returned to the client
+type ModuleTimer struct {
+	QuizID           primitive.ObjectID `json:"QuizID"`
+	Section          string             `json:"Section"`
+	Status           string             `json:"Status"`
+	TimeLimit        float64            `json:"TimeLimit"`        // seconds
+	RemainingSeconds float64            `json:"RemainingSeconds"` // seconds
+	Deadline         *time.Time         `json:"Deadline,omitempty"`
+}
+
+func newModuleTimer(moduleQuiz *quiz.Quiz, section string) *ModuleTimer {
+	now := time.Now()
+	return &ModuleTimer{
+		QuizID:           moduleQuiz.ID,
+		Section:          section,
+		Status:           moduleQuiz.TimerStatus(now),
+		TimeLimit:        moduleQuiz.TimeLimit.Seconds(),
+		RemainingSeconds: moduleQuiz.RemainingTime(now).Seconds(),
+		Deadline:         moduleQuiz.Deadline,
+	}
+}
+
+// moduleSection finds the quiz for a module of the test and the section it belongs to
+func (s *TestService) moduleSection(ctx context.Context, test *Test, index int, quizService *quiz.QuizService, questionService *question.QuestionService) (primitive.ObjectID, string, error) {
+	if test.QuizIDList == nil || index < 0 || index >= len(*test.QuizIDList) {
+		return primitive.NilObjectID, "", errors.New("module not found in test")
+	}
+	quizID := (*test.QuizIDList)[index]
+
+	if test.Modules != nil {
+		for _, module := range *test.Modules {
+			if module.QuizID == quizID {
+				return quizID, module.Section, nil
+			}
+		}
+	}
+
+	// Fixed tests do not record sections, so use the topic of the first question in the module
+	moduleQuiz, err := quizService.GetQuiz(ctx, quizID)
+	if err != nil {
+		return primitive.NilObjectID, "", err
+	}
+	for _, qeid := range moduleQuiz.QuestionEngagementIDCombos {
+		firstQuestion, err := questionService.GetQuestion(ctx, *qeid.QuestionID)
+		if err != nil {
+			return primitive.NilObjectID, "", err
+		}
+		if firstQuestion.Topic != nil {
+			return quizID, SectionForTopic(*firstQuestion.Topic), nil
+		}
+	}
+
+	return quizID, "", nil
+}
+
+// moduleTimeLimit returns the time limit for one module of a section
+func moduleTimeLimit(section string) (time.Duration, error) {
+	minutes, ok := parameterdata.ModuleTimeLimits[section]
+	if !ok {
+		return 0, fmt.Errorf("no time limit for section %q", section)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// armModules sets the time limit on every module of a fixed test without starting their clocks,
+// so no answers are accepted for a module until the student starts it
+func (s *TestService) armModules(ctx context.Context, test *Test, quizService *quiz.QuizService, questionService *question.QuestionService) error {
+	for index := range *test.QuizIDList {
+		quizID, section, err := s.moduleSection(ctx, test, index, quizService, questionService)
+		if err != nil {
+			return err
+		}
+		// Modules without a scored section have no time limit
+		if section == "" {
+			continue
+		}
+
+		limit, err := moduleTimeLimit(section)
+		if err != nil {
+			return err
+		}
+
+		if err := quizService.ArmQuizTimer(ctx, quizID, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartModule starts the timer on a module using the time limit for its section
+func (s *TestService) StartModule(ctx context.Context, test *Test, index int, quizService *quiz.QuizService, questionService *question.QuestionService) (*ModuleTimer, error) {
+	quizID, section, err := s.moduleSection(ctx, test, index, quizService, questionService)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := moduleTimeLimit(section)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleQuiz, err := quizService.StartQuizTimer(ctx, quizID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return newModuleTimer(moduleQuiz, section), nil
+}
+
+// PauseModule pauses the timer on a module
+func (s *TestService) PauseModule(ctx context.Context, test *Test, index int, quizService *quiz.QuizService, questionService *question.QuestionService) (*ModuleTimer, error) {
+	quizID, section, err := s.moduleSection(ctx, test, index, quizService, questionService)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleQuiz, err := quizService.PauseQuizTimer(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newModuleTimer(moduleQuiz, section), nil
+}
+
+// ResumeModule resumes the timer on a paused module
+func (s *TestService) ResumeModule(ctx context.Context, test *Test, index int, quizService *quiz.QuizService, questionService *question.QuestionService) (*ModuleTimer, error) {
+	quizID, section, err := s.moduleSection(ctx, test, index, quizService, questionService)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleQuiz, err := quizService.ResumeQuizTimer(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newModuleTimer(moduleQuiz, section), nil
+}
+
+// GetModuleTimer returns the remaining time on a module, submitting it if the time has run out
+func (s *TestService) GetModuleTimer(ctx context.Context, test *Test, index int, quizService *quiz.QuizService, questionService *question.QuestionService) (*ModuleTimer, error) {
+	quizID, section, err := s.moduleSection(ctx, test, index, quizService, questionService)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleQuiz, err := quizService.GetQuizTimer(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newModuleTimer(moduleQuiz, section), nil
+}
+
+// RunDeadlineSweeper submits expired modules every interval until the context is cancelled.
+// When the expired module is the current module of an adaptive test, the next module is created.
+func (s *TestService) RunDeadlineSweeper(ctx context.Context, interval time.Duration, quizService *quiz.QuizService, questionService *question.QuestionService, engagementService *engagement.EngagementService) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			quizIDs, err := quizService.SubmitExpiredQuizzes(ctx)
+			if err != nil {
+				fmt.Println("Error submitting expired quizzes:", err)
+			}
+
+			for _, quizID := range quizIDs {
+				var test Test
+				err := s.collection.FindOne(ctx, bson.M{"adaptive": true, "completed": bson.M{"$ne": true}, "modules": bson.M{"$elemMatch": bson.M{"quiz_id": quizID, "submitted": false}}}).Decode(&test)
+				if err == mongo.ErrNoDocuments {
+					continue
+				}
+				if err != nil {
+					fmt.Println("Error finding test for expired quiz:", err)
+					continue
+				}
+
+				if _, err := s.SubmitModule(ctx, test.ID, quizService, questionService, engagementService); err != nil {
+					fmt.Println("Error submitting expired module:", err)
+				}
+			}
+		}
+	}
+}
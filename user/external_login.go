@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
+)
+
+// OAuthStateLifeTime is how long a user has to finish signing in with a provider
+const OAuthStateLifeTime = time.Minute * 10
+
+// ErrUnknownProvider is returned for identity providers that are not configured
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// ErrInvalidOAuthState is returned when the state in a callback is unknown, expired or for another provider
+var ErrInvalidOAuthState = errors.New("invalid or expired login attempt")
+
+// ErrEmailNotVerified is returned when a provider has not verified the user's email, so it cannot be linked to an account
+var ErrEmailNotVerified = errors.New("email address is not verified by the identity provider")
+
+// oauthState remembers a login that has been started but not finished
+type oauthState struct {
+	State        string    `bson:"_id"`
+	Provider     string    `bson:"provider"`
+	CodeVerifier string    `bson:"code_verifier"`
+	Nonce        string    `bson:"nonce"`
+	ExpiresTime  time.Time `bson:"expires_time"`
+}
+
+// RegisterIdentityProvider makes a provider available under /user/oauth/:provider
+func (us *UserService) RegisterIdentityProvider(name string, provider IdentityProvider) {
+	us.providers[name] = provider
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// StartExternalLogin returns the URL where the user signs in with the provider
+func (us *UserService) StartExternalLogin(ctx context.Context, providerName string) (string, error) {
+	provider, ok := us.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	pending := oauthState{
+		State:        state,
+		Provider:     providerName,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresTime:  time.Now().Add(OAuthStateLifeTime),
+	}
+
+	url, err := provider.AuthCodeURL(ctx, pending.State, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := us.oauthStateCollection.InsertOne(ctx, pending); err != nil {
+		return "", err
+	}
+
+	return url, nil
+}
+
+// FinishExternalLogin exchanges the authorization code, links or creates the user and starts a session
+func (us *UserService) FinishExternalLogin(ctx context.Context, providerName, code, state string) (*TokenPair, error) {
+	provider, ok := us.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	// Each state can only be used once
+	var pending oauthState
+	err := us.oauthStateCollection.FindOneAndDelete(ctx, bson.M{"_id": state}).Decode(&pending)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	if pending.Provider != providerName || time.Now().After(pending.ExpiresTime) {
+		return nil, ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := us.linkExternalIdentity(ctx, providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return us.CreateSession(ctx, user)
+}
+
+// linkExternalIdentity finds the user who has signed in with this identity before, or else the user with the same email,
+// ignoring case. If neither exists a new student account is created.
+func (us *UserService) linkExternalIdentity(ctx context.Context, providerName string, identity *ExternalIdentity) (*User, error) {
+	var user User
+	err := us.collection.FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": providerName, "subject": identity.Subject}}}).Decode(&user)
+	if err == nil {
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// Linking by email is only safe if the provider has checked the user owns it
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	login := ExternalLogin{Provider: providerName, Subject: identity.Subject}
+
+	existing, err := us.getUserByEmailFold(ctx, identity.Email)
+	if err != nil && err != ErrUserNotFound {
+		return nil, err
+	}
+	if existing != nil {
+		set := bson.M{"email_verified": true}
+		// Nobody has proven they own an unverified account, so whoever registered it could be someone else.
+		// The provider's user takes it over: the password is removed and any sessions are logged out.
+		if !existing.EmailVerified {
+			set["password_hash"] = ""
+			existing.PasswordHash = ""
+			if err := us.RevokeAllSessions(ctx, existing.ID.Hex()); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = us.collection.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{
+			"$push": bson.M{"identities": login},
+			"$set":  set,
+		})
+		if err != nil {
+			return nil, err
+		}
+		existing.EmailVerified = true
+		return existing, nil
+	}
+
+	// There is no password, so the account can only sign in through the provider until the user resets it
+	newUser := User{
+		Email:         identity.Email,
+		FirstName:     identity.FirstName,
+		LastName:      identity.LastName,
+		Role:          RoleStudent,
+		EmailVerified: true,
+		Identities:    []ExternalLogin{login},
+	}
+	return us.CreateUser(ctx, &newUser)
+}
+
+// getUserByEmailFold returns the user with the email, ignoring case
+func (us *UserService) getUserByEmailFold(ctx context.Context, email string) (*User, error) {
+	var user User
+	opts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	err := us.collection.FindOne(ctx, bson.M{"email": email}, opts).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,98 @@
+package user
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailSender sends plain text emails to users
+type MailSender interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailSender sends mail through an SMTP server
+type SMTPMailSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPMailSender) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{to}, []byte(message))
+}
+
+// FileMailSender appends mail to a file instead of sending it, for local development and tests.
+// If Path is empty the mail is written to the log.
+type FileMailSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileMailSender) Send(to, subject, body string) error {
+	entry := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), to, subject, body)
+
+	if s.Path == "" {
+		log.Print("Mail not sent (no SMTP_HOST configured):\n" + entry)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
+
+// NewMailSenderFromEnv uses SMTP when SMTP_HOST is set, and otherwise writes mail to MAIL_LOG_FILE or the log
+func NewMailSenderFromEnv() MailSender {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return &FileMailSender{Path: os.Getenv("MAIL_LOG_FILE")}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USERNAME")
+	}
+
+	return &SMTPMailSender{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
@@ -1,8 +1,10 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
@@ -18,10 +20,11 @@ func JWTMiddleware(userService *UserService) gin.HandlerFunc {
 			tokenString := strings.Replace(authHeader, "Bearer ", "", -1)
 
 			// Parse the token and extract the user ID
-			userID, err := userService.ParseToken(tokenString)
+			userID, sessionID, err := userService.ValidateAccessToken(c.Request.Context(), tokenString)
 			if err == nil {
 				// Set the user ID in the context if the token is valid
 				c.Set("userID", userID)
+				c.Set("sessionID", sessionID)
 				// fmt.Println("userID", userID)
 			}
 			// Note: No error handling here - we allow the request to continue regardless
@@ -32,8 +35,92 @@ func JWTMiddleware(userService *UserService) gin.HandlerFunc {
 	}
 }
 
-// ParseToken parses a token and returns the user email.
-func (us *UserService) ParseToken(tokenString string) (string, error) {
+// StrictJWTMiddleware is the strict mode of JWTMiddleware. It returns 401 when the token is missing, invalid, expired or revoked.
+func StrictJWTMiddleware(userService *UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+			return
+		}
+
+		tokenString := strings.Replace(authHeader, "Bearer ", "", -1)
+		userID, sessionID, err := userService.ValidateAccessToken(c.Request.Context(), tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("userID", userID)
+		c.Set("sessionID", sessionID)
+		c.Next()
+	}
+}
+
+// RequireRole only lets a request through if the logged in user has one of the given roles.
+// Super admins are always allowed. It must run after JWTMiddleware.
+func RequireRole(userService *UserService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			return
+		}
+
+		role, err := userService.FetchUserRoleFromDB(c.Request.Context(), userID.(string))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		if role == RoleSuperAdmin {
+			c.Next()
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	}
+}
+
+// ValidateAccessToken parses an access token and checks that its session has not been logged out.
+// It returns the user ID and session ID.
+func (us *UserService) ValidateAccessToken(ctx context.Context, tokenString string) (string, string, error) {
+	claims, err := us.parseClaims(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+
+	userID, ok := claims["_id"].(string)
+	if !ok {
+		return "", "", errors.New("invalid token claims")
+	}
+
+	// Tokens issued before sessions existed cannot be revoked, so they are no longer accepted
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		return "", "", errors.New("invalid token claims")
+	}
+
+	active, err := us.SessionActive(ctx, sessionID)
+	if err != nil {
+		return "", "", err
+	}
+	if !active {
+		return "", "", ErrSessionRevoked
+	}
+
+	return userID, sessionID, nil
+}
+
+// parseClaims checks the signature and expiry of an access token
+func (us *UserService) parseClaims(tokenString string) (jwt.MapClaims, error) {
 	// Get the secret key from the environment variable
 	secretKey := os.Getenv("SECRET_KEY")
 
@@ -46,17 +133,17 @@ func (us *UserService) ParseToken(tokenString string) (string, error) {
 		return []byte(secretKey), nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Extract the claims
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["_id"].(string)
-		if !ok {
-			return "", errors.New("invalid token claims")
+		// Purpose tokens (email verification, password reset) are not access tokens
+		if _, ok := claims["purpose"]; ok {
+			return nil, errors.New("invalid token")
 		}
-		return userID, nil
+		return claims, nil
 	} else {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 }
@@ -1,6 +1,10 @@
 package user
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // User represents a user in the system.
 type User struct {
@@ -11,5 +15,85 @@ type User struct {
 	LastName     string             `bson:"last_name" json:"LastName"`
 	PhoneNumber  string             `bson:"phone_number" json:"PhoneNumber"`
 	Tier         string             `bson:"tier" json:"Tier"`
+	Role         string             `bson:"role" json:"Role"`
+	// EmailVerified is set once the user follows the link in their verification email
+	EmailVerified bool `bson:"email_verified" json:"EmailVerified"`
+	// Identities are the external logins (such as Google) linked to this account
+	Identities []ExternalLogin `bson:"identities,omitempty" json:"Identities,omitempty"`
+}
+
+// ExternalLogin links an account to a user at an identity provider
+type ExternalLogin struct {
+	Provider string `bson:"provider" json:"Provider"`
+	Subject  string `bson:"subject" json:"Subject"`
+}
+
+const (
+	RoleStudent      = "student"
+	RoleTutor        = "tutor"
+	RoleContentAdmin = "content-admin"
+	RoleSuperAdmin   = "super-admin"
+)
+
+// Session is a login on one device. The refresh token rotates on every use and only its hash is stored;
+// the hashes it replaced are kept so a stolen, already used token can be detected.
+type Session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"UserID"`
+	TokenHash      string             `bson:"token_hash" json:"-"`
+	PreviousHashes []string           `bson:"previous_hashes" json:"-"`
+	CreatedTime    time.Time          `bson:"created_time" json:"CreatedTime"`
+	LastUsedTime   time.Time          `bson:"last_used_time" json:"LastUsedTime"`
+	ExpiresTime    time.Time          `bson:"expires_time" json:"ExpiresTime"`
+	Revoked        bool               `bson:"revoked" json:"Revoked"`
 }
 
+const (
+	TierFree = "free"
+	TierPaid = "paid"
+)
+
+const (
+	SubscriptionTrialing = "trialing"
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+	SubscriptionExpired  = "expired"
+)
+
+// Plan is something a user can subscribe to
+type Plan struct {
+	ID        string `json:"ID"`
+	Name      string `json:"Name"`
+	Tier      string `json:"Tier"`
+	Price     int    `json:"Price"` // in cents
+	Interval  string `json:"Interval"`
+	TrialDays int    `json:"TrialDays"`
+}
+
+// Plans are the plans on offer, keyed by ID. The IDs must match the plan_id metadata sent by the payment provider.
+var Plans = map[string]Plan{
+	"monthly": {ID: "monthly", Name: "Monthly", Tier: TierPaid, Price: 1999, Interval: "month", TrialDays: 7},
+	"yearly":  {ID: "yearly", Name: "Yearly", Tier: TierPaid, Price: 14999, Interval: "year", TrialDays: 7},
+}
+
+// Subscription gives a user the tier of its plan while it is trialing or active and EndTime has not passed
+type Subscription struct {
+	ID                     primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID                 primitive.ObjectID `bson:"user_id" json:"UserID"`
+	PlanID                 string             `bson:"plan_id" json:"PlanID"`
+	Status                 string             `bson:"status" json:"Status"`
+	StartTime              time.Time          `bson:"start_time" json:"StartTime"`
+	EndTime                time.Time          `bson:"end_time" json:"EndTime"`
+	TrialEnd               *time.Time         `bson:"trial_end,omitempty" json:"TrialEnd,omitempty"`
+	CancelAtPeriodEnd      bool               `bson:"cancel_at_period_end" json:"CancelAtPeriodEnd"`
+	ProviderCustomerID     string             `bson:"provider_customer_id,omitempty" json:"-"`
+	ProviderSubscriptionID string             `bson:"provider_subscription_id,omitempty" json:"-"`
+	// LastEventTime is when the payment provider created the last event applied, so late events are ignored
+	LastEventTime time.Time `bson:"last_event_time" json:"-"`
+	UpdatedTime   time.Time `bson:"updated_time" json:"UpdatedTime"`
+}
+
+// ValidRoles lists every role a user can be given
+var ValidRoles = []string{RoleStudent, RoleTutor, RoleContentAdmin, RoleSuperAdmin}
+
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/mail"
 
@@ -19,6 +21,30 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All logs the user out of every session, not just this one
+	All bool `json:"all"`
+}
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 // RegisterRoutes registers the user routes.
 func RegisterRoutes(router *gin.Engine, userService *UserService) {
 	userGroup := router.Group("/user")
@@ -26,21 +52,38 @@ func RegisterRoutes(router *gin.Engine, userService *UserService) {
 		userGroup.POST("/register", func(c *gin.Context) { createUser(c, userService) })
 		userGroup.GET("/:id", func(c *gin.Context) { getUser(c, userService) })
 		userGroup.POST("/login", func(c *gin.Context) { loginUser(c, userService) })
+		userGroup.POST("/refresh", func(c *gin.Context) { refreshSession(c, userService) })
+		userGroup.POST("/logout", func(c *gin.Context) { logoutUser(c, userService) })
+		userGroup.GET("/oauth/:provider/start", func(c *gin.Context) { startExternalLogin(c, userService) })
+		userGroup.POST("/oauth/:provider/callback", func(c *gin.Context) { finishExternalLogin(c, userService) })
+		userGroup.POST("/:id/revoke-sessions", RequireRole(userService, RoleSuperAdmin), func(c *gin.Context) { revokeUserSessions(c, userService) })
 		userGroup.GET("/confirm", func(c *gin.Context) { confirmUser(c, userService) }) // Removed :id
+		userGroup.GET("/verify", func(c *gin.Context) { verifyEmail(c, userService) })
+		userGroup.POST("/forgot-password", func(c *gin.Context) { forgotPassword(c, userService) })
+		userGroup.POST("/reset-password", func(c *gin.Context) { resetPassword(c, userService) })
+		userGroup.PUT("/:id/role", RequireRole(userService, RoleSuperAdmin), func(c *gin.Context) { setUserRole(c, userService) })
 
 		// Add more routes as needed
 	}
+
+	subscriptionGroup := router.Group("/subscription")
+	{
+		subscriptionGroup.GET("/plans", getPlans)
+		subscriptionGroup.GET("", func(c *gin.Context) { getSubscription(c, userService) })
+		subscriptionGroup.POST("/trial", func(c *gin.Context) { startTrial(c, userService) })
+		subscriptionGroup.POST("/webhook", func(c *gin.Context) { paymentWebhook(c, userService) })
+	}
 }
 
-// GetUserTier fetches the user's tier from the database
+// GetUserTier returns the tier of the user's active subscription
 func (us *UserService) GetUserTier(c *gin.Context) string {
 	// Attempt to get user ID from JWT token
 	userID, ok := c.Get("userID")
 
-	userTier := "free" // Default to free tier
+	userTier := TierFree // Default to free tier
 	if ok {
-		// Fetch user's tier from the database
-		tier, err := us.FetchUserTierFromDB(c.Request.Context(), userID.(string))
+		// Look up the user's active subscription
+		tier, err := us.ActiveTier(c.Request.Context(), userID.(string))
 		if err == nil {
 			userTier = tier
 		}
@@ -68,7 +111,7 @@ func createUser(c *gin.Context, userService *UserService) {
 	// Check if a user with the same email already exists
 	existingUser, err := userService.GetUserByEmail(c, request.Email)
 	if err != nil {
-		if err.Error() == "user not found" {
+		if err == ErrUserNotFound {
 			// User not found, continue execution
 		} else {
 			// Other error, return an internal server error
@@ -98,6 +141,7 @@ func createUser(c *gin.Context, userService *UserService) {
 	newUser := User{
 		Email:        request.Email,
 		PasswordHash: string(hashedPassword),
+		Role:         RoleStudent,
 		// Add other fields as needed
 	}
 
@@ -107,6 +151,11 @@ func createUser(c *gin.Context, userService *UserService) {
 		return
 	}
 
+	// The account is usable straight away, so a failed email should not fail the registration
+	if err := userService.SendVerificationEmail(createdUser); err != nil {
+		fmt.Println("Error sending verification email:", err)
+	}
+
 	c.JSON(http.StatusCreated, createdUser)
 }
 
@@ -138,14 +187,108 @@ func loginUser(c *gin.Context, userService *UserService) {
 		return
 	}
 
-	// Generate a token for the user
-	token, err := userService.GenerateToken(user)
+	// Start a session and generate tokens for the user
+	tokens, err := userService.CreateSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+// refreshSession swaps a refresh token for a new access token and refresh token
+func refreshSession(c *gin.Context, userService *UserService) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	tokens, err := userService.RefreshSession(c, request.RefreshToken)
+	if err != nil {
+		if err == ErrInvalidRefreshToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// logoutUser revokes the current session, or every session of the user when "all" is set
+func logoutUser(c *gin.Context, userService *UserService) {
+	var request LogoutRequest
+	// The body is optional when the access token identifies the session
+	_ = c.ShouldBindJSON(&request)
+
+	userID, loggedIn := c.Get("userID")
+	sessionID, hasSession := c.Get("sessionID")
+
+	var err error
+	switch {
+	case request.All && loggedIn:
+		err = userService.RevokeAllSessions(c, userID.(string))
+	case request.All:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+		return
+	case request.RefreshToken != "":
+		err = userService.RevokeSessionByRefreshToken(c, request.RefreshToken)
+	case loggedIn && hasSession:
+		err = userService.RevokeSession(c, userID.(string), sessionID.(string))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Access token or refresh token is required"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// revokeUserSessions logs a user out of every session. Only super admins can do this.
+func revokeUserSessions(c *gin.Context, userService *UserService) {
+	if err := userService.RevokeAllSessions(c, c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+}
+
+// setUserRole handles changing the role of a user. Only super admins can do this.
+func setUserRole(c *gin.Context, userService *UserService) {
+	var request struct {
+		Role string `json:"Role"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	valid := false
+	for _, role := range ValidRoles {
+		if request.Role == role {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	if err := userService.SetUserRole(c, c.Param("id"), request.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
 }
 
 func confirmUser(c *gin.Context, userService *UserService) {
@@ -163,3 +306,200 @@ func confirmUser(c *gin.Context, userService *UserService) {
 
 	c.JSON(http.StatusOK, gin.H{"userExists": exists})
 }
+
+// verifyEmail handles the link sent in the verification email
+func verifyEmail(c *gin.Context, userService *UserService) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+		return
+	}
+
+	if err := userService.VerifyEmail(c, token); err != nil {
+		if err == ErrInvalidToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// forgotPassword emails a password reset link. The response is the same whether or not the account exists.
+func forgotPassword(c *gin.Context, userService *UserService) {
+	var request ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := mail.ParseAddress(request.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	if err := userService.RequestPasswordReset(c, request.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// resetPassword sets a new password using the token from the reset email
+func resetPassword(c *gin.Context, userService *UserService) {
+	var request ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long"})
+		return
+	}
+
+	if err := userService.ResetPassword(c, request.Token, request.Password); err != nil {
+		if err == ErrInvalidToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// startExternalLogin returns the URL where the user signs in with an identity provider such as Google
+func startExternalLogin(c *gin.Context, userService *UserService) {
+	url, err := userService.StartExternalLogin(c, c.Param("provider"))
+	if err != nil {
+		if err == ErrUnknownProvider {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// finishExternalLogin is called by the frontend with the code and state the provider redirected back with
+func finishExternalLogin(c *gin.Context, userService *UserService) {
+	var request OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" || request.State == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code and state are required"})
+		return
+	}
+
+	tokens, err := userService.FinishExternalLogin(c, c.Param("provider"), request.Code, request.State)
+	if err != nil {
+		switch err {
+		case ErrUnknownProvider:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case ErrInvalidOAuthState, ErrEmailNotVerified:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to sign in with provider"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// getPlans lists the plans on offer
+func getPlans(c *gin.Context) {
+	plans := make([]Plan, 0, len(Plans))
+	for _, plan := range Plans {
+		plans = append(plans, plan)
+	}
+	c.JSON(http.StatusOK, plans)
+}
+
+// getSubscription returns the user's active subscription and their subscription history
+func getSubscription(c *gin.Context, userService *UserService) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+		return
+	}
+
+	active, err := userService.GetActiveSubscription(c, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := userService.GetSubscriptions(c, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tier := TierFree
+	if active != nil {
+		tier = Plans[active.PlanID].Tier
+	}
+
+	c.JSON(http.StatusOK, gin.H{"Tier": tier, "Active": active, "History": history})
+}
+
+// startTrial starts a free trial for a user who has never subscribed
+func startTrial(c *gin.Context, userService *UserService) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+		return
+	}
+
+	var request struct {
+		PlanID string `json:"PlanID"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := userService.StartTrial(c, userID.(string), request.PlanID)
+	if err != nil {
+		if err == ErrTrialUsed {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// paymentWebhook receives signed events from the payment provider
+func paymentWebhook(c *gin.Context, userService *UserService) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	event, err := parsePaymentEvent(payload, c.GetHeader("Stripe-Signature"))
+	if err != nil {
+		if err == ErrInvalidSignature {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := userService.HandlePaymentEvent(c, *event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
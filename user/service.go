@@ -15,20 +15,49 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrUserNotFound is returned when no user has the requested email or ID
+var ErrUserNotFound = errors.New("user not found")
+
 type UserService struct {
-	collection *mongo.Collection
+	collection             *mongo.Collection
+	sessionCollection      *mongo.Collection
+	oauthStateCollection   *mongo.Collection
+	subscriptionCollection *mongo.Collection
+	paymentEventCollection *mongo.Collection
+	mailSender             MailSender
+	providers              map[string]IdentityProvider
 }
 
 func NewUserService(client *mongo.Client) *UserService {
 	collection := client.Database("test").Collection("users")
-	return &UserService{collection: collection}
+	sessionCollection := client.Database("test").Collection("sessions")
+	oauthStateCollection := client.Database("test").Collection("oauth_states")
+	subscriptionCollection := client.Database("test").Collection("subscriptions")
+	paymentEventCollection := client.Database("test").Collection("payment_events")
+	return &UserService{
+		collection:             collection,
+		sessionCollection:      sessionCollection,
+		oauthStateCollection:   oauthStateCollection,
+		subscriptionCollection: subscriptionCollection,
+		paymentEventCollection: paymentEventCollection,
+		mailSender:             NewMailSenderFromEnv(),
+		providers:              make(map[string]IdentityProvider),
+	}
+}
+
+// SetMailSender replaces the mail sender chosen from the environment
+func (us *UserService) SetMailSender(sender MailSender) {
+	us.mailSender = sender
 }
 
 func (us *UserService) CreateUser(ctx context.Context, user *User) (*User, error) {
-	_, err := us.collection.InsertOne(ctx, user)
+	result, err := us.collection.InsertOne(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		user.ID = id
+	}
 	return user, nil
 }
 
@@ -46,7 +75,7 @@ func (us *UserService) GetUserByEmail(ctx context.Context, email string) (*User,
 	err := us.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -66,12 +95,14 @@ func (us *UserService) ValidateCredentials(ctx context.Context, email, password
 	return user, nil
 }
 
-// TokenLifeTime is the duration that a token is valid.
-const TokenLifeTime = time.Hour * 24
+// TokenLifeTime is the duration that an access token is valid. Clients use their refresh token to get a new one.
+const TokenLifeTime = time.Minute * 15
 
-func (us *UserService) GenerateToken(user *User) (string, error) {
+// GenerateToken issues an access token for the user, tied to the session it was issued for
+func (us *UserService) GenerateToken(user *User, sessionID primitive.ObjectID) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"_id": user.ID.Hex(),
+		"sid": sessionID.Hex(),
 		"exp": time.Now().Add(TokenLifeTime).Unix(),
 	})
 
@@ -100,6 +131,43 @@ func (us *UserService) FetchUserTierFromDB(ctx context.Context, userID string) (
 	return user.Tier, nil
 }
 
+// FetchUserRoleFromDB returns the user's role. Users created before roles existed are students.
+func (us *UserService) FetchUserRoleFromDB(ctx context.Context, userID string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", err
+	}
+
+	var user User
+	err = us.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Role == "" {
+		return RoleStudent, nil
+	}
+	return user.Role, nil
+}
+
+// SetUserRole changes the role of a user
+func (us *UserService) SetUserRole(ctx context.Context, userID string, role string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := us.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 func (us *UserService) UserExists(c *gin.Context, userID string) (bool, error) {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -0,0 +1,178 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RefreshTokenLifeTime is how long a session can go unused before the user has to log in again
+const RefreshTokenLifeTime = time.Hour * 24 * 30
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or revoked
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// ErrSessionRevoked is returned for access tokens whose session has been logged out
+var ErrSessionRevoked = errors.New("session has been revoked")
+
+// TokenPair is what a client receives when it logs in or refreshes its session
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (us *UserService) issueTokens(user *User, sessionID primitive.ObjectID, refreshToken string) (*TokenPair, error) {
+	token, err := us.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{Token: token, RefreshToken: refreshToken, ExpiresIn: int(TokenLifeTime.Seconds())}, nil
+}
+
+// CreateSession starts a new session for the user and returns its first access and refresh tokens
+func (us *UserService) CreateSession(ctx context.Context, user *User) (*TokenPair, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := Session{
+		UserID:         user.ID,
+		TokenHash:      hashRefreshToken(refreshToken),
+		PreviousHashes: []string{},
+		CreatedTime:    now,
+		LastUsedTime:   now,
+		ExpiresTime:    now.Add(RefreshTokenLifeTime),
+	}
+
+	result, err := us.sessionCollection.InsertOne(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return us.issueTokens(user, result.InsertedID.(primitive.ObjectID), refreshToken)
+}
+
+// RefreshSession swaps a refresh token for a new access token and a new refresh token.
+// If a refresh token that was already swapped is used again, it has probably been stolen, so the whole session is revoked.
+func (us *UserService) RefreshSession(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+
+	var session Session
+	err := us.sessionCollection.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		// Revoke the session if this token has been rotated out already
+		_, revokeErr := us.sessionCollection.UpdateOne(ctx, bson.M{"previous_hashes": hash}, bson.M{"$set": bson.M{"revoked": true}})
+		if revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if session.Revoked || now.After(session.ExpiresTime) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := us.getUserByObjectID(ctx, session.UserID.Hex())
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only rotate if nobody else rotated this token in the meantime
+	result, err := us.sessionCollection.UpdateOne(ctx,
+		bson.M{"_id": session.ID, "token_hash": hash, "revoked": false},
+		bson.M{
+			"$set":  bson.M{"token_hash": hashRefreshToken(newToken), "last_used_time": now, "expires_time": now.Add(RefreshTokenLifeTime)},
+			"$push": bson.M{"previous_hashes": hash},
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return us.issueTokens(user, session.ID, newToken)
+}
+
+// RevokeSession logs out a single session of the user
+func (us *UserService) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	sessionObjID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.sessionCollection.UpdateOne(ctx, bson.M{"_id": sessionObjID, "user_id": userObjID}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+// RevokeSessionByRefreshToken logs out the session a refresh token belongs to
+func (us *UserService) RevokeSessionByRefreshToken(ctx context.Context, refreshToken string) error {
+	_, err := us.sessionCollection.UpdateOne(ctx, bson.M{"token_hash": hashRefreshToken(refreshToken)}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+// RevokeAllSessions logs the user out everywhere
+func (us *UserService) RevokeAllSessions(ctx context.Context, userID string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.sessionCollection.UpdateMany(ctx, bson.M{"user_id": objID, "revoked": false}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+// SessionActive reports whether the session an access token was issued for is still logged in
+func (us *UserService) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	var session Session
+	err = us.sessionCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return !session.Revoked && time.Now().Before(session.ExpiresTime), nil
+}
@@ -0,0 +1,394 @@
+package user
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebhookTolerance is how old a signed webhook event may be before it is rejected as a replay
+const WebhookTolerance = time.Minute * 5
+
+// ErrInvalidSignature is returned for webhook requests that are not signed with PAYMENT_WEBHOOK_SECRET
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrTrialUsed is returned when a user who has already subscribed asks for a trial
+var ErrTrialUsed = errors.New("trial has already been used")
+
+// PaymentEvent is a webhook event from the payment provider, in the Stripe format
+type PaymentEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object ProviderSubscription `json:"object"`
+	} `json:"data"`
+}
+
+// ProviderSubscription is the subscription object sent with customer.subscription.* events
+type ProviderSubscription struct {
+	ID                 string            `json:"id"`
+	Customer           string            `json:"customer"`
+	Status             string            `json:"status"`
+	CurrentPeriodStart int64             `json:"current_period_start"`
+	CurrentPeriodEnd   int64             `json:"current_period_end"`
+	TrialEnd           int64             `json:"trial_end"`
+	CancelAtPeriodEnd  bool              `json:"cancel_at_period_end"`
+	Metadata           map[string]string `json:"metadata"`
+}
+
+// VerifyWebhookSignature checks a Stripe style signature header ("t=<timestamp>,v1=<hex hmac>").
+// The HMAC-SHA256 is computed over "<timestamp>.<payload>" with the shared secret.
+func VerifyWebhookSignature(payload []byte, header string, secret string, now time.Time) error {
+	if secret == "" {
+		return errors.New("webhook secret is not configured")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(payload)))
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// grantsAccess reports whether a subscription currently gives its plan's tier
+func (s *Subscription) grantsAccess(now time.Time) bool {
+	return (s.Status == SubscriptionTrialing || s.Status == SubscriptionActive) && now.Before(s.EndTime)
+}
+
+// ActiveTier returns the tier from the user's active subscription, or "free" if there is none
+func (us *UserService) ActiveTier(ctx context.Context, userID string) (string, error) {
+	subscription, err := us.GetActiveSubscription(ctx, userID)
+	if err != nil {
+		return TierFree, err
+	}
+	if subscription == nil {
+		return TierFree, nil
+	}
+
+	if plan, ok := Plans[subscription.PlanID]; ok {
+		return plan.Tier, nil
+	}
+	return TierPaid, nil
+}
+
+// GetActiveSubscription returns the subscription that currently gives the user access, or nil
+func (us *UserService) GetActiveSubscription(ctx context.Context, userID string) (*Subscription, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"user_id":  objID,
+		"status":   bson.M{"$in": []string{SubscriptionTrialing, SubscriptionActive}},
+		"end_time": bson.M{"$gt": now},
+	}
+	opts := options.FindOne().SetSort(bson.M{"end_time": -1})
+
+	var subscription Subscription
+	err = us.subscriptionCollection.FindOne(ctx, filter, opts).Decode(&subscription)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetSubscriptions returns all of the user's subscriptions, newest first
+func (us *UserService) GetSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := us.subscriptionCollection.Find(ctx, bson.M{"user_id": objID}, options.Find().SetSort(bson.M{"start_time": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subscriptions := []Subscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// StartTrial gives the user a free trial of a plan. Each user gets one trial, and only if they have never subscribed.
+func (us *UserService) StartTrial(ctx context.Context, userID string, planID string) (*Subscription, error) {
+	plan, ok := Plans[planID]
+	if !ok || plan.TrialDays <= 0 {
+		return nil, errors.New("plan does not offer a trial")
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := us.subscriptionCollection.CountDocuments(ctx, bson.M{"user_id": objID})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrTrialUsed
+	}
+
+	now := time.Now()
+	trialEnd := now.AddDate(0, 0, plan.TrialDays)
+	subscription := Subscription{
+		UserID:      objID,
+		PlanID:      plan.ID,
+		Status:      SubscriptionTrialing,
+		StartTime:   now,
+		EndTime:     trialEnd,
+		TrialEnd:    &trialEnd,
+		UpdatedTime: now,
+	}
+
+	result, err := us.subscriptionCollection.InsertOne(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+	subscription.ID = result.InsertedID.(primitive.ObjectID)
+
+	return &subscription, us.syncUserTier(ctx, objID)
+}
+
+// HandlePaymentEvent applies a verified webhook event. Events that were already handled, or that arrive after a newer event, are ignored.
+func (us *UserService) HandlePaymentEvent(ctx context.Context, event PaymentEvent) error {
+	if event.ID == "" {
+		return errors.New("event ID is required")
+	}
+
+	processed, err := us.paymentEventCollection.CountDocuments(ctx, bson.M{"_id": event.ID})
+	if err != nil {
+		return err
+	}
+	if processed > 0 {
+		return nil
+	}
+
+	switch event.Type {
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		if err := us.applyProviderSubscription(ctx, event); err != nil {
+			return err
+		}
+	default:
+		// Other events are acknowledged so the provider stops sending them
+	}
+
+	_, err = us.paymentEventCollection.InsertOne(ctx, bson.M{"_id": event.ID, "type": event.Type, "received_time": time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (us *UserService) applyProviderSubscription(ctx context.Context, event PaymentEvent) error {
+	object := event.Data.Object
+	if object.ID == "" {
+		return errors.New("subscription ID is required")
+	}
+	eventTime := time.Unix(event.Created, 0)
+
+	var existing Subscription
+	err := us.subscriptionCollection.FindOne(ctx, bson.M{"provider_subscription_id": object.ID}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	found := err == nil
+
+	if found && eventTime.Before(existing.LastEventTime) {
+		return nil
+	}
+
+	var userID primitive.ObjectID
+	switch {
+	case found:
+		userID = existing.UserID
+	case object.Metadata["user_id"] != "":
+		userID, err = primitive.ObjectIDFromHex(object.Metadata["user_id"])
+		if err != nil {
+			return fmt.Errorf("invalid user_id metadata: %w", err)
+		}
+	default:
+		return errors.New("subscription has no user_id metadata")
+	}
+
+	planID := object.Metadata["plan_id"]
+	if planID == "" && found {
+		planID = existing.PlanID
+	}
+	if _, ok := Plans[planID]; !ok {
+		return fmt.Errorf("unknown plan %q", planID)
+	}
+
+	status := providerStatus(object.Status)
+	if event.Type == "customer.subscription.deleted" {
+		status = SubscriptionCanceled
+	}
+
+	update := bson.M{
+		"user_id":                  userID,
+		"plan_id":                  planID,
+		"status":                   status,
+		"start_time":               time.Unix(object.CurrentPeriodStart, 0),
+		"end_time":                 time.Unix(object.CurrentPeriodEnd, 0),
+		"cancel_at_period_end":     object.CancelAtPeriodEnd,
+		"provider_customer_id":     object.Customer,
+		"provider_subscription_id": object.ID,
+		"last_event_time":          eventTime,
+		"updated_time":             time.Now(),
+	}
+	if object.TrialEnd > 0 {
+		update["trial_end"] = time.Unix(object.TrialEnd, 0)
+	}
+	if found && !existing.StartTime.IsZero() {
+		// Keep when the subscription first started rather than the start of the current period
+		update["start_time"] = existing.StartTime
+	}
+
+	_, err = us.subscriptionCollection.UpdateOne(ctx,
+		bson.M{"provider_subscription_id": object.ID},
+		bson.M{"$set": update},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	return us.syncUserTier(ctx, userID)
+}
+
+// providerStatus maps the payment provider's statuses onto ours
+func providerStatus(status string) string {
+	switch status {
+	case "trialing":
+		return SubscriptionTrialing
+	case "active":
+		return SubscriptionActive
+	case "past_due", "unpaid", "incomplete":
+		return SubscriptionPastDue
+	default:
+		return SubscriptionCanceled
+	}
+}
+
+// syncUserTier copies the tier from the active subscription onto the user, so the stored Tier stays accurate
+func (us *UserService) syncUserTier(ctx context.Context, userID primitive.ObjectID) error {
+	tier, err := us.ActiveTier(ctx, userID.Hex())
+	if err != nil {
+		return err
+	}
+
+	_, err = us.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"tier": tier}})
+	return err
+}
+
+// ExpireSubscriptions marks subscriptions past their end time as expired and moves their users back to the free tier
+func (us *UserService) ExpireSubscriptions(ctx context.Context) (int, error) {
+	filter := bson.M{
+		"status":   bson.M{"$in": []string{SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue}},
+		"end_time": bson.M{"$lte": time.Now()},
+	}
+
+	cursor, err := us.subscriptionCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var expired []Subscription
+	if err := cursor.All(ctx, &expired); err != nil {
+		return 0, err
+	}
+
+	for _, subscription := range expired {
+		_, err := us.subscriptionCollection.UpdateOne(ctx,
+			bson.M{"_id": subscription.ID, "end_time": bson.M{"$lte": time.Now()}},
+			bson.M{"$set": bson.M{"status": SubscriptionExpired, "updated_time": time.Now()}})
+		if err != nil {
+			return 0, err
+		}
+		if err := us.syncUserTier(ctx, subscription.UserID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+// RunSubscriptionExpiry expires subscriptions every interval until the context is cancelled
+func (us *UserService) RunSubscriptionExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := us.ExpireSubscriptions(ctx); err != nil {
+				fmt.Println("Error expiring subscriptions:", err)
+			}
+		}
+	}
+}
+
+// parsePaymentEvent verifies the signature of a webhook request and decodes the event
+func parsePaymentEvent(payload []byte, signatureHeader string) (*PaymentEvent, error) {
+	if err := VerifyWebhookSignature(payload, signatureHeader, os.Getenv("PAYMENT_WEBHOOK_SECRET"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(payload string, timestamp int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "whsec_test"
+	const payload = `{"id":"evt_1","type":"customer.subscription.updated"}`
+	now := time.Unix(1700000000, 0)
+	timestamp := now.Unix()
+	signature := sign(payload, timestamp, secret)
+	stamp := strconv.FormatInt(timestamp, 10)
+	old := strconv.FormatInt(timestamp-400, 10)
+	recent := strconv.FormatInt(timestamp-200, 10)
+
+	tests := []struct {
+		name    string
+		payload string
+		header  string
+		secret  string
+		wantErr bool
+	}{
+		{"valid", payload, "t=" + stamp + ",v1=" + signature, secret, false},
+		{"spaces after commas", payload, "t=" + stamp + ", v1=" + signature, secret, false},
+		{"one of several signatures", payload, "t=" + stamp + ",v1=00ff,v1=" + signature, secret, false},
+		{"unknown schemes are ignored", payload, "t=" + stamp + ",v0=abc,v1=" + signature, secret, false},
+		{"payload changed", payload + " ", "t=" + stamp + ",v1=" + signature, secret, true},
+		{"wrong secret", payload, "t=" + stamp + ",v1=" + signature, "whsec_other", true},
+		{"timestamp changed", payload, "t=" + strconv.FormatInt(timestamp+1, 10) + ",v1=" + signature, secret, true},
+		{"no signature", payload, "t=" + stamp, secret, true},
+		{"no timestamp", payload, "v1=" + signature, secret, true},
+		{"signature not hex", payload, "t=" + stamp + ",v1=zz", secret, true},
+		{"old event replayed", payload, "t=" + old + ",v1=" + sign(payload, timestamp-400, secret), secret, true},
+		{"within tolerance", payload, "t=" + recent + ",v1=" + sign(payload, timestamp-200, secret), secret, false},
+		{"empty header", payload, "", secret, true},
+		{"no secret configured", payload, "t=" + stamp + ",v1=" + signature, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature([]byte(tt.payload), tt.header, tt.secret, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyWebhookSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	purposeVerifyEmail   = "verify-email"
+	purposeResetPassword = "reset-password"
+)
+
+// VerificationTokenLifeTime is how long an email verification link is valid
+const VerificationTokenLifeTime = time.Hour * 48
+
+// ResetTokenLifeTime is how long a password reset link is valid
+const ResetTokenLifeTime = time.Hour
+
+// ErrInvalidToken is returned for verification and reset tokens that are expired, tampered with or already used
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// passwordFingerprint ties a reset token to the current password, so the token stops working once it has been used
+func passwordFingerprint(user *User) string {
+	sum := sha256.Sum256([]byte(user.PasswordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// generatePurposeToken signs a short lived token that can only be used for the given purpose
+func generatePurposeToken(user *User, purpose string, lifetime time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"_id":     user.ID.Hex(),
+		"purpose": purpose,
+		"exp":     time.Now().Add(lifetime).Unix(),
+	}
+	if purpose == purposeVerifyEmail {
+		claims["email"] = user.Email
+	}
+	if purpose == purposeResetPassword {
+		claims["pwd"] = passwordFingerprint(user)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+}
+
+// parsePurposeToken checks the signature, expiry and purpose of a token and returns its claims
+func parsePurposeToken(tokenString string, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("SECRET_KEY")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["_id"].(string); !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// appBaseURL is where the frontend lives, used to build links in emails
+func appBaseURL() string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return base
+}
+
+func (us *UserService) getUserByObjectID(ctx context.Context, userID string) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := us.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SendVerificationEmail emails the user a link to confirm their address
+func (us *UserService) SendVerificationEmail(user *User) error {
+	token, err := generatePurposeToken(user, purposeVerifyEmail, VerificationTokenLifeTime)
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/verify?token=" + url.QueryEscape(token)
+	body := "Welcome! Please confirm your email address by opening the link below:\n\n" + link +
+		"\n\nThe link expires in 48 hours."
+
+	return us.mailSender.Send(user.Email, "Confirm your email address", body)
+}
+
+// VerifyEmail marks the user in the token as verified, as long as their email has not changed since it was issued
+func (us *UserService) VerifyEmail(ctx context.Context, tokenString string) error {
+	claims, err := parsePurposeToken(tokenString, purposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	user, err := us.getUserByObjectID(ctx, claims["_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if claims["email"] != user.Email {
+		return ErrInvalidToken
+	}
+
+	_, err = us.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"email_verified": true}})
+	return err
+}
+
+// RequestPasswordReset emails a reset link if an account exists for the email.
+// It does not report whether the account exists.
+func (us *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := us.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+
+	token, err := generatePurposeToken(user, purposeResetPassword, ResetTokenLifeTime)
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+	body := "We received a request to reset your password. Open the link below to choose a new one:\n\n" + link +
+		"\n\nThe link expires in 1 hour. If you did not ask for this, you can ignore this email."
+
+	return us.mailSender.Send(user.Email, "Reset your password", body)
+}
+
+// ResetPassword sets a new password for the user in the token. Each token can only be used once.
+func (us *UserService) ResetPassword(ctx context.Context, tokenString string, password string) error {
+	claims, err := parsePurposeToken(tokenString, purposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	user, err := us.getUserByObjectID(ctx, claims["_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if claims["pwd"] != passwordFingerprint(user) {
+		return ErrInvalidToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Following the emailed link also proves the user owns the address
+	_, err = us.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"password_hash":  string(hashedPassword),
+		"email_verified": true,
+	}})
+	if err != nil {
+		return err
+	}
+
+	// Anyone who knew the old password should not stay logged in
+	return us.RevokeAllSessions(ctx, user.ID.Hex())
+}
@@ -9,11 +9,11 @@ import (
 	"google.golang.org/api/youtube/v3"
 )
 
-func RegisterRoutes(publicRouter *gin.RouterGroup, service *VideoService, youtubeService *youtube.Service) {
+func RegisterRoutes(publicRouter *gin.RouterGroup, service *VideoService, youtubeService *youtube.Service, userService *user.UserService) {
 	// Add this line to create a new route for getVideos
 	publicRouter.GET("/video", getVideo(service))
 	publicRouter.GET("/videosbyid", getVideosByID(service))
-	publicRouter.POST("/video", postVideo(service, youtubeService))
+	publicRouter.POST("/video", user.RequireRole(userService, user.RoleContentAdmin), postVideo(service, youtubeService))
 }
 
 func getVideo(service *VideoService) gin.HandlerFunc {